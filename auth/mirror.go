@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/docker/distribution/reference"
+)
+
+// RegistryMirror is a registry images are pulled from in place of the
+// registry they're published to.
+//
+// Whether a mirror is reached over plain HTTP follows the engine's registry
+// config, like any other registry.
+type RegistryMirror struct {
+	// Address of the mirror: a host, optionally followed by a path prefix
+	// for its repositories (e.g., "mirror.local:5000/docker-hub").
+	Address string
+}
+
+// Host returns the host of the mirror.
+func (mirror RegistryMirror) Host() string {
+	return strings.SplitN(mirror.Address, "/", 2)[0]
+}
+
+// RegistryMirrors are the mirrors to pull the images of registries from, by
+// registry.
+type RegistryMirrors map[string]RegistryMirror
+
+// With returns a copy of the mirrors with one configured to pull the images
+// of the registry at the given address from.
+//
+// The mirror may be given as a URL, whose scheme is ignored.
+func (mirrors RegistryMirrors) With(address, mirror string) (RegistryMirrors, error) {
+	registry, err := parseAuthAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	mirror = strings.TrimPrefix(mirror, "http://")
+	mirror = strings.TrimPrefix(mirror, "https://")
+	mirror = strings.TrimSuffix(mirror, "/")
+
+	// the mirror must prefix repository paths with a registry host
+	if _, err := reference.ParseNamed(mirror + "/library/alpine"); err != nil {
+		return nil, fmt.Errorf("invalid mirror address %q: %w", mirror, err)
+	}
+
+	cp := make(RegistryMirrors, len(mirrors)+1)
+	for k, v := range mirrors {
+		cp[k] = v
+	}
+
+	cp[registry] = RegistryMirror{
+		Address: mirror,
+	}
+
+	return cp, nil
+}
+
+// AddMirror configures a mirror to pull the images of the registry at the
+// given address from for the rest of the session.
+//
+// The mirror may be given as a URL, whose scheme is ignored.
+func (r *RegistryAuthProvider) AddMirror(address, mirror string) error {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	mirrors, err := r.mirrors.With(address, mirror)
+	if err != nil {
+		return err
+	}
+
+	r.mirrors = mirrors
+
+	return nil
+}
+
+// MirrorRef returns the ref to pull an image from, rewritten to the mirror of
+// its registry if it has one: in scoped, which take precedence, or among the
+// session's.
+func (r *RegistryAuthProvider) MirrorRef(ref string, scoped RegistryMirrors) (string, error) {
+	named, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return "", err
+	}
+
+	mirror, found := scoped[reference.Domain(named)]
+	if !found {
+		r.m.RLock()
+		mirror, found = r.mirrors[reference.Domain(named)]
+		r.m.RUnlock()
+	}
+
+	if !found {
+		return ref, nil
+	}
+
+	mirrored := mirror.Address + "/" + reference.Path(named)
+	if tagged, ok := named.(reference.Tagged); ok {
+		mirrored += ":" + tagged.Tag()
+	}
+	if digested, ok := named.(reference.Digested); ok {
+		mirrored += "@" + digested.Digest().String()
+	}
+
+	return mirrored, nil
+}
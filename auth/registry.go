@@ -35,6 +35,10 @@ type RegistryAuthProvider struct {
 	// Memory map credential storage.
 	credentials map[string]*bkauth.CredentialsResponse
 
+	// Mirrors to pull images from instead of their registry, by registry
+	// domain.
+	mirrors RegistryMirrors
+
 	// Mutex to handle concurrency.
 	m sync.RWMutex
 }
@@ -43,6 +47,7 @@ type RegistryAuthProvider struct {
 func NewRegistryAuthProvider(cfg *configfile.ConfigFile) *RegistryAuthProvider {
 	return &RegistryAuthProvider{
 		credentials:        map[string]*bkauth.CredentialsResponse{},
+		mirrors:            RegistryMirrors{},
 		dockerAuthProvider: authprovider.NewDockerAuthProvider(cfg).(bkauth.AuthServer),
 	}
 }
@@ -172,6 +177,14 @@ func (r *RegistryAuthProvider) credential(domain string) *bkauth.CredentialsResp
 		}
 	}
 
+	// Like Docker, authenticate to a mirror as the registry it mirrors unless
+	// it has credentials of its own.
+	for registry, mirror := range r.mirrors {
+		if mirror.Host() == domain {
+			return r.credentials[registry]
+		}
+	}
+
 	return nil
 }
 
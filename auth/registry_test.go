@@ -3,6 +3,8 @@ package auth
 import (
 	"testing"
 
+	"github.com/docker/cli/cli/config/configfile"
+
 	"github.com/stretchr/testify/require"
 )
 
@@ -102,3 +104,66 @@ func TestParseAuthAddress(t *testing.T) {
 		})
 	}
 }
+
+func TestMirrorRef(t *testing.T) {
+	r := NewRegistryAuthProvider(configfile.New(""))
+	require.NoError(t, r.AddMirror("docker.io", "https://mirror.local:5000/docker-hub/"))
+	require.NoError(t, r.AddMirror("ghcr.io", "http://ghcr-mirror.local"))
+	require.Error(t, r.AddMirror("quay.io", "not a host"))
+
+	testCases := map[string]string{
+		"alpine":                      "mirror.local:5000/docker-hub/library/alpine",
+		"alpine:3.16.2":               "mirror.local:5000/docker-hub/library/alpine:3.16.2",
+		"docker.io/foo/bar:1.1":       "mirror.local:5000/docker-hub/foo/bar:1.1",
+		"index.docker.io/foo/bar:1.1": "mirror.local:5000/docker-hub/foo/bar:1.1",
+		"ghcr.io/foo/bar@sha256:bc8813ea7b3603864987522f02a76101c17ad122e1c46d790efc0fca78ca7bfb": "ghcr-mirror.local/foo/bar@sha256:bc8813ea7b3603864987522f02a76101c17ad122e1c46d790efc0fca78ca7bfb",
+		"registry.com:5000/bar:1.1": "registry.com:5000/bar:1.1",
+	}
+
+	for ref, expected := range testCases {
+		t.Run(ref, func(t *testing.T) {
+			result, err := r.MirrorRef(ref, nil)
+			require.NoError(t, err)
+			require.Equal(t, expected, result)
+		})
+	}
+}
+
+func TestScopedMirrorRef(t *testing.T) {
+	r := NewRegistryAuthProvider(configfile.New(""))
+	require.NoError(t, r.AddMirror("docker.io", "mirror.local:5000/docker-hub"))
+
+	scoped, err := RegistryMirrors(nil).With("docker.io", "scoped.local")
+	require.NoError(t, err)
+	_, err = scoped.With("quay.io", "not a host")
+	require.Error(t, err)
+
+	result, err := r.MirrorRef("alpine:3.16.2", scoped)
+	require.NoError(t, err)
+	require.Equal(t, "scoped.local/library/alpine:3.16.2", result)
+
+	// scoping doesn't configure the session's mirrors
+	result, err = r.MirrorRef("alpine:3.16.2", nil)
+	require.NoError(t, err)
+	require.Equal(t, "mirror.local:5000/docker-hub/library/alpine:3.16.2", result)
+}
+
+func TestMirrorCredential(t *testing.T) {
+	r := NewRegistryAuthProvider(configfile.New(""))
+	require.NoError(t, r.AddCredential("docker.io", "hub-user", "hub-secret"))
+	require.NoError(t, r.AddMirror("docker.io", "mirror.local:5000/docker-hub"))
+	require.NoError(t, r.AddMirror("ghcr.io", "ghcr-mirror.local"))
+
+	cred := r.credential("mirror.local:5000")
+	require.NotNil(t, cred)
+	require.Equal(t, "hub-user", cred.Username)
+
+	// mirrors of registries without credentials don't borrow any
+	require.Nil(t, r.credential("ghcr-mirror.local"))
+
+	// the mirror's own credentials take precedence
+	require.NoError(t, r.AddCredential("mirror.local:5000", "mirror-user", "mirror-secret"))
+	cred = r.credential("mirror.local:5000")
+	require.NotNil(t, cred)
+	require.Equal(t, "mirror-user", cred.Username)
+}
@@ -26,6 +26,8 @@ func withEngine(
 		DisableHostRW: disableHostRW,
 		JournalURI:    os.Getenv("_EXPERIMENTAL_DAGGER_JOURNAL"),
 		JournalWriter: journalW,
+
+		RegistryMirrors: registryMirrors,
 	}
 	if debugLogs {
 		engineConf.LogOutput = logsW
@@ -46,6 +46,7 @@ DAGGER_SESSION_PORT and DAGGER_SESSION_TOKEN will be convieniently injected auto
 }
 
 var waitDelay time.Duration
+var registryMirrors []string
 var useShinyNewTUI = os.Getenv("_EXPERIMENTAL_DAGGER_TUI") != ""
 
 func init() {
@@ -58,6 +59,13 @@ func init() {
 		10*time.Second,
 		"max duration to wait between SIGTERM and SIGKILL on interrupt",
 	)
+
+	runCmd.Flags().StringArrayVar(
+		&registryMirrors,
+		"registry-mirror",
+		nil,
+		"pull images of a registry from a mirror, as registry=mirror (e.g. docker.io=mirror.local:5000); plain HTTP mirrors must be allowed by the engine's registry config",
+	)
 }
 
 func Run(cmd *cobra.Command, args []string) {
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"sort"
+
 	"github.com/moby/buildkit/cmd/buildkitd/config"
 )
 
@@ -44,3 +46,16 @@ func setNetworkDefaults(cfg *config.NetworkConfig, cniConfigPath string) {
 		cfg.CNIPoolSize = 16
 	}
 }
+
+// insecureRegistries returns the hosts of the registries the engine is
+// configured to reach over plain HTTP.
+func insecureRegistries(cfg *config.Config) []string {
+	hosts := []string{}
+	for host, registry := range cfg.Registries {
+		if registry.PlainHTTP != nil && *registry.PlainHTTP {
+			hosts = append(hosts, host)
+		}
+	}
+	sort.Strings(hosts)
+	return hosts
+}
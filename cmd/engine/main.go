@@ -22,6 +22,7 @@ import (
 	"github.com/containerd/containerd/sys"
 	sddaemon "github.com/coreos/go-systemd/v22/daemon"
 	"github.com/dagger/dagger/engine/cache"
+	"github.com/dagger/dagger/internal/engine"
 	"github.com/dagger/dagger/network"
 	"github.com/docker/docker/pkg/reexec"
 	"github.com/gofrs/flock"
@@ -251,6 +252,12 @@ func main() { //nolint:gocyclo
 			return err
 		}
 
+		// the shim runs internal commands that talk to registries without the
+		// engine's config, so tell it which ones to reach over plain HTTP
+		if err := os.Setenv(engine.InsecureRegistriesEnvName, strings.Join(insecureRegistries(&cfg), ",")); err != nil {
+			return err
+		}
+
 		logrus.SetFormatter(&logrus.TextFormatter{FullTimestamp: true})
 		if cfg.Debug {
 			logrus.SetLevel(logrus.DebugLevel)
@@ -50,9 +50,22 @@ func NewOperatorClient(ctx context.Context, c *bkclient.Client) (_ *dagger.Clien
 	}
 	port := l.Addr().(*net.TCPAddr).Port
 
+	// follow the output of execs for streaming it, and display the rest
 	solveCh := make(chan *bkclient.SolveStatus)
+	displayCh := make(chan *bkclient.SolveStatus)
+	outputsCh := make(chan *bkclient.SolveStatus)
+	execOutputs := core.NewExecOutputs()
+	go execOutputs.Consume(outputsCh)
 	go func() {
-		warn, err := progressui.DisplaySolveStatus(context.TODO(), nil, os.Stdout, solveCh)
+		defer close(displayCh)
+		defer close(outputsCh)
+		for ev := range solveCh {
+			outputsCh <- ev
+			displayCh <- ev
+		}
+	}()
+	go func() {
+		warn, err := progressui.DisplaySolveStatus(context.TODO(), nil, os.Stdout, displayCh)
 		for _, w := range warn {
 			fmt.Fprintf(os.Stdout, "=> %s\n", w.Short)
 		}
@@ -88,13 +101,15 @@ func NewOperatorClient(ctx context.Context, c *bkclient.Client) (_ *dagger.Clien
 			go pipeline.LoadRootLabels(".", "")
 			router := router.New(sessionToken.String())
 			secretStore.SetGateway(gw)
-			gwClient := core.NewGatewayClient(gw, "", nil)
+			gwClient := core.NewGatewayClient(gw, "", nil, execOutputs)
 			coreAPI, err := schema.New(schema.InitializeArgs{
+				SessionContext: ctx,
 				Router:         router,
 				Gateway:        gwClient,
 				BKClient:       c,
 				SolveOpts:      solveOpts,
 				SolveCh:        solveCh,
+				ExecOutputs:    execOutputs,
 				Platform:       *platform,
 				DisableHostRW:  true,
 				EnableServices: true,
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/dagger/dagger/core"
+	"github.com/klauspost/compress/zstd"
+)
+
+var (
+	gzipMagic     = []byte{0x1f, 0x8b}
+	zstdMagic     = []byte{0x28, 0xb5, 0x2f, 0xfd}
+	bzip2Magic    = []byte{0x42, 0x5a, 0x68}
+	zipMagic      = []byte{0x50, 0x4b, 0x03, 0x04}
+	zipEmptyMagic = []byte{0x50, 0x4b, 0x05, 0x06}
+)
+
+// archive packs a directory into an archive, preserving permissions,
+// ownership, timestamps and symlinks.
+func archive(args []string) error {
+	if len(args) != 3 {
+		return fmt.Errorf("usage: archive <compression> <dir> <archive>")
+	}
+
+	compression, root, dest := core.ArchiveCompression(args[0]), args[1], args[2]
+
+	out, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	buf := bufio.NewWriter(out)
+
+	var w archiveWriter
+	switch compression {
+	case core.ArchiveZip:
+		w = &zipArchiveWriter{zip.NewWriter(buf)}
+	case core.ArchiveGzip:
+		w = newTarArchiveWriter(gzip.NewWriter(buf))
+	case core.ArchiveZstd:
+		zw, err := zstd.NewWriter(buf)
+		if err != nil {
+			return err
+		}
+		w = newTarArchiveWriter(zw)
+	case core.ArchiveUncompressed, "":
+		w = newTarArchiveWriter(nopWriteCloser{buf})
+	default:
+		return fmt.Errorf("unknown archive compression %q", compression)
+	}
+
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if err := w.WriteEntry(filepath.ToSlash(rel), p, info); err != nil {
+			return fmt.Errorf("archive %s: %w", rel, err)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// unarchive extracts a tar archive, optionally compressed with gzip, bzip2
+// or zstd, or a zip archive into a directory.
+func unarchive(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: unarchive <archive> <dir>")
+	}
+
+	src, dest := args[0], args[1]
+
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	br := bufio.NewReader(f)
+
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if bytes.HasPrefix(magic, zipMagic) || bytes.HasPrefix(magic, zipEmptyMagic) {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		return extractZip(f, info.Size(), dest)
+	}
+
+	var r io.Reader = br
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		gr, err := gzip.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer gr.Close()
+		r = gr
+	case bytes.HasPrefix(magic, zstdMagic):
+		zr, err := zstd.NewReader(r)
+		if err != nil {
+			return err
+		}
+		defer zr.Close()
+		r = zr
+	case bytes.HasPrefix(magic, bzip2Magic):
+		r = bzip2.NewReader(r)
+	}
+
+	return extractTar(r, dest)
+}
+
+func extractTar(r io.Reader, dest string) error {
+	tr := tar.NewReader(r)
+
+	dirTimes := map[string]time.Time{}
+
+	for i := 0; ; i++ {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			if i == 0 {
+				return fmt.Errorf("not a tar or zip archive: %w", err)
+			}
+			return err
+		}
+
+		target := extractPath(dest, hdr.Name)
+		if target == dest {
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+
+		mode := hdr.FileInfo().Mode()
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+			dirTimes[target] = hdr.ModTime
+		case tar.TypeReg, tar.TypeRegA: //nolint:staticcheck // still written by old archivers
+			if err := extractFile(target, tr); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		case tar.TypeLink:
+			if err := os.Link(extractPath(dest, hdr.Linkname), target); err != nil {
+				return err
+			}
+			continue
+		default:
+			// devices, sockets and pipes have no place in a directory
+			continue
+		}
+
+		if err := os.Lchown(target, hdr.Uid, hdr.Gid); err != nil {
+			return err
+		}
+
+		if mode&fs.ModeSymlink != 0 {
+			continue
+		}
+
+		if err := os.Chmod(target, mode); err != nil {
+			return err
+		}
+
+		if err := os.Chtimes(target, hdr.ModTime, hdr.ModTime); err != nil {
+			return err
+		}
+	}
+
+	// set directory times last, since extracting into them changes them
+	for dir, mtime := range dirTimes {
+		if err := os.Chtimes(dir, mtime, mtime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func extractZip(r io.ReaderAt, size int64, dest string) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("not a tar or zip archive: %w", err)
+	}
+
+	dirTimes := map[string]time.Time{}
+
+	for _, f := range zr.File {
+		target := extractPath(dest, f.Name)
+		if target == dest {
+			continue
+		}
+
+		if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+			return err
+		}
+
+		mode := f.Mode()
+
+		switch {
+		case mode.IsDir():
+			if err := os.MkdirAll(target, 0o755); err != nil {
+				return err
+			}
+			dirTimes[target] = f.Modified
+		case mode&fs.ModeSymlink != 0:
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			linkTarget, err := io.ReadAll(rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+
+			if err := os.Symlink(string(linkTarget), target); err != nil {
+				return err
+			}
+			continue
+		default:
+			rc, err := f.Open()
+			if err != nil {
+				return err
+			}
+			err = extractFile(target, rc)
+			rc.Close()
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := os.Chmod(target, mode); err != nil {
+			return err
+		}
+
+		if err := os.Chtimes(target, f.Modified, f.Modified); err != nil {
+			return err
+		}
+	}
+
+	for dir, mtime := range dirTimes {
+		if err := os.Chtimes(dir, mtime, mtime); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// extractPath returns where to extract an archive entry to, keeping it under
+// dest.
+func extractPath(dest, name string) string {
+	return filepath.Join(dest, filepath.Clean("/"+name))
+}
+
+func extractFile(target string, r io.Reader) error {
+	f, err := os.OpenFile(target, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+type archiveWriter interface {
+	WriteEntry(name, path string, info fs.FileInfo) error
+	Close() error
+}
+
+type tarArchiveWriter struct {
+	tw *tar.Writer
+	w  io.WriteCloser
+}
+
+func newTarArchiveWriter(w io.WriteCloser) *tarArchiveWriter {
+	return &tarArchiveWriter{tw: tar.NewWriter(w), w: w}
+}
+
+func (w *tarArchiveWriter) WriteEntry(name, path string, info fs.FileInfo) error {
+	mode := info.Mode()
+
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    tarMode(mode),
+		ModTime: info.ModTime(),
+	}
+
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		hdr.Uid = int(st.Uid)
+		hdr.Gid = int(st.Gid)
+	}
+
+	switch {
+	case mode.IsDir():
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+	case mode&fs.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		hdr.Typeflag = tar.TypeSymlink
+		hdr.Linkname = target
+	case mode.IsRegular():
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = info.Size()
+	default:
+		// devices, sockets and pipes have no place in an archive
+		return nil
+	}
+
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+
+	if !mode.IsRegular() {
+		return nil
+	}
+
+	return copyFile(w.tw, path)
+}
+
+func (w *tarArchiveWriter) Close() error {
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return w.w.Close()
+}
+
+type zipArchiveWriter struct {
+	zw *zip.Writer
+}
+
+func (w *zipArchiveWriter) WriteEntry(name, path string, info fs.FileInfo) error {
+	mode := info.Mode()
+
+	hdr := &zip.FileHeader{
+		Name:     name,
+		Method:   zip.Deflate,
+		Modified: info.ModTime(),
+	}
+	hdr.SetMode(mode)
+
+	switch {
+	case mode.IsDir():
+		hdr.Name += "/"
+		hdr.Method = zip.Store
+	case mode&fs.ModeSymlink != 0, mode.IsRegular():
+	default:
+		// devices, sockets and pipes have no place in an archive
+		return nil
+	}
+
+	fw, err := w.zw.CreateHeader(hdr)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case mode&fs.ModeSymlink != 0:
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(fw, target)
+		return err
+	case mode.IsRegular():
+		return copyFile(fw, path)
+	default:
+		return nil
+	}
+}
+
+func (w *zipArchiveWriter) Close() error {
+	return w.zw.Close()
+}
+
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// tarMode converts a Go file mode to the unix permission bits stored in a
+// tar header.
+func tarMode(mode fs.FileMode) int64 {
+	bits := int64(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return bits
+}
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+)
+
+// replace replaces the first occurrence of a string in a file, or every
+// occurrence with -all, in place.
+func replace(args []string) error {
+	all := len(args) > 0 && args[0] == "-all"
+	if all {
+		args = args[1:]
+	}
+
+	if len(args) != 3 {
+		return fmt.Errorf("usage: replace [-all] <file> <search> <replacement>")
+	}
+
+	name, search, replacement := args[0], []byte(args[1]), []byte(args[2])
+
+	content, err := os.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Contains(content, search) {
+		return fmt.Errorf("search string %q not found", search)
+	}
+
+	n := 1
+	if all {
+		n = -1
+	}
+
+	return writeInPlace(name, os.O_TRUNC, bytes.Replace(content, search, replacement, n))
+}
+
+// appendFile appends a string to a file, in place.
+func appendFile(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: append <file> <contents>")
+	}
+
+	return writeInPlace(args[0], os.O_APPEND, []byte(args[1]))
+}
+
+// writeInPlace writes to an existing file rather than replacing it, so that it
+// keeps its ownership and its full mode, including setuid, setgid and sticky
+// bits.
+func writeInPlace(name string, flag int, content []byte) error {
+	info, err := os.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(name, os.O_WRONLY|flag, 0)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// NB: writing may clear setuid and setgid bits, so restore them
+	return os.Chmod(name, info.Mode())
+}
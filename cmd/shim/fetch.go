@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/dagger/dagger/core"
+	"github.com/opencontainers/go-digest"
+)
+
+// fetch downloads a URL to a file, sending the given headers and, if one is
+// mounted, the secret Authorization header. If checksum is not empty, the
+// content must match it.
+func fetch(args []string) error {
+	if len(args) < 4 {
+		return fmt.Errorf("usage: fetch <url> <file> <mode> <checksum> [<header>: <value> ...]")
+	}
+
+	url, dest, modeStr, checksumStr, headers := args[0], args[1], args[2], args[3], args[4:]
+
+	mode, err := strconv.ParseUint(modeStr, 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid mode %q: %w", modeStr, err)
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	for _, header := range headers {
+		name, value, ok := strings.Cut(header, ":")
+		if !ok {
+			return fmt.Errorf("invalid header %q", header)
+		}
+		req.Header.Add(name, strings.TrimSpace(value))
+	}
+
+	auth, err := os.ReadFile(core.HTTPAuthHeaderPath)
+	switch {
+	case err == nil:
+		req.Header.Set("Authorization", string(auth))
+	case !os.IsNotExist(err):
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("fetch %s: %s", url, resp.Status)
+	}
+
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+
+	var checksum digest.Digest
+	var digester digest.Digester
+	if checksumStr != "" {
+		checksum, err = digest.Parse(checksumStr)
+		if err != nil {
+			return fmt.Errorf("invalid checksum %q: %w", checksumStr, err)
+		}
+
+		digester = checksum.Algorithm().Digester()
+		w = io.MultiWriter(f, digester.Hash())
+	}
+
+	if _, err := io.Copy(w, resp.Body); err != nil {
+		return fmt.Errorf("fetch %s: %w", url, err)
+	}
+
+	if digester != nil {
+		if actual := digester.Digest(); actual != checksum {
+			return fmt.Errorf("fetch %s: checksum mismatch: expected %s, got %s", url, checksum, actual)
+		}
+	}
+
+	if err := f.Chmod(os.FileMode(mode)); err != nil {
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/dagger/dagger/core"
+)
+
+// gitHistory fetches the history of a remote repository into a bare
+// repository in dir, updating the one fetched there before, and prints the
+// answer to a query about it as JSON.
+func gitHistory(args []string) error {
+	if len(args) < 4 {
+		return fmt.Errorf("usage: git-history <url> <depth> <dir> <query> [<arg> ...]")
+	}
+
+	url, depthStr, dir, query := args[0], args[1], args[2], args[3:]
+
+	depth, err := strconv.Atoi(depthStr)
+	if err != nil {
+		return fmt.Errorf("invalid depth %q: %w", depthStr, err)
+	}
+
+	opts, err := gitFetchOpts(depth)
+	if err != nil {
+		return err
+	}
+
+	history, err := core.FetchGitHistory(context.Background(), dir, url, opts)
+	if err != nil {
+		return err
+	}
+
+	answer, err := history.Query(query...)
+	if err != nil {
+		return err
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(answer)
+}
+
+// gitFetchOpts returns the options for fetching a repository, with the auth
+// secrets mounted by the engine, if any.
+func gitFetchOpts(depth int) (core.GitFetchOpts, error) {
+	opts := core.GitFetchOpts{
+		Depth: depth,
+	}
+
+	token, err := os.ReadFile(core.GitAuthTokenPath)
+	switch {
+	case err == nil:
+		opts.AuthToken = string(token)
+	case !os.IsNotExist(err):
+		return opts, err
+	}
+
+	header, err := os.ReadFile(core.GitAuthHeaderPath)
+	switch {
+	case err == nil:
+		opts.AuthHeader = string(header)
+	case !os.IsNotExist(err):
+		return opts, err
+	}
+
+	return opts, nil
+}
+
+// gitCheckout checks out a commit of a remote repository into dir.
+func gitCheckout(args []string) error {
+	if len(args) != 6 {
+		return fmt.Errorf("usage: git-checkout <url> <depth> <commit> <submodules> <keep git dir> <dir>")
+	}
+
+	url, depthStr, commit, submodulesStr, keepGitDirStr, dir := args[0], args[1], args[2], args[3], args[4], args[5]
+
+	depth, err := strconv.Atoi(depthStr)
+	if err != nil {
+		return fmt.Errorf("invalid depth %q: %w", depthStr, err)
+	}
+
+	submodules, err := strconv.ParseBool(submodulesStr)
+	if err != nil {
+		return fmt.Errorf("invalid submodules %q: %w", submodulesStr, err)
+	}
+
+	keepGitDir, err := strconv.ParseBool(keepGitDirStr)
+	if err != nil {
+		return fmt.Errorf("invalid keep git dir %q: %w", keepGitDirStr, err)
+	}
+
+	opts, err := gitFetchOpts(depth)
+	if err != nil {
+		return err
+	}
+
+	return core.CheckoutGit(context.Background(), dir, url, commit, opts, submodules, keepGitDir)
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cenkalti/backoff/v4"
+	"github.com/dagger/dagger/core"
+)
+
+// healthcheckTimeout is how long a single check may take, following Docker.
+const healthcheckTimeout = 30 * time.Second
+
+// serveHealthcheck runs the healthcheck until it first passes or fails too
+// many times in a row, and then reports its outcome on the healthcheck port
+// until ctx is canceled.
+func serveHealthcheck(ctx context.Context, check core.ContainerHealthcheck, env []string) {
+	outcome := "healthy"
+	if err := runHealthcheck(ctx, check, env); err != nil {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// NB: don't log it; the command may not be run as a service at all
+		outcome = "unhealthy: " + strings.ReplaceAll(err.Error(), "\n", " ")
+	}
+
+	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", core.HealthcheckPort))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "report healthcheck:", err)
+		return
+	}
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+
+		fmt.Fprintln(conn, outcome)
+		conn.Close()
+	}
+}
+
+func runHealthcheck(ctx context.Context, check core.ContainerHealthcheck, env []string) error {
+	interval := time.Duration(check.Interval) * time.Second
+	startedAt := time.Now()
+	startPeriod := time.Duration(check.StartPeriod) * time.Second
+
+	failures := 0
+	for {
+		err := healthcheckOnce(ctx, check, env)
+		if err == nil {
+			return nil
+		}
+
+		if time.Since(startedAt) >= startPeriod {
+			failures++
+			if failures >= check.Retries {
+				return fmt.Errorf("failed %d times in a row: %w", failures, err)
+			}
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func healthcheckOnce(ctx context.Context, check core.ContainerHealthcheck, env []string) error {
+	ctx, cancel := context.WithTimeout(ctx, healthcheckTimeout)
+	defer cancel()
+
+	if check.HTTPPath != "" {
+		url := "http://" + net.JoinHostPort("localhost", strconv.Itoa(check.HTTPPort)) + check.HTTPPath
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+
+		res.Body.Close()
+
+		if res.StatusCode >= 400 {
+			return fmt.Errorf("GET %s: %s", url, res.Status)
+		}
+
+		return nil
+	}
+
+	output := new(bytes.Buffer)
+	cmd := exec.CommandContext(ctx, check.Args[0], check.Args[1:]...)
+	cmd.Env = env
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Run(); err != nil {
+		if output.Len() > 0 {
+			return fmt.Errorf("%s: %w: %s", strings.Join(check.Args, " "), err, strings.TrimSpace(output.String()))
+		}
+		return fmt.Errorf("%s: %w", strings.Join(check.Args, " "), err)
+	}
+
+	return nil
+}
+
+// pollForHealth waits for the healthcheck of the service at addr to report
+// its outcome.
+func pollForHealth(logPrefix, addr string) error {
+	retry := backoff.NewExponentialBackOff()
+	retry.InitialInterval = 100 * time.Millisecond
+	// the healthcheck gives up on its own once it fails too many times, which
+	// can take longer than the default limit with a long start period; this
+	// process is otherwise stopped along with the engine's check
+	retry.MaxElapsedTime = 0
+
+	dialer := net.Dialer{
+		Timeout: time.Second,
+	}
+
+	return backoff.Retry(func() error {
+		conn, err := dialer.Dial("tcp", addr)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s healthcheck pending: %s; elapsed: %s\n", logPrefix, err, retry.GetElapsedTime())
+			return err
+		}
+
+		defer conn.Close()
+
+		outcome, err := bufio.NewReader(conn).ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		outcome = strings.TrimSpace(outcome)
+		if outcome != "healthy" {
+			return backoff.Permanent(errors.New(outcome))
+		}
+
+		return nil
+	}, retry)
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/containerd/containerd/platforms"
+	"github.com/dagger/dagger/core"
+	"github.com/google/go-containerregistry/pkg/authn"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/remote/transport"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// inspect writes a description of an image in a registry to stdout as JSON,
+// without pulling its layers.
+func inspect(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: inspect <ref> <platform>")
+	}
+
+	target, platformStr := args[0], args[1]
+
+	ref, err := parseReference(target)
+	if err != nil {
+		return err
+	}
+
+	platform, err := v1.ParsePlatform(platformStr)
+	if err != nil {
+		return err
+	}
+
+	image := core.RegistryImage{
+		Address:   target,
+		Platforms: []core.PlatformDigest{},
+		Labels:    map[string]string{},
+		Layers:    []core.ImageLayer{},
+	}
+
+	auth, err := registryAuth()
+	if err != nil {
+		return err
+	}
+
+	desc, err := remote.Get(ref, remote.WithAuth(auth))
+	if err != nil {
+		if isNotFound(err, auth) {
+			return json.NewEncoder(os.Stdout).Encode(image)
+		}
+		return err
+	}
+
+	image.Exists = true
+
+	dig := desc.Digest.String()
+	image.Digest = &dig
+
+	var img v1.Image
+	if desc.MediaType.IsIndex() {
+		idx, err := desc.ImageIndex()
+		if err != nil {
+			return err
+		}
+
+		manifest, err := idx.IndexManifest()
+		if err != nil {
+			return err
+		}
+
+		var match *v1.Descriptor
+		for i, m := range manifest.Manifests {
+			// skip attestations and other artifacts without a platform
+			if m.Platform == nil || m.Platform.OS == "unknown" {
+				continue
+			}
+
+			image.Platforms = append(image.Platforms, core.PlatformDigest{
+				Platform: specsPlatform(*m.Platform),
+				Digest:   m.Digest.String(),
+			})
+
+			if match == nil && m.Platform.Satisfies(*platform) {
+				match = &manifest.Manifests[i]
+			}
+		}
+
+		if match == nil {
+			// the image isn't available for the platform, which is worth
+			// knowing rather than failing on
+			return json.NewEncoder(os.Stdout).Encode(image)
+		}
+
+		img, err = idx.Image(match.Digest)
+		if err != nil {
+			return err
+		}
+	} else {
+		img, err = desc.Image()
+		if err != nil {
+			return err
+		}
+	}
+
+	manifestDigest, err := img.Digest()
+	if err != nil {
+		return err
+	}
+
+	config, err := img.ConfigFile()
+	if err != nil {
+		return err
+	}
+
+	configPlatform := v1.Platform{
+		OS:           config.OS,
+		Architecture: config.Architecture,
+		Variant:      config.Variant,
+	}
+	imagePlatform := specsPlatform(configPlatform)
+
+	manifestDigestStr := manifestDigest.String()
+
+	if !desc.MediaType.IsIndex() {
+		image.Platforms = append(image.Platforms, core.PlatformDigest{
+			Platform: imagePlatform,
+			Digest:   manifestDigestStr,
+		})
+
+		if !configPlatform.Satisfies(*platform) {
+			return json.NewEncoder(os.Stdout).Encode(image)
+		}
+	}
+
+	image.Platform = &imagePlatform
+	image.ManifestDigest = &manifestDigestStr
+
+	if config.Config.Labels != nil {
+		image.Labels = config.Config.Labels
+	}
+
+	if !config.Created.IsZero() {
+		created := config.Created.UTC().Format(time.RFC3339)
+		image.Created = &created
+	}
+
+	manifest, err := img.Manifest()
+	if err != nil {
+		return err
+	}
+
+	for _, layer := range manifest.Layers {
+		image.Layers = append(image.Layers, core.ImageLayer{
+			Digest:    layer.Digest.String(),
+			MediaType: string(layer.MediaType),
+			Size:      int(layer.Size),
+		})
+	}
+
+	return json.NewEncoder(os.Stdout).Encode(image)
+}
+
+// isNotFound reports whether a registry error means the image doesn't exist.
+//
+// Docker Hub answers unauthorized rather than not found for a repository that
+// doesn't exist, so anonymous lookups of missing images are reported that way
+// too. With credentials, unauthorized means they were refused.
+func isNotFound(err error, auth authn.Authenticator) bool {
+	var terr *transport.Error
+	if !errors.As(err, &terr) {
+		return false
+	}
+
+	anonymous := auth == authn.Anonymous
+
+	switch terr.StatusCode {
+	case http.StatusNotFound:
+		return true
+	case http.StatusUnauthorized:
+		return anonymous
+	}
+
+	for _, diag := range terr.Errors {
+		switch diag.Code {
+		case transport.ManifestUnknownErrorCode,
+			transport.NameUnknownErrorCode:
+			return true
+		case transport.UnauthorizedErrorCode:
+			return anonymous
+		}
+	}
+
+	return false
+}
+
+// specsPlatform converts a platform to the type the engine uses, normalized
+// the way the engine normalizes its own.
+func specsPlatform(platform v1.Platform) specs.Platform {
+	return platforms.Normalize(specs.Platform{
+		OS:           platform.OS,
+		Architecture: platform.Architecture,
+		Variant:      platform.Variant,
+	})
+}
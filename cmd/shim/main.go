@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net"
@@ -12,6 +13,7 @@ import (
 	"os/signal"
 	"path/filepath"
 	"runtime"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -19,6 +21,7 @@ import (
 	"github.com/cenkalti/backoff/v4"
 	"github.com/dagger/dagger/core"
 	"github.com/dagger/dagger/engine"
+	internalengine "github.com/dagger/dagger/internal/engine"
 	"github.com/dagger/dagger/router"
 	"github.com/google/uuid"
 	"github.com/moby/buildkit/identity"
@@ -27,11 +30,14 @@ import (
 )
 
 const (
-	metaMountPath = "/.dagger_meta_mount"
-	stdinPath     = metaMountPath + "/stdin"
-	exitCodePath  = metaMountPath + "/exitCode"
-	runcPath      = "/usr/local/bin/runc"
-	shimPath      = "/_shim"
+	metaMountPath  = "/.dagger_meta_mount"
+	stdinPath      = metaMountPath + "/stdin"
+	exitCodePath   = metaMountPath + "/exitCode"
+	startedAtPath  = metaMountPath + "/startedAt"
+	finishedAtPath = metaMountPath + "/finishedAt"
+	runcPath       = "/usr/local/bin/runc"
+	shimPath       = "/_shim"
+	caCertsPath    = "/etc/ssl/certs"
 )
 
 var (
@@ -50,7 +56,14 @@ There are two "subcommands" of this binary:
 func main() {
 	if os.Args[0] == shimPath {
 		if _, found := internalEnv("_DAGGER_INTERNAL_COMMAND"); found {
-			os.Exit(internalCommand())
+			code := internalCommand()
+			if _, found := internalEnv(core.DebugFailedExecEnv); found {
+				// internal commands don't save their output, so the engine
+				// collects it by running them again; succeed so that it keeps
+				// what they printed
+				code = 0
+			}
+			os.Exit(code)
 			return
 		}
 
@@ -79,6 +92,78 @@ func internalCommand() int {
 			return 1
 		}
 		return 0
+	case "symlink":
+		if err := symlink(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "replace":
+		if err := replace(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "append":
+		if err := appendFile(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "archive":
+		if err := archive(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "unarchive":
+		if err := unarchive(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "fetch":
+		if err := fetch(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "git-checkout":
+		if err := gitCheckout(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "git-history":
+		if err := gitHistory(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "push":
+		if err := push(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "inspect":
+		if err := inspect(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "tunnel":
+		if err := tunnel(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	case "proxy":
+		if err := proxy(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
 	default:
 		fmt.Fprintf(os.Stderr, "unknown command: %s\n", cmd)
 		return 1
@@ -87,7 +172,7 @@ func internalCommand() int {
 
 func check(args []string) error {
 	if len(args) == 0 {
-		return fmt.Errorf("usage: check <host> port/tcp [port/udp ...]")
+		return fmt.Errorf("usage: check <host> port/tcp [port/udp ...] [port/health]")
 	}
 
 	logPrefix := fmt.Sprintf("[check %s]", identity.NewID())
@@ -102,6 +187,17 @@ func check(args []string) error {
 
 		pollAddr := net.JoinHostPort(host, port)
 
+		if network == "health" {
+			fmt.Println(logPrefix, "waiting for healthcheck", pollAddr)
+
+			if err := pollForHealth(logPrefix, pollAddr); err != nil {
+				return fmt.Errorf("healthcheck: %w", err)
+			}
+
+			fmt.Println(logPrefix, "healthcheck passed")
+			continue
+		}
+
 		fmt.Println(logPrefix, "polling for port", pollAddr)
 
 		reached, err := pollForPort(logPrefix, network, pollAddr)
@@ -115,6 +211,43 @@ func check(args []string) error {
 	return nil
 }
 
+func tunnel(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: tunnel <host:port>")
+	}
+
+	conn, err := net.Dial("tcp", args[0])
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	go func() {
+		_, _ = io.Copy(conn, os.Stdin)
+
+		// let the backend know the client is done sending
+		_ = conn.(*net.TCPConn).CloseWrite()
+	}()
+
+	_, err = io.Copy(os.Stdout, conn)
+	return err
+}
+
+func symlink(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: symlink <target> <link>")
+	}
+
+	target, link := args[0], args[1]
+
+	if err := os.MkdirAll(filepath.Dir(link), 0o755); err != nil {
+		return err
+	}
+
+	return os.Symlink(target, link)
+}
+
 func pollForPort(logPrefix, network, addr string) (string, error) {
 	retry := backoff.NewExponentialBackOff()
 	retry.InitialInterval = 100 * time.Millisecond
@@ -168,7 +301,25 @@ func shim() int {
 		return 1
 	}
 
-	cmd := exec.Command(name, args...)
+	cmdCtx := ctx
+	var timeout time.Duration
+	if val, found := internalEnv("_DAGGER_EXEC_TIMEOUT"); found {
+		secs, err := strconv.Atoi(val)
+		if err != nil {
+			panic(fmt.Errorf("invalid timeout: %w", err))
+		}
+
+		timeout = time.Duration(secs) * time.Second
+
+		var cancelTimeout context.CancelFunc
+		cmdCtx, cancelTimeout = context.WithTimeout(ctx, timeout)
+		defer cancelTimeout()
+	}
+
+	cmd := exec.CommandContext(cmdCtx, name, args...)
+	if timeout > 0 {
+		killProcessGroupOnCancel(cmd)
+	}
 
 	if stdinFile, err := os.Open(stdinPath); err == nil {
 		defer stdinFile.Close()
@@ -209,6 +360,11 @@ func shim() int {
 		return 0
 	}
 
+	expect := core.ExecExpectSuccess
+	if val, found := internalEnv("_DAGGER_EXPECT"); found {
+		expect = core.ExecExpect(val)
+	}
+
 	var secretsToScrub core.SecretToScrubInfo
 
 	secretsToScrubVar, found := internalEnv("_DAGGER_SCRUB_SECRETS")
@@ -219,11 +375,23 @@ func shim() int {
 		}
 	}
 
+	var healthcheck *core.ContainerHealthcheck
+	if val, found := internalEnv(core.HealthcheckEnv); found {
+		healthcheck = new(core.ContainerHealthcheck)
+		if err := json.Unmarshal([]byte(val), healthcheck); err != nil {
+			panic(fmt.Errorf("cannot load healthcheck: %w", err))
+		}
+	}
+
 	cmd.Env = os.Environ()
 
 	// append nesting envs if any
 	cmd.Env = append(cmd.Env, env...)
 
+	if healthcheck != nil {
+		go serveHealthcheck(ctx, *healthcheck, cmd.Env)
+	}
+
 	currentDirPath := "/"
 	shimFS := os.DirFS(currentDirPath)
 
@@ -253,6 +421,10 @@ func shim() int {
 	}
 	cmd.Stderr = scrubErrWriter
 
+	if err := writeTimestamp(startedAtPath); err != nil {
+		panic(err)
+	}
+
 	exitCode := 0
 	if err := cmd.Run(); err != nil {
 		exitCode = 1
@@ -261,11 +433,65 @@ func shim() int {
 		}
 	}
 
+	if errors.Is(cmdCtx.Err(), context.DeadlineExceeded) {
+		fmt.Fprintf(os.Stderr, "command timed out after %s\n", timeout)
+		exitCode = timeoutExitCode
+	}
+
+	if err := writeTimestamp(finishedAtPath); err != nil {
+		panic(err)
+	}
+
 	if err := os.WriteFile(exitCodePath, []byte(fmt.Sprintf("%d", exitCode)), 0o600); err != nil {
 		panic(err)
 	}
 
-	return exitCode
+	return expectedExitCode(expect, exitCode)
+}
+
+// timeoutExitCode is the exit code recorded for commands that time out,
+// following timeout(1).
+const timeoutExitCode = 124
+
+// killWaitDelay is how long to wait for a killed command's output to be
+// closed, in case it was inherited by a process that outlived it.
+const killWaitDelay = 10 * time.Second
+
+// killProcessGroupOnCancel kills the command and any processes it spawned
+// once its context is canceled.
+func killProcessGroupOnCancel(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+
+	cmd.SysProcAttr.Setpgid = true
+
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+
+	cmd.WaitDelay = killWaitDelay
+}
+
+func writeTimestamp(path string) error {
+	return os.WriteFile(path, []byte(time.Now().UTC().Format(time.RFC3339Nano)), 0o600)
+}
+
+// expectedExitCode returns the exit code the shim should exit with so that the
+// exec only fails when the command's exit code doesn't meet the expectation.
+func expectedExitCode(expect core.ExecExpect, exitCode int) int {
+	switch expect {
+	case core.ExecExpectAny:
+		return 0
+	case core.ExecExpectFailure:
+		if exitCode == 0 {
+			fmt.Fprintln(os.Stderr, "expected command to fail, but it exited 0")
+			return 1
+		}
+		return 0
+	default:
+		return exitCode
+	}
 }
 
 func setupBundle() int {
@@ -299,6 +525,11 @@ func setupBundle() int {
 		return 1
 	}
 
+	if err := loadSecretEnvs(&spec); err != nil {
+		fmt.Fprintln(os.Stderr, "secret env:", err)
+		return 1
+	}
+
 	// Check to see if this is a dagger exec, currently by using
 	// the presence of the dagger meta mount. If it is, set up the
 	// shim to be invoked as the init process. Otherwise, just
@@ -311,13 +542,39 @@ func setupBundle() int {
 		}
 	}
 	// We're running an internal shim command, i.e. a service health check
+	var isInternalCommand bool
 	for _, env := range spec.Process.Env {
 		if strings.HasPrefix(env, "_DAGGER_INTERNAL_COMMAND=") {
 			isDaggerExec = true
+			isInternalCommand = true
 			break
 		}
 	}
 
+	if isInternalCommand && len(spec.Process.Args) > 0 {
+		cmd := spec.Process.Args[0]
+
+		switch cmd {
+		case "push", "inspect", "fetch", "git-history", "git-checkout":
+			// internal commands run on scratch, so give the ones that make
+			// requests the engine's CA certificates
+			if _, err := os.Stat(caCertsPath); err == nil {
+				spec.Mounts = append(spec.Mounts, specs.Mount{
+					Destination: caCertsPath,
+					Type:        "bind",
+					Source:      caCertsPath,
+					Options:     []string{"rbind", "ro"},
+				})
+			}
+		}
+
+		switch cmd {
+		case "push", "inspect":
+			spec.Process.Env = append(spec.Process.Env,
+				internalengine.InsecureRegistriesEnvName+"="+os.Getenv(internalengine.InsecureRegistriesEnvName))
+		}
+	}
+
 	if isDaggerExec {
 		// mount this executable into the container so it can be invoked as the shim
 		selfPath, err := os.Executable()
@@ -370,6 +627,12 @@ func setupBundle() int {
 				fmt.Fprintln(os.Stderr, "host alias:", err)
 				return 1
 			}
+		case strings.HasPrefix(env, resourceLimitPrefix):
+			// NB: don't keep this env var, it's only for the bundling step
+			if err := applyResourceLimit(&spec, env); err != nil {
+				fmt.Fprintln(os.Stderr, "resource limit:", err)
+				return 1
+			}
 		default:
 			keepEnv = append(keepEnv, env)
 		}
@@ -462,6 +725,82 @@ func appendHostAlias(hostsFilePath string, env string) error {
 	return hostsFile.Close()
 }
 
+// loadSecretEnvs sets the secrets mounted under core.SecretEnvMountDir as
+// env vars named after their files, in place of the mounts.
+func loadSecretEnvs(spec *specs.Spec) error {
+	mounts := []specs.Mount{}
+	for _, mnt := range spec.Mounts {
+		if filepath.Dir(mnt.Destination) != core.SecretEnvMountDir {
+			mounts = append(mounts, mnt)
+			continue
+		}
+
+		val, err := os.ReadFile(mnt.Source)
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Base(mnt.Destination)
+		spec.Process.Env = append(spec.Process.Env, name+"="+string(val))
+	}
+
+	spec.Mounts = mounts
+
+	return nil
+}
+
+const resourceLimitPrefix = "_DAGGER_LIMIT_"
+
+// cpuPeriod is the CFS period CPU quotas are relative to, in microseconds.
+const cpuPeriod uint64 = 100000
+
+func applyResourceLimit(spec *specs.Spec, env string) error {
+	name, val, ok := strings.Cut(strings.TrimPrefix(env, resourceLimitPrefix), "=")
+	if !ok {
+		return fmt.Errorf("malformed resource limit: %s", env)
+	}
+
+	limit, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return fmt.Errorf("parse %s limit: %w", name, err)
+	}
+
+	if spec.Linux == nil {
+		spec.Linux = &specs.Linux{}
+	}
+	if spec.Linux.Resources == nil {
+		spec.Linux.Resources = &specs.LinuxResources{}
+	}
+	res := spec.Linux.Resources
+
+	switch name {
+	case "MEMORY":
+		if res.Memory == nil {
+			res.Memory = &specs.LinuxMemory{}
+		}
+		res.Memory.Limit = &limit
+	case "CPU_SHARES":
+		if res.CPU == nil {
+			res.CPU = &specs.LinuxCPU{}
+		}
+		shares := uint64(limit)
+		res.CPU.Shares = &shares
+	case "CPU_QUOTA":
+		if res.CPU == nil {
+			res.CPU = &specs.LinuxCPU{}
+		}
+		period := cpuPeriod
+		res.CPU.Quota = &limit
+		res.CPU.Period = &period
+	case "PIDS":
+		res.Pids = &specs.LinuxPids{Limit: limit}
+	default:
+		return fmt.Errorf("unknown resource limit: %s", name)
+	}
+
+	return nil
+}
+
 // nolint: unparam
 func execRunc() int {
 	args := []string{runcPath}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dagger/dagger/core"
+	"github.com/opencontainers/runtime-spec/specs-go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpectedExitCode(t *testing.T) {
+	for _, tc := range []struct {
+		expect   core.ExecExpect
+		exitCode int
+		want     int
+	}{
+		{core.ExecExpectSuccess, 0, 0},
+		{core.ExecExpectSuccess, 3, 3},
+		{core.ExecExpectFailure, 0, 1},
+		{core.ExecExpectFailure, 3, 0},
+		{core.ExecExpectAny, 0, 0},
+		{core.ExecExpectAny, 3, 0},
+	} {
+		require.Equal(t, tc.want, expectedExitCode(tc.expect, tc.exitCode), "expect %s, exit code %d", tc.expect, tc.exitCode)
+	}
+}
+
+func TestApplyResourceLimit(t *testing.T) {
+	spec := &specs.Spec{}
+
+	for _, env := range []string{
+		"_DAGGER_LIMIT_MEMORY=536870912",
+		"_DAGGER_LIMIT_CPU_SHARES=512",
+		"_DAGGER_LIMIT_CPU_QUOTA=50000",
+		"_DAGGER_LIMIT_PIDS=100",
+	} {
+		require.NoError(t, applyResourceLimit(spec, env))
+	}
+
+	res := spec.Linux.Resources
+	require.Equal(t, int64(536870912), *res.Memory.Limit)
+	require.Equal(t, uint64(512), *res.CPU.Shares)
+	require.Equal(t, int64(50000), *res.CPU.Quota)
+	require.Equal(t, cpuPeriod, *res.CPU.Period)
+	require.Equal(t, int64(100), res.Pids.Limit)
+
+	require.Error(t, applyResourceLimit(spec, "_DAGGER_LIMIT_MEMORY=lots"))
+	require.Error(t, applyResourceLimit(spec, "_DAGGER_LIMIT_BOGUS=1"))
+	require.Error(t, applyResourceLimit(spec, "_DAGGER_LIMIT_PIDS"))
+}
+
+func TestSymlink(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(dir, "sub", "link")
+
+	require.NoError(t, symlink([]string{"../target", link}))
+
+	target, err := os.Readlink(link)
+	require.NoError(t, err)
+	require.Equal(t, "../target", target)
+
+	require.Error(t, symlink([]string{"../target", link}))
+	require.Error(t, symlink([]string{"too-few"}))
+}
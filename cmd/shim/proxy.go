@@ -0,0 +1,165 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/dagger/dagger/network"
+	"golang.org/x/sync/errgroup"
+)
+
+// proxy listens on ports in the container and forwards their traffic to
+// Unix sockets, which the session forwards to ports on the host.
+//
+// UDP datagrams are framed with network.WriteDatagram, one stream per client
+// address.
+func proxy(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: proxy port/tcp=<socket> [port/udp=<socket> ...]")
+	}
+
+	eg := new(errgroup.Group)
+	for _, arg := range args {
+		port, sockPath, ok := strings.Cut(arg, "=")
+		if !ok {
+			return fmt.Errorf("invalid forward %q: expected port/protocol=socket", arg)
+		}
+
+		port, proto, ok := strings.Cut(port, "/")
+		if !ok {
+			proto = "tcp"
+		}
+
+		addr := ":" + port
+
+		switch proto {
+		case "tcp":
+			ln, err := net.Listen("tcp", addr)
+			if err != nil {
+				return err
+			}
+
+			eg.Go(func() error {
+				return proxyStreams(ln, sockPath)
+			})
+		case "udp":
+			pc, err := net.ListenPacket("udp", addr)
+			if err != nil {
+				return err
+			}
+
+			eg.Go(func() error {
+				return proxyDatagrams(pc, sockPath)
+			})
+		default:
+			return fmt.Errorf("unsupported protocol %q", proto)
+		}
+
+		fmt.Println("forwarding", port+"/"+proto)
+	}
+
+	return eg.Wait()
+}
+
+func proxyStreams(ln net.Listener, sockPath string) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			defer conn.Close()
+
+			upstream, err := net.Dial("unix", sockPath)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "dial host:", err)
+				return
+			}
+
+			defer upstream.Close()
+
+			pipeConns(conn, upstream)
+		}()
+	}
+}
+
+func proxyDatagrams(pc net.PacketConn, sockPath string) error {
+	var mu sync.Mutex
+	streams := map[string]net.Conn{}
+
+	buf := make([]byte, network.MaxDatagramSize)
+	for {
+		n, addr, err := pc.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		stream, found := streams[addr.String()]
+		if !found {
+			stream, err = net.Dial("unix", sockPath)
+			if err != nil {
+				mu.Unlock()
+				fmt.Fprintln(os.Stderr, "dial host:", err)
+				continue
+			}
+
+			streams[addr.String()] = stream
+
+			go func() {
+				defer func() {
+					mu.Lock()
+					delete(streams, addr.String())
+					mu.Unlock()
+
+					stream.Close()
+				}()
+
+				replyBuf := make([]byte, network.MaxDatagramSize)
+				for {
+					p, err := network.ReadDatagram(stream, replyBuf)
+					if err != nil {
+						return
+					}
+
+					if _, err := pc.WriteTo(p, addr); err != nil {
+						return
+					}
+				}
+			}()
+		}
+		mu.Unlock()
+
+		if err := network.WriteDatagram(stream, buf[:n]); err != nil {
+			fmt.Fprintln(os.Stderr, "forward datagram:", err)
+		}
+	}
+}
+
+// pipeConns copies between both conns until each side is done writing.
+func pipeConns(a, b net.Conn) {
+	wg := new(sync.WaitGroup)
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(b, a)
+		closeWrite(b)
+	}()
+	go func() {
+		defer wg.Done()
+		_, _ = io.Copy(a, b)
+		closeWrite(a)
+	}()
+	wg.Wait()
+}
+
+func closeWrite(conn net.Conn) {
+	if cw, ok := conn.(interface{ CloseWrite() error }); ok {
+		_ = cw.CloseWrite()
+	}
+}
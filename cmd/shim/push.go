@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/dagger/dagger/core"
+	internalengine "github.com/dagger/dagger/internal/engine"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+)
+
+// push pushes the single image of an OCI layout to a registry, for artifacts
+// like image signatures that buildkit's exporter can't push.
+func push(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: push <layout> <ref>")
+	}
+
+	layoutPath, target := args[0], args[1]
+
+	ref, err := parseReference(target)
+	if err != nil {
+		return err
+	}
+
+	auth, err := registryAuth()
+	if err != nil {
+		return err
+	}
+
+	idx, err := layout.ImageIndexFromPath(layoutPath)
+	if err != nil {
+		return err
+	}
+
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return err
+	}
+
+	if len(manifest.Manifests) != 1 {
+		return fmt.Errorf("expected one image in layout, got %d", len(manifest.Manifests))
+	}
+
+	img, err := idx.Image(manifest.Manifests[0].Digest)
+	if err != nil {
+		return err
+	}
+
+	return remote.Write(ref, img, remote.WithAuth(auth))
+}
+
+// parseReference parses an image reference, allowing plain HTTP only for the
+// registries the engine is configured to reach over it.
+func parseReference(target string) (name.Reference, error) {
+	ref, err := name.ParseReference(target)
+	if err != nil {
+		return nil, err
+	}
+
+	insecure, _ := internalEnv(internalengine.InsecureRegistriesEnvName)
+	for _, host := range strings.Split(insecure, ",") {
+		if host != "" && host == ref.Context().RegistryStr() {
+			return name.ParseReference(target, name.Insecure)
+		}
+	}
+
+	return ref, nil
+}
+
+// registryAuth returns the registry credentials mounted by the engine, if
+// any.
+func registryAuth() (authn.Authenticator, error) {
+	username, err := os.ReadFile(core.RegistryUsernamePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return authn.Anonymous, nil
+		}
+		return nil, err
+	}
+
+	password, err := os.ReadFile(core.RegistryPasswordPath)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	return authn.FromConfig(authn.AuthConfig{
+		Username: string(username),
+		Password: string(password),
+	}), nil
+}
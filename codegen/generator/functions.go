@@ -19,6 +19,7 @@ var CustomScalar = map[string]string{
 	"SecretID":    "Secret",
 	"SocketID":    "Socket",
 	"CacheID":     "CacheVolume",
+	"ServiceID":   "Service",
 }
 
 // FormatTypeFuncs is an interface to format any GraphQL type.
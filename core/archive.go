@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path"
+
+	"github.com/moby/buildkit/client/llb"
+)
+
+// ArchiveCompression is the format of an archive created from a directory.
+type ArchiveCompression string
+
+const (
+	ArchiveUncompressed ArchiveCompression = "UNCOMPRESSED"
+	ArchiveGzip         ArchiveCompression = "GZIP"
+	ArchiveZstd         ArchiveCompression = "ZSTD"
+	ArchiveZip          ArchiveCompression = "ZIP"
+)
+
+// Filename returns the name given to an archive of this format.
+func (compression ArchiveCompression) Filename() (string, error) {
+	switch compression {
+	case ArchiveUncompressed, "":
+		return "archive.tar", nil
+	case ArchiveGzip:
+		return "archive.tar.gz", nil
+	case ArchiveZstd:
+		return "archive.tar.zst", nil
+	case ArchiveZip:
+		return "archive.zip", nil
+	default:
+		return "", fmt.Errorf("unknown archive compression %q", compression)
+	}
+}
+
+// AsTarball packs the directory into an archive, preserving permissions,
+// ownership, timestamps and symlinks.
+func (dir *Directory) AsTarball(ctx context.Context, compression ArchiveCompression) (*File, error) {
+	name, err := compression.Filename()
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := dir.State()
+	if err != nil {
+		return nil, err
+	}
+
+	exec := llb.Scratch().Run(
+		llb.Args([]string{
+			"archive",
+			string(compression),
+			path.Join(internalMountPath, dir.Dir),
+			path.Join(internalOutputPath, name),
+		}),
+		llb.AddEnv("_DAGGER_INTERNAL_COMMAND", ""),
+		llb.Network(llb.NetModeNone),
+		dir.Pipeline.LLBOpt(),
+		llb.WithCustomNamef("archive %s as %s", dir.Dir, name),
+	)
+	exec.AddMount(internalMountPath, src, llb.Readonly)
+	st := exec.AddMount(internalOutputPath, llb.Scratch())
+
+	return NewFile(ctx, st, name, dir.Pipeline, dir.Platform, dir.Services)
+}
+
+// Unpack extracts the file, which must be a tar archive (optionally
+// compressed with gzip, bzip2 or zstd) or a zip archive, into a directory.
+func (file *File) Unpack(ctx context.Context) (*Directory, error) {
+	src, err := file.State()
+	if err != nil {
+		return nil, err
+	}
+
+	exec := llb.Scratch().Run(
+		llb.Args([]string{
+			"unarchive",
+			path.Join(internalMountPath, file.File),
+			internalOutputPath,
+		}),
+		llb.AddEnv("_DAGGER_INTERNAL_COMMAND", ""),
+		llb.Network(llb.NetModeNone),
+		file.Pipeline.LLBOpt(),
+		llb.WithCustomNamef("unpack %s", path.Base(file.File)),
+	)
+	exec.AddMount(internalMountPath, src, llb.Readonly)
+	st := exec.AddMount(internalOutputPath, llb.Scratch())
+
+	return NewDirectory(ctx, st, "", file.Pipeline, file.Platform, file.Services)
+}
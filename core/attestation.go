@@ -0,0 +1,110 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	gatewaypb "github.com/moby/buildkit/frontend/gateway/pb"
+	"github.com/moby/buildkit/solver/pb"
+	bkresult "github.com/moby/buildkit/solver/result"
+)
+
+// ContainerAttestation is an in-toto attestation attached to a container's
+// image when it is published or exported.
+type ContainerAttestation struct {
+	// The type of the attestation's predicate.
+	PredicateType string `json:"predicate_type"`
+
+	// The file containing the predicate.
+	Source *pb.Definition `json:"source"`
+	Path   string         `json:"path"`
+}
+
+// WithAttestation attaches the predicate in file to the container's image as
+// an in-toto statement about it.
+func (container *Container) WithAttestation(ctx context.Context, predicateType string, file *File) (*Container, error) {
+	container = container.Clone()
+
+	if predicateType == "" {
+		return nil, fmt.Errorf("predicate type must not be empty")
+	}
+
+	container.Attestations = append(container.Attestations, ContainerAttestation{
+		PredicateType: predicateType,
+		Source:        file.LLB,
+		Path:          file.File,
+	})
+
+	container.Services.Merge(file.Services)
+
+	return container, nil
+}
+
+// WithSBOM attaches an SBOM of the container's rootfs, generated when its
+// image is published or exported.
+func (container *Container) WithSBOM(ctx context.Context, format SBOMFormat) (*Container, error) {
+	container = container.Clone()
+
+	if _, err := format.filename(); err != nil {
+		return nil, err
+	}
+
+	if format == "" {
+		format = SBOMSPDX
+	}
+
+	container.AttachedSBOM = format
+
+	return container, nil
+}
+
+// hasAttestations returns whether the container's image has attestations
+// attached.
+func (container *Container) hasAttestations() bool {
+	return len(container.Attestations) > 0 || container.AttachedSBOM != ""
+}
+
+// addAttestations solves the container's attestations and adds them to the
+// result for the given platform.
+func (container *Container) addAttestations(ctx context.Context, gw bkgw.Client, res *bkgw.Result, platformKey string) error {
+	attestations := container.Attestations
+	if container.AttachedSBOM != "" {
+		sbom, err := container.SBOM(ctx, gw, container.AttachedSBOM)
+		if err != nil {
+			return err
+		}
+
+		attestations = append(clone(attestations), ContainerAttestation{
+			PredicateType: container.AttachedSBOM.PredicateType(),
+			Source:        sbom.LLB,
+			Path:          sbom.File,
+		})
+	}
+
+	for _, att := range attestations {
+		r, err := gw.Solve(ctx, bkgw.SolveRequest{
+			Evaluate:   true,
+			Definition: att.Source,
+		})
+		if err != nil {
+			return err
+		}
+
+		ref, err := r.SingleRef()
+		if err != nil {
+			return err
+		}
+
+		res.AddAttestation(platformKey, bkgw.Attestation{
+			Kind: gatewaypb.AttestationKindInToto,
+			Ref:  ref,
+			Path: att.Path,
+			InToto: bkresult.InTotoAttestation{
+				PredicateType: att.PredicateType,
+			},
+		})
+	}
+
+	return nil
+}
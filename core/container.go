@@ -20,8 +20,10 @@ import (
 	"github.com/containerd/containerd/images"
 	"github.com/containerd/containerd/pkg/transfer/archive"
 	"github.com/containerd/containerd/platforms"
+	"github.com/dagger/dagger/auth"
 	"github.com/dagger/dagger/core/pipeline"
 	"github.com/docker/distribution/reference"
+	units "github.com/docker/go-units"
 	bkclient "github.com/moby/buildkit/client"
 	"github.com/moby/buildkit/client/llb"
 	"github.com/moby/buildkit/exporter/containerimage/exptypes"
@@ -69,9 +71,33 @@ type Container struct {
 	// Ports to expose from the container.
 	Ports []ContainerPort `json:"ports,omitempty"`
 
+	// Healthcheck to run alongside services started from the container.
+	Healthcheck *ContainerHealthcheck `json:"healthcheck,omitempty"`
+
+	// Whether the healthcheck is attached to the last command, as it is once
+	// the container is started as a service.
+	Healthchecked bool `json:"healthchecked,omitempty"`
+
+	// Mirrors to pull images from, on top of the session's, as configured
+	// by the query the container was created in.
+	RegistryMirrors auth.RegistryMirrors `json:"registry_mirrors,omitempty"`
+
 	// Services to start before running the container.
 	Services    ServiceBindings `json:"services,omitempty"`
 	HostAliases []HostAlias     `json:"host_aliases,omitempty"`
+
+	// Attestations to attach to the container's image.
+	Attestations []ContainerAttestation `json:"attestations,omitempty"`
+
+	// Format of an SBOM to generate and attach to the container's image.
+	AttachedSBOM SBOMFormat `json:"attached_sbom,omitempty"`
+
+	// History of the image's layers, up to BaseFS.
+	History []specs.History `json:"history,omitempty"`
+
+	// The root filesystem as of the last history entry. Layers added since
+	// are squashed on top of it.
+	BaseFS *pb.Definition `json:"base_fs,omitempty"`
 }
 
 func NewContainer(id ContainerID, pipeline pipeline.Path, platform specs.Platform) (*Container, error) {
@@ -95,8 +121,11 @@ func (container *Container) Clone() *Container {
 	cp.Sockets = clone(cp.Sockets)
 	cp.Ports = clone(cp.Ports)
 	cp.Services = cloneMap(cp.Services)
+	cp.RegistryMirrors = cloneMap(cp.RegistryMirrors)
 	cp.HostAliases = clone(cp.HostAliases)
 	cp.Pipeline = clone(cp.Pipeline)
+	cp.Attestations = clone(cp.Attestations)
+	cp.History = clone(cp.History)
 	return &cp
 }
 
@@ -178,6 +207,11 @@ func (container *Container) FSState() (llb.State, error) {
 	return defToState(container.FS)
 }
 
+// SecretEnvMountDir is where secrets are mounted for the shim to set them as
+// env vars of processes in gateway containers, which can't be given secret
+// env vars directly.
+const SecretEnvMountDir = "/run/dagger/secret-env"
+
 // metaMountDestPath is the special path that the shim writes metadata to.
 const metaMountDestPath = "/.dagger_meta_mount"
 
@@ -254,9 +288,30 @@ func (mnts ContainerMounts) With(newMnt ContainerMount) ContainerMounts {
 type PipelineMetaResolver struct {
 	Resolver llb.ImageMetaResolver
 	Pipeline pipeline.Path
+
+	// Mirrors rewrites refs to pull from the mirrors configured for their
+	// registries, if set.
+	Mirrors *auth.RegistryAuthProvider
+
+	// ScopedMirrors take precedence over the session's mirrors.
+	ScopedMirrors auth.RegistryMirrors
+}
+
+// PullRef returns the ref to pull an image from.
+func (r PipelineMetaResolver) PullRef(ref string) (string, error) {
+	if r.Mirrors == nil {
+		return ref, nil
+	}
+
+	return r.Mirrors.MirrorRef(ref, r.ScopedMirrors)
 }
 
 func (r PipelineMetaResolver) ResolveImageConfig(ctx context.Context, ref string, opt llb.ResolveImageConfigOpt) (digest.Digest, []byte, error) {
+	pullRef, err := r.PullRef(ref)
+	if err != nil {
+		return "", nil, err
+	}
+
 	// FIXME: `ResolveImageConfig` doesn't support progress groups. As a workaround, we inject
 	// the pipeline in the vertex name.
 	opt.LogName = pipeline.CustomName{
@@ -264,10 +319,10 @@ func (r PipelineMetaResolver) ResolveImageConfig(ctx context.Context, ref string
 		Pipeline: r.Pipeline,
 	}.String()
 
-	return r.Resolver.ResolveImageConfig(ctx, ref, opt)
+	return r.Resolver.ResolveImageConfig(ctx, pullRef, opt)
 }
 
-func (container *Container) From(ctx context.Context, gw bkgw.Client, addr string) (*Container, error) {
+func (container *Container) From(ctx context.Context, gw bkgw.Client, addr string, mirrors *auth.RegistryAuthProvider) (*Container, error) {
 	container = container.Clone()
 
 	platform := container.Platform
@@ -286,8 +341,10 @@ func (container *Container) From(ctx context.Context, gw bkgw.Client, addr strin
 	ref := reference.TagNameOnly(refName).String()
 
 	resolver := PipelineMetaResolver{
-		Resolver: gw,
-		Pipeline: p,
+		Resolver:      gw,
+		Pipeline:      p,
+		Mirrors:       mirrors,
+		ScopedMirrors: container.RegistryMirrors,
 	}
 
 	digest, cfgBytes, err := resolver.ResolveImageConfig(ctx, ref, llb.ResolveImageConfigOpt{
@@ -308,8 +365,23 @@ func (container *Container) From(ctx context.Context, gw bkgw.Client, addr strin
 		return nil, err
 	}
 
+	pullRef, err := resolver.PullRef(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	pullName, err := reference.ParseNormalizedNamed(pullRef)
+	if err != nil {
+		return nil, err
+	}
+
+	pullDigested, err := reference.WithDigest(pullName, digest)
+	if err != nil {
+		return nil, err
+	}
+
 	fsSt := llb.Image(
-		digested.String(),
+		pullDigested.String(),
 		llb.WithCustomNamef("pull %s", ref),
 		p.LLBOpt(),
 	)
@@ -325,6 +397,9 @@ func (container *Container) From(ctx context.Context, gw bkgw.Client, addr strin
 	imgSpec.Config.Env = append(container.Config.Env, imgSpec.Config.Env...)
 	container.Config = imgSpec.Config
 
+	container.History = imgSpec.History
+	container.BaseFS = container.FS
+
 	container.ImageRef = digested.String()
 
 	return container, nil
@@ -413,6 +488,8 @@ func (container *Container) Build(ctx context.Context, gw bkgw.Client, context *
 		}))
 
 		container.FS = def.ToPB()
+		container.BaseFS = container.FS
+		container.History = nil
 
 		cfgBytes, found := res.Metadata[exptypes.ExporterImageConfigKey]
 		if found {
@@ -422,6 +499,7 @@ func (container *Container) Build(ctx context.Context, gw bkgw.Client, context *
 			}
 
 			container.Config = imgSpec.Config
+			container.History = imgSpec.History
 		}
 
 		return container, nil
@@ -439,6 +517,91 @@ func (container *Container) RootFS(ctx context.Context) (*Directory, error) {
 }
 
 func (container *Container) WithRootFS(ctx context.Context, dir *Directory) (*Container, error) {
+	container, err := container.withRootFS(ctx, dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// the new rootfs has layers of its own
+	container.History = nil
+	container.BaseFS = nil
+
+	return container, nil
+}
+
+// Squash merges the layers added since the last history entry, or all of
+// them if there is none, into a single layer.
+func (container *Container) Squash(ctx context.Context) (*Container, error) {
+	container = container.Clone()
+
+	fsSt, err := container.FSState()
+	if err != nil {
+		return nil, err
+	}
+
+	flat := llb.Scratch().File(
+		llb.Copy(fsSt, "/", "/", &llb.CopyInfo{
+			CopyDirContentsOnly: true,
+		}),
+		container.Pipeline.LLBOpt(),
+		llb.WithCustomName("squash layers"),
+	)
+
+	var squashed llb.State
+	if container.BaseFS == nil {
+		squashed = flat
+		container.History = nil
+	} else {
+		baseSt, err := defToState(container.BaseFS)
+		if err != nil {
+			return nil, err
+		}
+
+		// the flattened rootfs doesn't descend from the base, so the diff is
+		// computed as a single layer, deletions included
+		squashed = llb.Merge([]llb.State{
+			baseSt,
+			llb.Diff(baseSt, flat, container.Pipeline.LLBOpt()),
+		}, container.Pipeline.LLBOpt())
+	}
+
+	def, err := squashed.Marshal(ctx, llb.Platform(container.Platform))
+	if err != nil {
+		return nil, err
+	}
+
+	container.FS = def.ToPB()
+	container.BaseFS = container.FS
+
+	return container, nil
+}
+
+// WithHistory squashes the layers added since the last history entry into
+// one layer and describes it with a new entry, as shown by `docker history`.
+//
+// An empty layer entry describes a change to the image config instead, and
+// leaves the layers as they are.
+func (container *Container) WithHistory(ctx context.Context, createdBy, comment string, emptyLayer bool) (*Container, error) {
+	container = container.Clone()
+
+	if !emptyLayer {
+		var err error
+		container, err = container.Squash(ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	container.History = append(container.History, specs.History{
+		CreatedBy:  createdBy,
+		Comment:    comment,
+		EmptyLayer: emptyLayer,
+	})
+
+	return container, nil
+}
+
+func (container *Container) withRootFS(ctx context.Context, dir *Directory) (*Container, error) {
 	container = container.Clone()
 
 	dirSt, err := dir.StateWithSourcePath()
@@ -501,6 +664,60 @@ func (container *Container) WithNewFile(ctx context.Context, gw bkgw.Client, des
 	})
 }
 
+func (container *Container) WithSymlink(ctx context.Context, gw bkgw.Client, target, linkName string) (*Container, error) {
+	container = container.Clone()
+
+	dir, file := filepath.Split(linkName)
+	return container.writeToPath(ctx, gw, dir, func(dir *Directory) (*Directory, error) {
+		return dir.WithSymlink(ctx, target, file)
+	})
+}
+
+func (container *Container) WithRename(ctx context.Context, gw bkgw.Client, from, to string) (*Container, error) {
+	container = container.Clone()
+
+	from = absPath(container.Config.WorkingDir, from)
+	to = absPath(container.Config.WorkingDir, to)
+
+	root := container.mountRoot(from)
+	if from == root {
+		return nil, fmt.Errorf("%s: cannot rename a mount point", from)
+	}
+
+	if toRoot := container.mountRoot(to); toRoot != root {
+		return nil, fmt.Errorf("cannot rename %s to %s: paths are on different mounts", from, to)
+	}
+
+	relFrom, err := filepath.Rel(root, from)
+	if err != nil {
+		return nil, err
+	}
+
+	relTo, err := filepath.Rel(root, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return container.writeToPath(ctx, gw, root, func(dir *Directory) (*Directory, error) {
+		return dir.WithRename(ctx, relFrom, relTo)
+	})
+}
+
+// mountRoot returns the target of the mount containing the given absolute
+// path, or "/" if it's in the rootfs.
+func (container *Container) mountRoot(containerPath string) string {
+	// NB: iterate in reverse order so we'll find deeper mounts first
+	for i := len(container.Mounts) - 1; i >= 0; i-- {
+		mnt := container.Mounts[i]
+
+		if containerPath == mnt.Target || strings.HasPrefix(containerPath, mnt.Target+"/") {
+			return mnt.Target
+		}
+	}
+
+	return "/"
+}
+
 func (container *Container) WithMountedDirectory(ctx context.Context, gw bkgw.Client, target string, dir *Directory, owner string) (*Container, error) {
 	container = container.Clone()
 
@@ -946,7 +1163,7 @@ func (container *Container) writeToPath(ctx context.Context, gw bkgw.Client, sub
 			return nil, err
 		}
 
-		return container.WithRootFS(ctx, root)
+		return container.withRootFS(ctx, root)
 	}
 
 	return container.withMounted(ctx, gw, mount.Target, dir.LLB, mount.SourcePath, nil, "")
@@ -1165,6 +1382,28 @@ func (container *Container) WithExec(ctx context.Context, gw bkgw.Client, defaul
 		runOpts = append(runOpts, llb.Security(llb.SecurityModeInsecure))
 	}
 
+	switch opts.Expect {
+	case "", ExecExpectSuccess:
+		// the shim's default; don't set the env so the cache key is unchanged
+	case ExecExpectFailure, ExecExpectAny:
+		runOpts = append(runOpts, llb.AddEnv("_DAGGER_EXPECT", string(opts.Expect)))
+	default:
+		return nil, fmt.Errorf("invalid exec expectation %q", opts.Expect)
+	}
+
+	limitOpts, err := opts.resourceLimitOpts()
+	if err != nil {
+		return nil, err
+	}
+	runOpts = append(runOpts, limitOpts...)
+
+	if opts.internalCommand {
+		runOpts = append(runOpts, llb.AddEnv("_DAGGER_INTERNAL_COMMAND", ""))
+	}
+
+	// attached when the container is started as a service
+	container.Healthchecked = false
+
 	fsSt, err := container.FSState()
 	if err != nil {
 		return nil, fmt.Errorf("fs state: %w", err)
@@ -1262,12 +1501,25 @@ func (container *Container) ExitCode(ctx context.Context, gw bkgw.Client) (int,
 	return strconv.Atoi(content)
 }
 
-func (container *Container) Start(ctx context.Context, gw bkgw.Client) (*Service, error) {
+func (container *Container) Start(ctx context.Context, gw bkgw.Client) (*RunningService, error) {
 	if container.Hostname == "" {
 		return nil, ErrContainerNoExec
 	}
 
-	health := newHealth(gw, container.Hostname, container.Ports)
+	container, err := container.withHealthcheckAttached()
+	if err != nil {
+		return nil, err
+	}
+
+	health := newHealth(gw, container.Hostname, container.Ports, container.Healthchecked)
+
+	var outputs *ExecOutputs
+	if gwc, ok := gw.(*GatewayClient); ok {
+		outputs = gwc.outputs
+	}
+
+	// keep what the service logs in case it fails to start
+	tail := tailOutput(container, outputs)
 
 	svcCtx, stop := context.WithCancel(context.Background())
 
@@ -1297,24 +1549,152 @@ func (container *Container) Start(ctx context.Context, gw bkgw.Client) (*Service
 	case err := <-checked:
 		if err != nil {
 			stop()
-			return nil, fmt.Errorf("health check errored: %w", err)
+			tail.Finish(ctx)
+			return nil, &ServiceError{
+				Err:      fmt.Errorf("health check errored: %w", err),
+				ExitCode: -1,
+				Logs:     tail.Lines(serviceErrorLogLines),
+			}
 		}
 
+		tail.Stop()
+
 		_ = stop // leave it running
 
-		return &Service{
+		return &RunningService{
 			Container: container,
 			Detach:    stop,
+			Exited:    exited,
 		}, nil
 	case err := <-exited:
 		stop() // interrupt healthcheck
+		tail.Finish(ctx)
 
-		if err != nil {
-			return nil, fmt.Errorf("exited: %w", err)
+		return nil, serviceExitedError(err, tail.Lines(serviceErrorLogLines))
+	}
+}
+
+// gatewayContainer creates a container outside of the LLB graph with the
+// container's rootfs, mounts, secrets and sockets, suitable for running
+// processes interactively.
+func (container *Container) gatewayContainer(ctx context.Context, gw bkgw.Client) (bkgw.Container, error) {
+	if container.FS == nil {
+		return nil, fmt.Errorf("container has no rootfs")
+	}
+
+	rootRef, err := gwRef(ctx, gw, container.FS)
+	if err != nil {
+		return nil, fmt.Errorf("rootfs: %w", err)
+	}
+
+	mounts := []bkgw.Mount{
+		{
+			Dest:      "/",
+			MountType: pb.MountType_BIND,
+			Ref:       rootRef,
+		},
+	}
+
+	for _, mnt := range container.Mounts {
+		switch {
+		case mnt.Tmpfs:
+			mounts = append(mounts, bkgw.Mount{
+				Dest:      mnt.Target,
+				MountType: pb.MountType_TMPFS,
+			})
+		case mnt.CacheID != "":
+			var sharing pb.CacheSharingOpt
+			switch mnt.CacheSharingMode {
+			case "shared":
+				sharing = pb.CacheSharingOpt_SHARED
+			case "private":
+				sharing = pb.CacheSharingOpt_PRIVATE
+			case "locked":
+				sharing = pb.CacheSharingOpt_LOCKED
+			default:
+				return nil, errors.Errorf("invalid cache mount sharing mode %q", mnt.CacheSharingMode)
+			}
+
+			mounts = append(mounts, bkgw.Mount{
+				Dest:      mnt.Target,
+				MountType: pb.MountType_CACHE,
+				CacheOpt: &pb.CacheOpt{
+					ID:      mnt.CacheID,
+					Sharing: sharing,
+				},
+			})
+		default:
+			ref, err := gwRef(ctx, gw, mnt.Source)
+			if err != nil {
+				return nil, fmt.Errorf("mount %s: %w", mnt.Target, err)
+			}
+
+			mounts = append(mounts, bkgw.Mount{
+				Dest:      mnt.Target,
+				Selector:  mnt.SourcePath,
+				MountType: pb.MountType_BIND,
+				Ref:       ref,
+			})
+		}
+	}
+
+	for i, secret := range container.Secrets {
+		opt := &pb.SecretOpt{
+			ID:   secret.Secret.String(),
+			Mode: 0o400, // preserve default
+		}
+
+		var dest string
+		switch {
+		case secret.EnvName != "":
+			// NB: processes in a gateway container can't be given secret env vars,
+			// so the shim sets them from mounts instead
+			dest = path.Join(SecretEnvMountDir, secret.EnvName)
+		case secret.MountPath != "":
+			dest = secret.MountPath
+			if secret.Owner != nil {
+				opt.Uid = uint32(secret.Owner.UID)
+				opt.Gid = uint32(secret.Owner.GID)
+			}
+		default:
+			return nil, fmt.Errorf("malformed secret config at index %d", i)
+		}
+
+		mounts = append(mounts, bkgw.Mount{
+			Dest:      dest,
+			MountType: pb.MountType_SECRET,
+			SecretOpt: opt,
+		})
+	}
+
+	for _, socket := range container.Sockets {
+		if socket.UnixPath == "" {
+			return nil, fmt.Errorf("unsupported socket: only unix paths are implemented")
 		}
 
-		return nil, fmt.Errorf("service exited before healthcheck")
+		opt := &pb.SSHOpt{
+			ID:   socket.Socket.LLBID(),
+			Mode: 0o600, // preserve default
+		}
+		if socket.Owner != nil {
+			opt.Uid = uint32(socket.Owner.UID)
+			opt.Gid = uint32(socket.Owner.GID)
+		}
+
+		mounts = append(mounts, bkgw.Mount{
+			Dest:      socket.UnixPath,
+			MountType: pb.MountType_SSH,
+			SSHOpt:    opt,
+		})
 	}
+
+	platform := pb.PlatformFromSpec(container.Platform)
+
+	return gw.NewContainer(ctx, bkgw.NewContainerRequest{
+		Mounts:   mounts,
+		Hostname: container.Hostname,
+		Platform: &platform,
+	})
 }
 
 func (container *Container) MetaFileContents(ctx context.Context, gw bkgw.Client, filePath string) (string, error) {
@@ -1347,58 +1727,6 @@ func (container *Container) MetaFileContents(ctx context.Context, gw bkgw.Client
 	return string(content), nil
 }
 
-func (container *Container) Publish(
-	ctx context.Context,
-	ref string,
-	platformVariants []ContainerID,
-	bkClient *bkclient.Client,
-	solveOpts bkclient.SolveOpt,
-	solveCh chan<- *bkclient.SolveStatus,
-) (string, error) {
-	// NOTE: be careful to not overwrite any values from original solveOpts (i.e. with append).
-	solveOpts.Exports = []bkclient.ExportEntry{
-		{
-			Type: bkclient.ExporterImage,
-			Attrs: map[string]string{
-				"name": ref,
-				"push": "true",
-			},
-		},
-	}
-
-	ch, wg := mirrorCh(solveCh)
-	defer wg.Wait()
-
-	res, err := bkClient.Build(ctx, solveOpts, "", func(ctx context.Context, gw bkgw.Client) (*bkgw.Result, error) {
-		return container.export(ctx, gw, platformVariants)
-	}, ch)
-	if err != nil {
-		return "", err
-	}
-
-	refName, err := reference.ParseNormalizedNamed(ref)
-	if err != nil {
-		return "", err
-	}
-
-	imageDigest, found := res.ExporterResponse[exptypes.ExporterImageDigestKey]
-	if found {
-		dig, err := digest.Parse(imageDigest)
-		if err != nil {
-			return "", fmt.Errorf("parse digest: %w", err)
-		}
-
-		withDig, err := reference.WithDigest(refName, dig)
-		if err != nil {
-			return "", fmt.Errorf("with digest: %w", err)
-		}
-
-		return withDig.String(), nil
-	}
-
-	return ref, nil
-}
-
 func (container *Container) Export(
 	ctx context.Context,
 	host *Host,
@@ -1493,6 +1821,8 @@ func (container *Container) Import(
 	}
 
 	container.Config = imgSpec.Config
+	container.History = imgSpec.History
+	container.BaseFS = container.FS
 
 	return container, nil
 }
@@ -1588,30 +1918,19 @@ func (container *Container) export(
 	gw bkgw.Client,
 	platformVariants []ContainerID,
 ) (*bkgw.Result, error) {
-	containers := []*Container{}
-	services := ServiceBindings{}
-	if container.FS != nil {
-		containers = append(containers, container)
-		services.Merge(container.Services)
-	}
-	for _, id := range platformVariants {
-		variant, err := id.ToContainer()
-		if err != nil {
-			return nil, err
-		}
-		if variant.FS != nil {
-			containers = append(containers, variant)
-			services.Merge(variant.Services)
-		}
+	containers, err := container.exportVariants(platformVariants)
+	if err != nil {
+		return nil, err
 	}
 
-	if len(containers) == 0 {
-		// Could also just ignore and do nothing, airing on side of error until proven otherwise.
-		return nil, errors.New("no containers to export")
+	services := ServiceBindings{}
+	for _, variant := range containers {
+		services.Merge(variant.Services)
 	}
 
 	return WithServices(ctx, gw, services, func() (*bkgw.Result, error) {
-		if len(containers) == 1 {
+		// buildkit only attaches attestations to multi-platform results
+		if len(containers) == 1 && !containers[0].hasAttestations() {
 			exportContainer := containers[0]
 
 			st, err := exportContainer.FSState()
@@ -1632,13 +1951,7 @@ func (container *Container) export(
 				return nil, err
 			}
 
-			cfgBytes, err := json.Marshal(specs.Image{
-				Architecture: exportContainer.Platform.Architecture,
-				OS:           exportContainer.Platform.OS,
-				OSVersion:    exportContainer.Platform.OSVersion,
-				OSFeatures:   exportContainer.Platform.OSFeatures,
-				Config:       exportContainer.Config,
-			})
+			cfgBytes, err := exportContainer.imageConfig()
 			if err != nil {
 				return nil, err
 			}
@@ -1677,18 +1990,16 @@ func (container *Container) export(
 
 			platformKey := platforms.Format(exportContainer.Platform)
 			res.AddRef(platformKey, ref)
+
+			if err := exportContainer.addAttestations(ctx, gw, res, platformKey); err != nil {
+				return nil, err
+			}
 			expPlatforms.Platforms[i] = exptypes.Platform{
 				ID:       platformKey,
 				Platform: exportContainer.Platform,
 			}
 
-			cfgBytes, err := json.Marshal(specs.Image{
-				Architecture: exportContainer.Platform.Architecture,
-				OS:           exportContainer.Platform.OS,
-				OSVersion:    exportContainer.Platform.OSVersion,
-				OSFeatures:   exportContainer.Platform.OSFeatures,
-				Config:       exportContainer.Config,
-			})
+			cfgBytes, err := exportContainer.imageConfig()
 			if err != nil {
 				return nil, err
 			}
@@ -1705,6 +2016,31 @@ func (container *Container) export(
 	})
 }
 
+// exportVariants returns the containers exported as platform variants of
+// the image, skipping any without a filesystem.
+func (container *Container) exportVariants(platformVariants []ContainerID) ([]*Container, error) {
+	containers := []*Container{}
+	if container.FS != nil {
+		containers = append(containers, container)
+	}
+	for _, id := range platformVariants {
+		variant, err := id.ToContainer()
+		if err != nil {
+			return nil, err
+		}
+		if variant.FS != nil {
+			containers = append(containers, variant)
+		}
+	}
+
+	if len(containers) == 0 {
+		// Could also just ignore and do nothing, airing on side of error until proven otherwise.
+		return nil, errors.New("no containers to export")
+	}
+
+	return containers, nil
+}
+
 func (container *Container) ImageRefOrErr(ctx context.Context, gw bkgw.Client) (string, error) {
 	imgRef := container.ImageRef
 	if imgRef != "" {
@@ -1752,6 +2088,71 @@ type ContainerExecOpts struct {
 
 	// Grant the process all root capabilities
 	InsecureRootCapabilities bool
+
+	// The outcome the command is expected to have; defaults to success
+	Expect ExecExpect
+
+	// Memory limit, with an optional unit suffix (e.g. "512m")
+	MemoryLimit string
+
+	// Relative CPU weight of the command
+	CPUShares int
+
+	// CPU time the command may use per 100ms period, in microseconds
+	CPUQuota int
+
+	// Maximum number of processes the command may run
+	PidsLimit int
+
+	// Seconds the command may run for before it's killed
+	Timeout int
+
+	// Run the command as one of the shim's internal commands, without
+	// persisting that in the container's config. Not settable through the
+	// API.
+	internalCommand bool
+}
+
+// resourceLimitOpts returns the env vars that configure the exec's resource
+// limits, which are applied by the shim.
+func (opts ContainerExecOpts) resourceLimitOpts() ([]llb.RunOption, error) {
+	runOpts := []llb.RunOption{}
+
+	if opts.MemoryLimit != "" {
+		memory, err := units.RAMInBytes(opts.MemoryLimit)
+		if err != nil {
+			return nil, fmt.Errorf("invalid memory limit: %w", err)
+		}
+
+		if memory <= 0 {
+			return nil, fmt.Errorf("invalid memory limit %q: must be positive", opts.MemoryLimit)
+		}
+
+		runOpts = append(runOpts, llb.AddEnv("_DAGGER_LIMIT_MEMORY", strconv.FormatInt(memory, 10)))
+	}
+
+	for _, limit := range []struct {
+		name  string
+		env   string
+		value int
+	}{
+		{"cpu shares", "_DAGGER_LIMIT_CPU_SHARES", opts.CPUShares},
+		{"cpu quota", "_DAGGER_LIMIT_CPU_QUOTA", opts.CPUQuota},
+		{"pids limit", "_DAGGER_LIMIT_PIDS", opts.PidsLimit},
+		{"timeout", "_DAGGER_EXEC_TIMEOUT", opts.Timeout},
+	} {
+		if limit.value < 0 {
+			return nil, fmt.Errorf("invalid %s %d: must be positive", limit.name, limit.value)
+		}
+
+		if limit.value == 0 {
+			continue
+		}
+
+		runOpts = append(runOpts, llb.AddEnv(limit.env, strconv.Itoa(limit.value)))
+	}
+
+	return runOpts, nil
 }
 
 type BuildArg struct {
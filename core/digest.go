@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"io"
+	"io/fs"
+	"path"
+
+	"github.com/dagger/dagger/core/reffs"
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/opencontainers/go-digest"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+// DigestAlgorithm is a hash algorithm used to compute a content digest.
+type DigestAlgorithm string
+
+const (
+	DigestSHA256 DigestAlgorithm = "SHA256"
+	DigestSHA512 DigestAlgorithm = "SHA512"
+)
+
+func (algorithm DigestAlgorithm) hash() (digest.Algorithm, hash.Hash, error) {
+	switch algorithm {
+	case DigestSHA256, "":
+		return digest.SHA256, sha256.New(), nil
+	case DigestSHA512:
+		return digest.SHA512, sha512.New(), nil
+	default:
+		return "", nil, fmt.Errorf("unknown digest algorithm %q", algorithm)
+	}
+}
+
+// Digest returns the digest of the file's contents, e.g. "sha256:...".
+func (file *File) Digest(ctx context.Context, gw bkgw.Client, algorithm DigestAlgorithm) (digest.Digest, error) {
+	alg, h, err := algorithm.hash()
+	if err != nil {
+		return "", err
+	}
+
+	return WithServices(ctx, gw, file.Services, func() (digest.Digest, error) {
+		fs, err := reffs.OpenDef(ctx, gw, file.LLB)
+		if err != nil {
+			return "", err
+		}
+
+		f, err := fs.Open(file.File)
+		if err != nil {
+			return "", err
+		}
+		defer f.Close()
+
+		if _, err := io.Copy(h, f); err != nil {
+			return "", err
+		}
+
+		return digest.NewDigest(alg, h), nil
+	})
+}
+
+// Digest returns a SHA256 digest of the directory's tree.
+//
+// The digest covers the path, type, permissions and contents of every file,
+// directory and symlink, but not timestamps or ownership, so the same tree
+// has the same digest however it was produced.
+func (dir *Directory) Digest(ctx context.Context, gw bkgw.Client) (digest.Digest, error) {
+	return WithServices(ctx, gw, dir.Services, func() (digest.Digest, error) {
+		res, err := gw.Solve(ctx, bkgw.SolveRequest{
+			Definition: dir.LLB,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		ref, err := res.SingleRef()
+		if err != nil {
+			return "", err
+		}
+
+		h := sha256.New()
+
+		// empty directory, i.e. llb.Scratch()
+		if ref == nil {
+			return digest.NewDigest(digest.SHA256, h), nil
+		}
+
+		// read files in chunks rather than whole
+		refFS := reffs.ReferenceFS(ctx, ref)
+
+		err = walkRef(ctx, ref, dir.Dir, func(entryPath string, entry *fstypes.Stat) (bool, error) {
+			mode := fs.FileMode(entry.GetMode())
+
+			var data string
+			switch {
+			case mode.IsDir():
+				data = "dir"
+			case mode&fs.ModeSymlink != 0:
+				data = "symlink:" + entry.Linkname
+			case mode.IsRegular():
+				f, err := refFS.Open(path.Join(dir.Dir, entryPath))
+				if err != nil {
+					return false, err
+				}
+				defer f.Close()
+
+				fileH := sha256.New()
+				if _, err := io.Copy(fileH, f); err != nil {
+					return false, err
+				}
+				data = digest.NewDigest(digest.SHA256, fileH).String()
+			default:
+				data = mode.Type().String()
+			}
+
+			// NUL separators keep paths containing spaces or newlines unambiguous
+			fmt.Fprintf(h, "%s\x00%o\x00%s\x00", entryPath, uint32(mode.Perm()|mode&(fs.ModeSetuid|fs.ModeSetgid|fs.ModeSticky)), data)
+
+			return true, nil
+		})
+		if err != nil {
+			return "", err
+		}
+
+		return digest.NewDigest(digest.SHA256, h), nil
+	})
+}
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io/fs"
@@ -8,6 +9,7 @@ import (
 	"path/filepath"
 	"reflect"
 	"strings"
+	"text/template"
 	"time"
 
 	"github.com/dagger/dagger/core/pipeline"
@@ -196,6 +198,120 @@ func (dir *Directory) Entries(ctx context.Context, gw bkgw.Client, src string) (
 	})
 }
 
+// Glob returns the paths in the directory matching the pattern, walking it
+// recursively. A "**" segment matches any number of directories.
+func (dir *Directory) Glob(ctx context.Context, gw bkgw.Client, pattern string) ([]string, error) {
+	segments := strings.Split(path.Clean(pattern), "/")
+	for _, segment := range segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	return WithServices(ctx, gw, dir.Services, func() ([]string, error) {
+		res, err := gw.Solve(ctx, bkgw.SolveRequest{
+			Definition: dir.LLB,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		ref, err := res.SingleRef()
+		if err != nil {
+			return nil, err
+		}
+
+		matches := []string{}
+
+		// empty directory, i.e. llb.Scratch()
+		if ref == nil {
+			return matches, nil
+		}
+
+		err = walkRef(ctx, ref, dir.Dir, func(entryPath string, entry *fstypes.Stat) (bool, error) {
+			entrySegments := strings.Split(entryPath, "/")
+
+			if matchGlob(segments, entrySegments, false) {
+				matches = append(matches, entryPath)
+			}
+
+			return matchGlob(segments, entrySegments, true), nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return matches, nil
+	})
+}
+
+// walkRef calls fn for each entry under root in the reference, recursing
+// into directories for which fn returns true. Paths are relative to root.
+func walkRef(ctx context.Context, ref bkgw.Reference, root string, fn func(string, *fstypes.Stat) (bool, error)) error {
+	var walk func(string) error
+	walk = func(sub string) error {
+		entries, err := ref.ReadDir(ctx, bkgw.ReadDirRequest{
+			Path: path.Join(root, sub),
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			entryPath := path.Join(sub, entry.GetPath())
+
+			descend, err := fn(entryPath, entry)
+			if err != nil {
+				return err
+			}
+
+			if descend && fs.FileMode(entry.GetMode()).IsDir() {
+				if err := walk(entryPath); err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	}
+
+	return walk("")
+}
+
+// matchGlob reports whether the segments of a path match the segments of a
+// pattern. If prefix is true, it instead reports whether a path beneath it
+// could match.
+func matchGlob(pattern, name []string, prefix bool) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			if prefix {
+				return true
+			}
+
+			// match any number of segments, including none
+			for i := 0; i <= len(name); i++ {
+				if matchGlob(pattern[1:], name[i:], prefix) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(name) == 0 {
+			return prefix
+		}
+
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+
+		pattern, name = pattern[1:], name[1:]
+	}
+
+	return len(name) == 0 && !prefix
+}
+
 func (dir *Directory) WithNewFile(ctx context.Context, dest string, content []byte, permissions fs.FileMode, ownership *Ownership) (*Directory, error) {
 	dir = dir.Clone()
 
@@ -470,6 +586,128 @@ func (dir *Directory) Without(ctx context.Context, path string) (*Directory, err
 	return dir, nil
 }
 
+// WithTemplate renders the Go text/template at src with the given variables
+// and writes the result to dest, preserving the template's permissions and
+// ownership. Referencing a variable that isn't set is an error.
+func (dir *Directory) WithTemplate(ctx context.Context, gw bkgw.Client, src, dest string, vars map[string]string) (*Directory, error) {
+	if dest == "" {
+		dest = src
+	}
+
+	tmplFile, err := dir.File(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := tmplFile.Contents(ctx, gw)
+	if err != nil {
+		return nil, err
+	}
+
+	stat, err := tmplFile.Stat(ctx, gw)
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := template.New(src).Option("missingkey=error").Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("parse template: %w", err)
+	}
+
+	rendered := new(bytes.Buffer)
+	if err := tmpl.Execute(rendered, vars); err != nil {
+		return nil, fmt.Errorf("render template: %w", err)
+	}
+
+	return dir.WithNewFile(ctx, dest, rendered.Bytes(), fs.FileMode(stat.Mode).Perm(), &Ownership{
+		UID: int(stat.Uid),
+		GID: int(stat.Gid),
+	})
+}
+
+// internalMountPath is where a directory is mounted for internal shim commands
+// that modify it.
+const internalMountPath = "/mnt"
+
+// internalOutputPath is where internal shim commands that create new files
+// write them.
+const internalOutputPath = "/out"
+
+func (dir *Directory) WithSymlink(ctx context.Context, target, linkName string) (*Directory, error) {
+	dir = dir.Clone()
+
+	err := validateFileName(linkName)
+	if err != nil {
+		return nil, err
+	}
+
+	linkName = path.Clean(linkName)
+	if linkName == "." || linkName == ".." || strings.HasPrefix(linkName, "../") {
+		return nil, fmt.Errorf("cannot create symlink outside parent: %s", linkName)
+	}
+
+	// be sure to create the link under the working directory
+	dest := path.Join(dir.Dir, linkName)
+
+	st, err := dir.State()
+	if err != nil {
+		return nil, err
+	}
+
+	// buildkit has no file op for symlinks, so have the shim create it
+	st = llb.Scratch().Run(
+		llb.Args([]string{"symlink", target, path.Join(internalMountPath, dest)}),
+		llb.AddEnv("_DAGGER_INTERNAL_COMMAND", ""),
+		llb.Network(llb.NetModeNone),
+		dir.Pipeline.LLBOpt(),
+		llb.WithCustomNamef("symlink %s -> %s", linkName, target),
+	).AddMount(internalMountPath, st)
+
+	err = dir.SetState(ctx, st)
+	if err != nil {
+		return nil, err
+	}
+
+	return dir, nil
+}
+
+// WithRename moves a file or directory, following the same semantics as mv:
+// if the destination is an existing directory, the source is moved into it.
+func (dir *Directory) WithRename(ctx context.Context, from, to string) (*Directory, error) {
+	dir = dir.Clone()
+
+	from = path.Join(dir.Dir, from)
+	to = path.Join(dir.Dir, to)
+
+	if from == to || to == path.Dir(from) {
+		// moving to the same path, or into the directory it's already in
+		return dir, nil
+	}
+
+	if strings.HasPrefix(to, from+"/") {
+		return nil, fmt.Errorf("cannot move %s into itself", from)
+	}
+
+	st, err := dir.State()
+	if err != nil {
+		return nil, err
+	}
+
+	st = st.File(
+		llb.Copy(st, from, to, &llb.CopyInfo{
+			CreateDestPath: true,
+		}).Rm(from),
+		dir.Pipeline.LLBOpt(),
+	)
+
+	err = dir.SetState(ctx, st)
+	if err != nil {
+		return nil, err
+	}
+
+	return dir, nil
+}
+
 func (dir *Directory) Export(
 	ctx context.Context,
 	host *Host,
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+)
+
+// ExecExpect is the outcome an exec is expected to have. An exec that doesn't
+// meet its expectation fails.
+type ExecExpect string
+
+const (
+	// ExecExpectSuccess expects a zero exit code; this is the default.
+	ExecExpectSuccess ExecExpect = "SUCCESS"
+
+	// ExecExpectFailure expects a non-zero exit code.
+	ExecExpectFailure ExecExpect = "FAILURE"
+
+	// ExecExpectAny accepts any exit code.
+	ExecExpectAny ExecExpect = "ANY"
+)
+
+// ExecResult is the outcome of a container's last exec.
+type ExecResult struct {
+	ExitCode   int
+	Stdout     string
+	Stderr     string
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// Duration returns how long the command ran for.
+func (result *ExecResult) Duration() time.Duration {
+	return result.FinishedAt.Sub(result.StartedAt)
+}
+
+// ExecResult returns the outcome of the container's last exec, as recorded by
+// the shim.
+//
+// Output that was redirected elsewhere is empty.
+func (container *Container) ExecResult(ctx context.Context, gw bkgw.Client) (*ExecResult, error) {
+	if container.Meta == nil {
+		return nil, ErrContainerNoExec
+	}
+
+	return WithServices(ctx, gw, container.Services, func() (*ExecResult, error) {
+		ref, err := gwRef(ctx, gw, container.Meta)
+		if err != nil {
+			return nil, err
+		}
+
+		entries, err := ref.ReadDir(ctx, bkgw.ReadDirRequest{
+			Path: metaSourcePath,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		recorded := map[string]bool{}
+		for _, entry := range entries {
+			recorded[entry.GetPath()] = true
+		}
+
+		read := func(name string) (string, error) {
+			if !recorded[name] {
+				return "", nil
+			}
+
+			content, err := ref.ReadFile(ctx, bkgw.ReadRequest{
+				Filename: path.Join(metaSourcePath, name),
+			})
+			if err != nil {
+				return "", fmt.Errorf("read %s: %w", name, err)
+			}
+
+			return string(content), nil
+		}
+
+		result := &ExecResult{}
+
+		exitCode, err := read("exitCode")
+		if err != nil {
+			return nil, err
+		}
+
+		result.ExitCode, err = strconv.Atoi(exitCode)
+		if err != nil {
+			return nil, fmt.Errorf("parse exit code: %w", err)
+		}
+
+		result.Stdout, err = read("stdout")
+		if err != nil {
+			return nil, err
+		}
+
+		result.Stderr, err = read("stderr")
+		if err != nil {
+			return nil, err
+		}
+
+		for name, dest := range map[string]*time.Time{
+			"startedAt":  &result.StartedAt,
+			"finishedAt": &result.FinishedAt,
+		} {
+			content, err := read(name)
+			if err != nil {
+				return nil, err
+			}
+
+			if content == "" {
+				continue
+			}
+
+			*dest, err = time.Parse(time.RFC3339Nano, strings.TrimSpace(content))
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", name, err)
+			}
+		}
+
+		return result, nil
+	})
+}
@@ -135,6 +135,56 @@ func (file *File) WithTimestamps(ctx context.Context, unix int) (*File, error) {
 	return file, nil
 }
 
+// WithReplaced replaces the first occurrence of search in the file's contents,
+// or every occurrence if all is true. It errors if there are none.
+func (file *File) WithReplaced(ctx context.Context, search, replacement string, all bool) (*File, error) {
+	if search == "" {
+		return nil, fmt.Errorf("search string must not be empty")
+	}
+
+	args := []string{"replace"}
+	if all {
+		args = append(args, "-all")
+	}
+	args = append(args, path.Join(internalMountPath, file.File), search, replacement)
+
+	return file.editInPlace(ctx, args, fmt.Sprintf("replace %q in %s", search, path.Base(file.File)))
+}
+
+// WithAppended appends to the file's contents.
+func (file *File) WithAppended(ctx context.Context, content string) (*File, error) {
+	args := []string{"append", path.Join(internalMountPath, file.File), content}
+
+	return file.editInPlace(ctx, args, fmt.Sprintf("append to %s", path.Base(file.File)))
+}
+
+// editInPlace has the shim run an internal command that edits the file where
+// it is, so that it keeps its ownership and mode.
+func (file *File) editInPlace(ctx context.Context, args []string, name string) (*File, error) {
+	file = file.Clone()
+
+	st, err := file.State()
+	if err != nil {
+		return nil, err
+	}
+
+	st = llb.Scratch().Run(
+		llb.Args(args),
+		llb.AddEnv("_DAGGER_INTERNAL_COMMAND", ""),
+		llb.Network(llb.NetModeNone),
+		file.Pipeline.LLBOpt(),
+		llb.WithCustomName(name),
+	).AddMount(internalMountPath, st)
+
+	def, err := st.Marshal(ctx, llb.Platform(file.Platform))
+	if err != nil {
+		return nil, err
+	}
+	file.LLB = def.ToPB()
+
+	return file, nil
+}
+
 func (file *File) Open(ctx context.Context, host *Host, gw bkgw.Client) (io.ReadCloser, error) {
 	return WithServices(ctx, gw, file.Services, func() (io.ReadCloser, error) {
 		fs, err := reffs.OpenDef(ctx, gw, file.LLB)
@@ -34,14 +34,19 @@ type GatewayClient struct {
 	cacheConfigType  string
 	cacheConfigAttrs map[string]string
 	mu               sync.Mutex
+
+	// outputs of execs, used to include what a service logged in the error
+	// returned when it fails to start
+	outputs *ExecOutputs
 }
 
-func NewGatewayClient(baseClient bkgw.Client, cacheConfigType string, cacheConfigAttrs map[string]string) *GatewayClient {
+func NewGatewayClient(baseClient bkgw.Client, cacheConfigType string, cacheConfigAttrs map[string]string, outputs *ExecOutputs) *GatewayClient {
 	return &GatewayClient{
 		Client:           baseClient,
 		cacheConfigType:  cacheConfigType,
 		cacheConfigAttrs: cacheConfigAttrs,
 		refs:             make(map[*ref]struct{}),
+		outputs:          outputs,
 	}
 }
 
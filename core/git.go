@@ -0,0 +1,815 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dagger/dagger/core/pipeline"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/moby/buildkit/client/llb"
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/moby/buildkit/util/gitutil"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// GitCommit is metadata about a commit in a git repository.
+type GitCommit struct {
+	Hash      string       `json:"hash"`
+	Author    GitSignature `json:"author"`
+	Committer GitSignature `json:"committer"`
+	Message   string       `json:"message"`
+	Parents   []string     `json:"parents"`
+	Timestamp time.Time    `json:"timestamp"`
+}
+
+// GitSignature identifies the author or committer of a commit.
+type GitSignature struct {
+	Name  string    `json:"name"`
+	Email string    `json:"email"`
+	Date  time.Time `json:"date"`
+}
+
+func (sig GitSignature) String() string {
+	return fmt.Sprintf("%s <%s>", sig.Name, sig.Email)
+}
+
+func newGitCommit(commit *object.Commit) GitCommit {
+	parents := make([]string, 0, len(commit.ParentHashes))
+	for _, parent := range commit.ParentHashes {
+		parents = append(parents, parent.String())
+	}
+
+	return GitCommit{
+		Hash: commit.Hash.String(),
+		Author: GitSignature{
+			Name:  commit.Author.Name,
+			Email: commit.Author.Email,
+			Date:  commit.Author.When,
+		},
+		Committer: GitSignature{
+			Name:  commit.Committer.Name,
+			Email: commit.Committer.Email,
+			Date:  commit.Committer.When,
+		},
+		Message:   commit.Message,
+		Parents:   parents,
+		Timestamp: commit.Committer.When,
+	}
+}
+
+// GitHistory is the history of a git repository, which can be inspected
+// without checking out a tree.
+type GitHistory struct {
+	repo *git.Repository
+}
+
+// GitFetchOpts configures how the engine fetches a git repository.
+type GitFetchOpts struct {
+	// AuthToken is a token used as the password for HTTP basic auth.
+	AuthToken string
+
+	// AuthHeader is the full value of the HTTP Authorization header, which
+	// takes precedence over AuthToken.
+	AuthHeader string
+
+	// Depth limits the number of commits fetched; 0 fetches the whole
+	// history.
+	Depth int
+}
+
+func (opts GitFetchOpts) auth() transport.AuthMethod {
+	switch {
+	case opts.AuthHeader != "":
+		return gitAuthHeader(opts.AuthHeader)
+	case opts.AuthToken != "":
+		// the same username buildkit uses for token auth
+		return &githttp.BasicAuth{
+			Username: "x-access-token",
+			Password: opts.AuthToken,
+		}
+	default:
+		return nil
+	}
+}
+
+// gitAuthHeader sets the Authorization header of HTTP git requests verbatim.
+type gitAuthHeader string
+
+func (header gitAuthHeader) SetAuth(r *http.Request) {
+	r.Header.Set("Authorization", string(header))
+}
+
+func (header gitAuthHeader) Name() string {
+	return "http-auth-header"
+}
+
+func (header gitAuthHeader) String() string {
+	return "Authorization: <redacted>"
+}
+
+// gitRemoteURL normalizes a git URL the same way as llb.Git, which assumes
+// https when there is no scheme (e.g. "github.com/dagger/dagger").
+func gitRemoteURL(url string) string {
+	if _, protocol := gitutil.ParseProtocol(url); protocol == gitutil.UnknownProtocol && !strings.Contains(url, "://") {
+		return "https://" + url
+	}
+	return url
+}
+
+// fetchGit fetches every branch and tag of the repository at url into repo.
+func fetchGit(ctx context.Context, repo *git.Repository, url string, opts GitFetchOpts) error {
+	// a repository fetched before may have been fetched with other credentials
+	if err := repo.DeleteRemote(git.DefaultRemoteName); err != nil && !errors.Is(err, git.ErrRemoteNotFound) {
+		return err
+	}
+
+	remote, err := repo.CreateRemote(&config.RemoteConfig{
+		Name: git.DefaultRemoteName,
+		URLs: []string{gitRemoteURL(url)},
+	})
+	if err != nil {
+		return err
+	}
+
+	err = remote.FetchContext(ctx, &git.FetchOptions{
+		RefSpecs: []config.RefSpec{
+			"+refs/heads/*:refs/heads/*",
+			"+refs/tags/*:refs/tags/*",
+		},
+		Depth: opts.Depth,
+		Auth:  opts.auth(),
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return fmt.Errorf("fetch %s: %w", url, err)
+	}
+
+	// point HEAD at the remote's default branch, so an empty ref resolves the
+	// same way it does for llb.Git
+	refs, err := remote.ListContext(ctx, &git.ListOptions{
+		Auth: opts.auth(),
+	})
+	if err != nil {
+		return fmt.Errorf("list %s: %w", url, err)
+	}
+
+	for _, ref := range refs {
+		if ref.Name() != plumbing.HEAD {
+			continue
+		}
+
+		if ref.Type() == plumbing.SymbolicReference {
+			ref = plumbing.NewSymbolicReference(plumbing.HEAD, ref.Target())
+		} else {
+			ref = plumbing.NewHashReference(plumbing.HEAD, ref.Hash())
+		}
+
+		if err := repo.Storer.SetReference(ref); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FetchGitHistory fetches every branch and tag of the repository at url into
+// a bare repository in dir, updating the one fetched there before, if any.
+func FetchGitHistory(ctx context.Context, dir, url string, opts GitFetchOpts) (*GitHistory, error) {
+	repo, err := git.PlainOpen(dir)
+	if errors.Is(err, git.ErrRepositoryNotExists) {
+		repo, err = git.PlainInit(dir, true)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fetchGit(ctx, repo, url, opts); err != nil {
+		return nil, err
+	}
+
+	return &GitHistory{repo: repo}, nil
+}
+
+// GitRemote is a remote git repository, along with the secrets used to fetch
+// it.
+type GitRemote struct {
+	URL        string
+	AuthToken  SecretID
+	AuthHeader SecretID
+	Depth      int
+}
+
+const (
+	// GitAuthTokenPath is where the secret used as the password for HTTP basic
+	// auth is mounted for the shim to read.
+	GitAuthTokenPath = "/run/dagger/git-auth-token"
+
+	// GitAuthHeaderPath is where the secret used as the HTTP Authorization
+	// header is mounted for the shim to read.
+	GitAuthHeaderPath = "/run/dagger/git-auth-header"
+
+	// gitHistoryCachePath is where the cache of a remote's history is mounted
+	// for the shim to fetch into.
+	gitHistoryCachePath = "/var/cache/git"
+)
+
+// secretMounts mounts the remote's auth secrets where the shim reads them.
+func (remote GitRemote) secretMounts() []bkgw.Mount {
+	var mounts []bkgw.Mount
+	if remote.AuthToken != "" {
+		mounts = append(mounts, secretMount(GitAuthTokenPath, remote.AuthToken))
+	}
+	if remote.AuthHeader != "" {
+		mounts = append(mounts, secretMount(GitAuthHeaderPath, remote.AuthHeader))
+	}
+	return mounts
+}
+
+// cacheID returns the ID of the cache mount the remote's history is fetched
+// into. Each set of credentials gets its own cache, so that history fetched
+// with one can't be read with another.
+func (remote GitRemote) cacheID() string {
+	key := strings.Join([]string{
+		gitRemoteURL(remote.URL),
+		strconv.Itoa(remote.Depth),
+		remote.AuthToken.String(),
+		remote.AuthHeader.String(),
+	}, "\x00")
+
+	return "dagger-git-history-" + digest.FromString(key).Encoded()
+}
+
+// Queries about a repository's history answered by GitHistory.Query.
+const (
+	GitHeadBranchQuery   = "head-branch"
+	GitCommitInfoQuery   = "commit-info"
+	GitLogQuery          = "log"
+	GitChangedFilesQuery = "changed-files"
+)
+
+// QueryGitHistory has the shim fetch the remote's history inside the engine
+// and answer a query about it, decoding the answer into dest. The history is
+// kept in a cache mount, so later queries only fetch what changed.
+func QueryGitHistory(ctx context.Context, gw bkgw.Client, remote GitRemote, query []string, dest any) error {
+	args := append([]string{
+		"git-history",
+		remote.URL,
+		strconv.Itoa(remote.Depth),
+		gitHistoryCachePath,
+	}, query...)
+
+	mounts := append(remote.secretMounts(), bkgw.Mount{
+		Dest:      gitHistoryCachePath,
+		MountType: pb.MountType_CACHE,
+		CacheOpt: &pb.CacheOpt{
+			ID: remote.cacheID(),
+			// fetches into the same repository can't run concurrently
+			Sharing: pb.CacheSharingOpt_LOCKED,
+		},
+	})
+
+	stdout := new(bytes.Buffer)
+	if err := runInternalCommand(ctx, gw, args, nil, nil, stdout, mounts...); err != nil {
+		return err
+	}
+
+	return json.Unmarshal(stdout.Bytes(), dest)
+}
+
+// NewGitTree returns the tree of a commit checked out by the shim inside the
+// engine, for checkouts buildkit's git source can't do: skipping submodules,
+// or keeping a .git directory with more than one commit of history.
+//
+// The commit must be a full hash rather than a branch or tag, since the
+// checkout is cached like any other exec.
+func NewGitTree(
+	ctx context.Context,
+	remote GitRemote,
+	commit string,
+	submodules bool,
+	keepGitDir bool,
+	pipeline pipeline.Path,
+	platform specs.Platform,
+	services ServiceBindings,
+) (*Directory, error) {
+	runOpts := []llb.RunOption{
+		llb.Args([]string{
+			"git-checkout",
+			remote.URL,
+			strconv.Itoa(remote.Depth),
+			commit,
+			strconv.FormatBool(submodules),
+			strconv.FormatBool(keepGitDir),
+			internalOutputPath,
+		}),
+		llb.AddEnv("_DAGGER_INTERNAL_COMMAND", ""),
+		pipeline.LLBOpt(),
+		llb.WithCustomNamef("git checkout %s %s", remote.URL, commit),
+	}
+
+	if remote.AuthToken != "" {
+		runOpts = append(runOpts, llb.AddSecret(GitAuthTokenPath, llb.SecretID(remote.AuthToken.String())))
+	}
+	if remote.AuthHeader != "" {
+		runOpts = append(runOpts, llb.AddSecret(GitAuthHeaderPath, llb.SecretID(remote.AuthHeader.String())))
+	}
+
+	st := llb.Scratch().Run(runOpts...).AddMount(internalOutputPath, llb.Scratch())
+
+	return NewDirectory(ctx, st, "", pipeline, platform, services)
+}
+
+// CheckoutGit checks out a commit of the repository at url into dir.
+func CheckoutGit(ctx context.Context, dir, url, commit string, opts GitFetchOpts, submodules, keepGitDir bool) error {
+	repo, err := git.PlainInit(dir, false)
+	if err != nil {
+		return err
+	}
+
+	if err := fetchGit(ctx, repo, url, opts); err != nil {
+		return err
+	}
+
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return err
+	}
+
+	err = worktree.Checkout(&git.CheckoutOptions{
+		Hash:  plumbing.NewHash(commit),
+		Force: true,
+	})
+	if err != nil {
+		return fmt.Errorf("checkout %s: %w", commit, err)
+	}
+
+	if submodules {
+		subs, err := worktree.Submodules()
+		if err != nil {
+			return err
+		}
+
+		err = subs.UpdateContext(ctx, &git.SubmoduleUpdateOptions{
+			Init:              true,
+			RecurseSubmodules: git.DefaultSubmoduleRecursionDepth,
+			Auth:              opts.auth(),
+		})
+		if err != nil {
+			return fmt.Errorf("update submodules: %w", err)
+		}
+	}
+
+	if keepGitDir {
+		return nil
+	}
+
+	// submodules have .git files pointing into the parent's .git directory,
+	// so they go too
+	var gitDirs []string
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.Name() != git.GitDirName {
+			return nil
+		}
+
+		gitDirs = append(gitDirs, p)
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, p := range gitDirs {
+		if err := os.RemoveAll(p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// OpenGitHistory opens the history of the git repository containing a
+// directory on the host.
+func OpenGitHistory(dir string) (*GitHistory, error) {
+	repo, err := pipeline.OpenGitRepository(dir)
+	if err != nil {
+		return nil, fmt.Errorf("open git repository %s: %w", dir, err)
+	}
+
+	return &GitHistory{repo: repo}, nil
+}
+
+// Commit resolves a branch, tag or commit to the commit it points to. An
+// empty ref resolves to HEAD.
+func (history *GitHistory) Commit(ref string) (*object.Commit, error) {
+	if ref == "" {
+		ref = plumbing.HEAD.String()
+	}
+
+	hash, err := history.repo.ResolveRevision(plumbing.Revision(ref))
+	if err != nil {
+		return nil, fmt.Errorf("resolve %q: %w", ref, err)
+	}
+
+	return history.repo.CommitObject(*hash)
+}
+
+// Query answers a query about the history: one of GitHeadBranchQuery,
+// GitCommitInfoQuery with a ref, GitLogQuery with from and to refs, or
+// GitChangedFilesQuery with a ref and a base.
+func (history *GitHistory) Query(query ...string) (any, error) {
+	if len(query) == 0 {
+		return nil, fmt.Errorf("empty git history query")
+	}
+
+	switch op, args := query[0], query[1:]; {
+	case op == GitHeadBranchQuery && len(args) == 0:
+		return history.HeadBranch()
+	case op == GitCommitInfoQuery && len(args) == 1:
+		return history.CommitInfo(args[0])
+	case op == GitLogQuery && len(args) == 2:
+		return history.Log(args[0], args[1])
+	case op == GitChangedFilesQuery && len(args) == 2:
+		return history.ChangedFiles(args[0], args[1])
+	default:
+		return nil, fmt.Errorf("invalid git history query %q", query)
+	}
+}
+
+// CommitInfo returns metadata about the commit ref points to.
+func (history *GitHistory) CommitInfo(ref string) (*GitCommit, error) {
+	commit, err := history.Commit(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	info := newGitCommit(commit)
+	return &info, nil
+}
+
+// Log returns the commits reachable from to but not from from, newest first,
+// like "git log from..to". If from is empty, the whole history of to is
+// returned.
+func (history *GitHistory) Log(from, to string) ([]GitCommit, error) {
+	toCommit, err := history.Commit(to)
+	if err != nil {
+		return nil, err
+	}
+
+	// don't walk past the end of a shallow history
+	shallowParents, err := history.shallowParents()
+	if err != nil {
+		return nil, err
+	}
+
+	exclude := make(map[plumbing.Hash]bool, len(shallowParents))
+	for hash := range shallowParents {
+		exclude[hash] = true
+	}
+
+	if from != "" {
+		fromCommit, err := history.Commit(from)
+		if err != nil {
+			return nil, err
+		}
+
+		err = object.NewCommitPreorderIter(fromCommit, shallowParents, nil).ForEach(func(c *object.Commit) error {
+			exclude[c.Hash] = true
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	commits := []GitCommit{}
+	err = object.NewCommitIterCTime(toCommit, exclude, nil).ForEach(func(c *object.Commit) error {
+		commits = append(commits, newGitCommit(c))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return commits, nil
+}
+
+// HeadBranch returns the name of the branch HEAD points to, or an empty
+// string if HEAD is detached.
+func (history *GitHistory) HeadBranch() (string, error) {
+	head, err := history.repo.Reference(plumbing.HEAD, false)
+	if err != nil {
+		return "", err
+	}
+
+	if head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+		return "", nil
+	}
+
+	return head.Target().Short(), nil
+}
+
+// Root returns the root of the repository's working tree.
+func (history *GitHistory) Root() (string, error) {
+	worktree, err := history.repo.Worktree()
+	if err != nil {
+		return "", err
+	}
+
+	return worktree.Filesystem.Root(), nil
+}
+
+// IsDirty returns whether the working tree has uncommitted changes to
+// tracked files. Untracked files don't count, as with "git describe --dirty".
+// Repositories without a working tree are never dirty.
+func (history *GitHistory) IsDirty() (bool, error) {
+	worktree, err := history.repo.Worktree()
+	if err != nil {
+		if errors.Is(err, git.ErrIsBareRepository) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	status, err := worktree.Status()
+	if err != nil {
+		return false, err
+	}
+
+	for _, file := range status {
+		if file.Worktree == git.Untracked {
+			continue
+		}
+		if file.Staging != git.Unmodified || file.Worktree != git.Unmodified {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// WorktreeFiles returns the paths of the files in the working tree that are
+// tracked, or untracked but not ignored, relative to its root.
+func (history *GitHistory) WorktreeFiles() ([]string, error) {
+	worktree, err := history.repo.Worktree()
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, err
+	}
+
+	idx, err := history.repo.Storer.Index()
+	if err != nil {
+		return nil, err
+	}
+
+	files := []string{}
+	for _, entry := range idx.Entries {
+		if file, found := status[entry.Name]; found && file.Worktree == git.Deleted {
+			continue
+		}
+		files = append(files, entry.Name)
+	}
+
+	for name, file := range status {
+		if file.Worktree == git.Untracked {
+			files = append(files, name)
+		}
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// ExportTree writes the tree of a commit to dir, without submodules. The
+// tree is written to a temporary directory next to dir first, so dir is either
+// missing or complete.
+func (history *GitHistory) ExportTree(commit *object.Commit, dir string) error {
+	tree, err := commit.Tree()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), filepath.Base(dir)+".tmp-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	if err := os.Chmod(tmp, 0o755); err != nil {
+		return err
+	}
+
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+
+	mtime := commit.Committer.When
+
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		dest := filepath.Join(tmp, filepath.FromSlash(name))
+
+		switch entry.Mode {
+		case filemode.Dir, filemode.Submodule:
+			if err := os.MkdirAll(dest, 0o755); err != nil {
+				return err
+			}
+			continue
+		case filemode.Symlink:
+			target, err := history.blobContent(entry.Hash)
+			if err != nil {
+				return err
+			}
+
+			if err := os.Symlink(string(target), dest); err != nil {
+				return err
+			}
+			continue
+		case filemode.Executable:
+			err = history.writeBlob(entry.Hash, dest, 0o755)
+		case filemode.Regular, filemode.Deprecated:
+			err = history.writeBlob(entry.Hash, dest, 0o644)
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := os.Chtimes(dest, mtime, mtime); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tmp, dir); err != nil {
+		if _, statErr := os.Stat(dir); statErr == nil {
+			// exported concurrently; trees of a commit are all the same
+			return nil
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (history *GitHistory) blobContent(hash plumbing.Hash) ([]byte, error) {
+	blob, err := history.repo.BlobObject(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+func (history *GitHistory) writeBlob(hash plumbing.Hash, dest string, perm fs.FileMode) error {
+	blob, err := history.repo.BlobObject(hash)
+	if err != nil {
+		return err
+	}
+
+	r, err := blob.Reader()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	f, err := os.OpenFile(dest, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	// NB: the umask may have cleared some bits
+	return os.Chmod(dest, perm)
+}
+
+// shallowParents returns the parents of the commits at the edge of a shallow
+// history, which are missing from the repository.
+func (history *GitHistory) shallowParents() (map[plumbing.Hash]bool, error) {
+	shallow, err := history.repo.Storer.Shallow()
+	if err != nil {
+		return nil, err
+	}
+
+	missing := map[plumbing.Hash]bool{}
+	for _, hash := range shallow {
+		commit, err := history.repo.CommitObject(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, parent := range commit.ParentHashes {
+			missing[parent] = true
+		}
+	}
+
+	return missing, nil
+}
+
+// ChangedFiles returns the paths of the files that changed in ref since it
+// diverged from base, like "git diff --name-only base...ref".
+func (history *GitHistory) ChangedFiles(ref, base string) ([]string, error) {
+	commit, err := history.Commit(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	baseCommit, err := history.Commit(base)
+	if err != nil {
+		return nil, err
+	}
+
+	mergeBases, err := commit.MergeBase(baseCommit)
+	if err != nil {
+		return nil, err
+	}
+	if len(mergeBases) == 0 {
+		return nil, fmt.Errorf("%q and %q have no common history", ref, base)
+	}
+
+	fromTree, err := mergeBases[0].Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	toTree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	changes, err := object.DiffTree(fromTree, toTree)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := map[string]bool{}
+	for _, change := range changes {
+		for _, name := range []string{change.From.Name, change.To.Name} {
+			if name != "" {
+				seen[strings.TrimPrefix(name, "/")] = true
+			}
+		}
+	}
+
+	files := make([]string, 0, len(seen))
+	for name := range seen {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+
+	return files, nil
+}
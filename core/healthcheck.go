@@ -0,0 +1,243 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	dockercontainer "github.com/docker/docker/api/types/container"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// HealthcheckPort is the port the shim reports the outcome of a healthcheck
+// on, once it has one: each connection is answered with a line of either
+// "healthy" or "unhealthy: <reason>".
+const HealthcheckPort = 64937
+
+// HealthcheckEnv is the env var the shim reads the healthcheck to run
+// alongside a command from.
+const HealthcheckEnv = "_DAGGER_HEALTHCHECK"
+
+// ContainerHealthcheck checks whether a service run from the container is
+// ready, beyond listening on its exposed ports.
+//
+// It's run in the container alongside the service, like a Docker
+// healthcheck, until it first passes or fails too many times in a row.
+type ContainerHealthcheck struct {
+	// Command to run; the service is healthy once it exits zero.
+	Args []string `json:"args,omitempty"`
+
+	// Path to request from HTTPPort over HTTP; the service is healthy once it
+	// responds with a 2xx or 3xx status.
+	HTTPPath string `json:"http_path,omitempty"`
+	HTTPPort int    `json:"http_port,omitempty"`
+
+	// Seconds between checks.
+	Interval int `json:"interval,omitempty"`
+
+	// Consecutive failures, after the start period, before the service is
+	// unhealthy.
+	Retries int `json:"retries,omitempty"`
+
+	// Seconds to give the service to start, during which failures don't
+	// count.
+	StartPeriod int `json:"start_period,omitempty"`
+}
+
+const (
+	defaultHealthcheckInterval = 1
+	defaultHealthcheckRetries  = 3
+)
+
+// WithHealthcheck configures a healthcheck for services run from the
+// container.
+//
+// An HTTP check defaults to the first exposed port.
+func (container *Container) WithHealthcheck(check ContainerHealthcheck) (*Container, error) {
+	container = container.Clone()
+
+	switch {
+	case len(check.Args) > 0 && check.HTTPPath != "":
+		return nil, fmt.Errorf("healthcheck must either run a command or request an HTTP path, not both")
+	case len(check.Args) == 0 && check.HTTPPath == "":
+		return nil, fmt.Errorf("healthcheck must either run a command or request an HTTP path")
+	}
+
+	if check.HTTPPath != "" && check.HTTPPort == 0 {
+		if len(container.Ports) == 0 {
+			return nil, fmt.Errorf("no port to request %s from: container has no exposed ports", check.HTTPPath)
+		}
+
+		check.HTTPPort = container.Ports[0].Port
+	}
+
+	if check.Interval < 0 || check.Retries < 0 || check.StartPeriod < 0 {
+		return nil, fmt.Errorf("healthcheck interval, retries and start period must not be negative")
+	}
+
+	if check.Interval == 0 {
+		check.Interval = defaultHealthcheckInterval
+	}
+
+	if check.Retries == 0 {
+		check.Retries = defaultHealthcheckRetries
+	}
+
+	container.Healthcheck = &check
+
+	return container, nil
+}
+
+// withHealthcheckAttached returns the container with its healthcheck passed
+// to the shim by its last exec, for starting it as a service. Running the exec
+// otherwise, such as to read its output, doesn't run the healthcheck.
+//
+// The exec keeps its hostname, so containers bound to the service reach it
+// all the same.
+func (container *Container) withHealthcheckAttached() (*Container, error) {
+	if container.Healthcheck == nil || container.Healthchecked {
+		return container, nil
+	}
+
+	vtx, err := container.execVertex()
+	if err != nil {
+		return nil, err
+	}
+
+	check, err := json.Marshal(container.Healthcheck)
+	if err != nil {
+		return nil, err
+	}
+
+	env := HealthcheckEnv + "=" + string(check)
+
+	container = container.Clone()
+
+	container.FS, err = withExecEnv(container.FS, vtx, env)
+	if err != nil {
+		return nil, fmt.Errorf("attach healthcheck: %w", err)
+	}
+
+	container.Meta, err = withExecEnv(container.Meta, vtx, env)
+	if err != nil {
+		return nil, fmt.Errorf("attach healthcheck: %w", err)
+	}
+
+	container.Healthchecked = true
+
+	return container, nil
+}
+
+// withExecEnv returns a copy of the definition with the env var added to the
+// exec op vtx, updating the ops that depend on it.
+func withExecEnv(def *pb.Definition, vtx digest.Digest, env string) (*pb.Definition, error) {
+	res := &pb.Definition{
+		Def:      make([][]byte, len(def.Def)),
+		Metadata: make(map[digest.Digest]pb.OpMetadata, len(def.Metadata)),
+		Source:   def.Source,
+	}
+	for dgst, md := range def.Metadata {
+		res.Metadata[dgst] = md
+	}
+
+	// ops are listed after their inputs, so the ops depending on a replaced
+	// one always come later
+	replaced := map[digest.Digest]digest.Digest{}
+	for i, dt := range def.Def {
+		dgst := digest.FromBytes(dt)
+
+		var op pb.Op
+		if err := op.Unmarshal(dt); err != nil {
+			return nil, err
+		}
+
+		changed := false
+
+		if dgst == vtx {
+			exec := op.GetExec()
+			if exec == nil {
+				return nil, fmt.Errorf("op %s is not an exec", vtx)
+			}
+
+			exec.Meta.Env = append(exec.Meta.Env, env)
+			changed = true
+		}
+
+		for _, input := range op.Inputs {
+			if newDgst, found := replaced[input.Digest]; found {
+				input.Digest = newDgst
+				changed = true
+			}
+		}
+
+		if !changed {
+			res.Def[i] = dt
+			continue
+		}
+
+		newDt, err := op.Marshal()
+		if err != nil {
+			return nil, err
+		}
+
+		newDgst := digest.FromBytes(newDt)
+		res.Def[i] = newDt
+		replaced[dgst] = newDgst
+
+		if md, found := def.Metadata[dgst]; found {
+			res.Metadata[newDgst] = md
+		}
+	}
+
+	if _, found := replaced[vtx]; !found {
+		return nil, fmt.Errorf("exec %s not found", vtx)
+	}
+
+	return res, nil
+}
+
+// imageConfig returns the config of the container's image, including its
+// healthcheck as Docker would record it.
+//
+// Only command checks are recorded, since an HTTP check would depend on an
+// HTTP client being installed in the image.
+func (container *Container) imageConfig() ([]byte, error) {
+	img := dockerImage{
+		Image: specs.Image{
+			Architecture: container.Platform.Architecture,
+			OS:           container.Platform.OS,
+			OSVersion:    container.Platform.OSVersion,
+			OSFeatures:   container.Platform.OSFeatures,
+			History:      container.History,
+		},
+		Config: dockerImageConfig{
+			ImageConfig: container.Config,
+		},
+	}
+
+	if check := container.Healthcheck; check != nil && len(check.Args) > 0 {
+		img.Config.Healthcheck = &dockercontainer.HealthConfig{
+			Test:        append([]string{"CMD"}, check.Args...),
+			Interval:    time.Duration(check.Interval) * time.Second,
+			StartPeriod: time.Duration(check.StartPeriod) * time.Second,
+			Retries:     check.Retries,
+		}
+	}
+
+	return json.Marshal(img)
+}
+
+// dockerImage is an OCI image whose config may include Docker's extensions.
+type dockerImage struct {
+	specs.Image
+
+	Config dockerImageConfig `json:"config,omitempty"`
+}
+
+type dockerImageConfig struct {
+	specs.ImageConfig
+
+	Healthcheck *dockercontainer.HealthConfig `json:"Healthcheck,omitempty"`
+}
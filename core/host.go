@@ -3,8 +3,10 @@ package core
 import (
 	"context"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 
 	"github.com/dagger/dagger/core/pipeline"
 	bkclient "github.com/moby/buildkit/client"
@@ -16,6 +18,11 @@ import (
 type Host struct {
 	Workdir   string
 	DisableRW bool
+
+	// gitTreesDir holds the trees of git refs uploaded during the session,
+	// created on first use and removed by Close.
+	gitTreesMu  sync.Mutex
+	gitTreesDir string
 }
 
 func NewHost(workdir string, disableRW bool) *Host {
@@ -125,6 +132,79 @@ func (host *Host) Socket(ctx context.Context, sockPath string) (*Socket, error)
 	return NewHostSocket(absPath), nil
 }
 
+// GitHistory opens the git repository containing a directory on the host.
+func (host *Host) GitHistory(dirPath string) (*GitHistory, error) {
+	if host.DisableRW {
+		return nil, ErrHostRWDisabled
+	}
+
+	var absPath string
+	var err error
+	if filepath.IsAbs(dirPath) {
+		absPath = dirPath
+	} else {
+		absPath = filepath.Join(host.Workdir, dirPath)
+
+		if !strings.HasPrefix(absPath, host.Workdir) {
+			return nil, fmt.Errorf("path %q escapes workdir; use an absolute path instead", dirPath)
+		}
+	}
+
+	absPath, err = filepath.EvalSymlinks(absPath)
+	if err != nil {
+		return nil, fmt.Errorf("eval symlinks: %w", err)
+	}
+
+	return OpenGitHistory(absPath)
+}
+
+// GitTree returns the tree of the commit ref points to in a git repository on
+// the host. The tree is exported to a temporary directory, where it is kept
+// until the session ends, and uploaded from there.
+func (host *Host) GitTree(ctx context.Context, history *GitHistory, ref string, p pipeline.Path, platform specs.Platform) (*Directory, error) {
+	commit, err := history.Commit(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	host.gitTreesMu.Lock()
+	if host.gitTreesDir == "" {
+		host.gitTreesDir, err = os.MkdirTemp("", "dagger-git-trees-")
+	}
+	treesDir := host.gitTreesDir
+	host.gitTreesMu.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	dir := filepath.Join(treesDir, commit.Hash.String())
+	if _, err := os.Stat(dir); err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+
+		if err := history.ExportTree(commit, dir); err != nil {
+			return nil, fmt.Errorf("export tree of %q: %w", ref, err)
+		}
+	}
+
+	return host.Directory(ctx, dir, p, platform, CopyFilter{})
+}
+
+// Close removes what the host wrote for the session.
+func (host *Host) Close() error {
+	host.gitTreesMu.Lock()
+	defer host.gitTreesMu.Unlock()
+
+	if host.gitTreesDir == "" {
+		return nil
+	}
+
+	err := os.RemoveAll(host.gitTreesDir)
+	host.gitTreesDir = ""
+	return err
+}
+
 func (host *Host) Export(
 	ctx context.Context,
 	export bkclient.ExportEntry,
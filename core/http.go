@@ -0,0 +1,65 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"path"
+
+	"github.com/dagger/dagger/core/pipeline"
+	"github.com/moby/buildkit/client/llb"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// HTTPAuthHeaderPath is where the secret sent as the Authorization header of
+// an HTTP request is mounted for the shim to read.
+const HTTPAuthHeaderPath = "/run/dagger/http-auth-header"
+
+// HTTPHeader is a header sent with an HTTP request.
+type HTTPHeader struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// FetchHTTP returns a file downloaded by the shim inside the engine, for
+// requests that need headers buildkit's HTTP source can't send. If checksum is
+// set, the content must match it.
+func FetchHTTP(
+	ctx context.Context,
+	url string,
+	filename string,
+	perm fs.FileMode,
+	checksum digest.Digest,
+	headers []HTTPHeader,
+	authHeader SecretID,
+	pipeline pipeline.Path,
+	platform specs.Platform,
+	services ServiceBindings,
+) (*File, error) {
+	args := []string{
+		"fetch",
+		url,
+		path.Join(internalOutputPath, filename),
+		fmt.Sprintf("%o", perm),
+		checksum.String(),
+	}
+	for _, header := range headers {
+		args = append(args, header.Name+": "+header.Value)
+	}
+
+	runOpts := []llb.RunOption{
+		llb.Args(args),
+		llb.AddEnv("_DAGGER_INTERNAL_COMMAND", ""),
+		pipeline.LLBOpt(),
+		llb.WithCustomNamef("fetch %s", url),
+	}
+
+	if authHeader != "" {
+		runOpts = append(runOpts, llb.AddSecret(HTTPAuthHeaderPath, llb.SecretID(authHeader.String())))
+	}
+
+	st := llb.Scratch().Run(runOpts...).AddMount(internalOutputPath, llb.Scratch())
+
+	return NewFile(ctx, st, filename, pipeline, platform, services)
+}
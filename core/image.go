@@ -0,0 +1,98 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/containerd/containerd/platforms"
+	"github.com/dagger/dagger/auth"
+	"github.com/docker/distribution/reference"
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// RegistryImage describes an image in a registry, as inspected without
+// pulling it.
+type RegistryImage struct {
+	// The address the image was looked up by.
+	Address string `json:"address"`
+
+	// Whether the address resolves to an image.
+	Exists bool `json:"exists"`
+
+	// The digest the address resolves to: an index for multi-platform images,
+	// a manifest otherwise.
+	Digest *string `json:"digest"`
+
+	// The digest of the manifest for each platform the image is available for.
+	Platforms []PlatformDigest `json:"platforms"`
+
+	// The platform the config and layers below describe.
+	Platform *specs.Platform `json:"platform"`
+
+	// The digest of the manifest for the platform.
+	ManifestDigest *string `json:"manifestDigest"`
+
+	Labels  map[string]string `json:"labels"`
+	Created *string           `json:"created"`
+	Layers  []ImageLayer      `json:"layers"`
+}
+
+// ImageLayer is a layer of an image in a registry.
+type ImageLayer struct {
+	Digest    string `json:"digest"`
+	MediaType string `json:"mediaType"`
+	Size      int    `json:"size"`
+}
+
+// InspectImage looks up the image at addr in its registry, describing its
+// config and layers for the given platform.
+//
+// The lookup runs in a container so that it reaches the same registries,
+// services included, as a pull would.
+func InspectImage(
+	ctx context.Context,
+	gw bkgw.Client,
+	addr string,
+	platform specs.Platform,
+	registryAuth *auth.RegistryAuthProvider,
+	mirrors auth.RegistryMirrors,
+	secrets SecretStore,
+) (*RegistryImage, error) {
+	refName, err := reference.ParseNormalizedNamed(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+
+	ref, err := registryAuth.MirrorRef(reference.TagNameOnly(refName).String(), mirrors)
+	if err != nil {
+		return nil, err
+	}
+
+	pullName, err := reference.ParseNormalizedNamed(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	mounts, err := registryAuthMounts(ctx, registryAuth, secrets, reference.Domain(pullName))
+	if err != nil {
+		return nil, err
+	}
+
+	stdout := new(bytes.Buffer)
+	err = runInternalCommand(ctx, gw, []string{"inspect", ref, platforms.Format(platform)}, nil, nil, stdout, mounts...)
+	if err != nil {
+		return nil, fmt.Errorf("inspect %s: %w", ref, err)
+	}
+
+	var image RegistryImage
+	if err := json.Unmarshal(stdout.Bytes(), &image); err != nil {
+		return nil, fmt.Errorf("unmarshal image: %w", err)
+	}
+
+	image.Address = addr
+
+	return &image, nil
+}
@@ -1,17 +1,29 @@
 package core
 
 import (
+	"archive/tar"
+	"bytes"
 	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
 	_ "embed"
 	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
 	"errors"
 	"fmt"
 	"io"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"dagger.io/dagger"
 	"github.com/dagger/dagger/core"
@@ -532,6 +544,181 @@ func TestContainerExecRedirectStdoutStderr(t *testing.T) {
 	require.Contains(t, err.Error(), "stderr: no such file or directory")
 }
 
+func TestContainerExecExpect(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	ctr := c.Container().From("alpine:3.16.2")
+
+	failing := []string{"sh", "-c", "echo hello; echo goodbye >/dev/stderr; exit 3"}
+
+	t.Run("success by default", func(t *testing.T) {
+		_, err := ctr.WithExec(failing).ExitCode(ctx)
+		require.Error(t, err)
+	})
+
+	t.Run("failure", func(t *testing.T) {
+		res := ctr.WithExec(failing, dagger.ContainerWithExecOpts{
+			Expect: dagger.Failure,
+		}).ExecResult()
+
+		exitCode, err := res.ExitCode(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 3, exitCode)
+
+		stdout, err := res.Stdout(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "hello\n", stdout)
+
+		stderr, err := res.Stderr(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "goodbye\n", stderr)
+
+		_, err = ctr.WithExec([]string{"true"}, dagger.ContainerWithExecOpts{
+			Expect: dagger.Failure,
+		}).ExitCode(ctx)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "expected command to fail")
+	})
+
+	t.Run("any", func(t *testing.T) {
+		for cmd, code := range map[string]int{"exit 0": 0, "exit 3": 3} {
+			exitCode, err := ctr.WithExec([]string{"sh", "-c", cmd}, dagger.ContainerWithExecOpts{
+				Expect: dagger.Any,
+			}).ExecResult().ExitCode(ctx)
+			require.NoError(t, err)
+			require.Equal(t, code, exitCode)
+		}
+	})
+}
+
+func TestContainerExecResourceLimits(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	ctr := c.Container().From("alpine:3.16.2").
+		WithEnvVariable("BUST", identity.NewID())
+
+	t.Run("memory", func(t *testing.T) {
+		out, err := ctr.WithExec([]string{"sh", "-c",
+			"cat /sys/fs/cgroup/memory.max 2>/dev/null || cat /sys/fs/cgroup/memory/memory.limit_in_bytes",
+		}, dagger.ContainerWithExecOpts{
+			MemoryLimit: "64m",
+		}).Stdout(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "67108864\n", out)
+	})
+
+	t.Run("pids", func(t *testing.T) {
+		out, err := ctr.WithExec([]string{"sh", "-c",
+			"cat /sys/fs/cgroup/pids.max 2>/dev/null || cat /sys/fs/cgroup/pids/pids.max",
+		}, dagger.ContainerWithExecOpts{
+			PidsLimit: 42,
+		}).Stdout(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "42\n", out)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		res := ctr.WithExec([]string{"sh", "-c", "echo started; sleep 60"}, dagger.ContainerWithExecOpts{
+			Timeout: 1,
+			Expect:  dagger.Any,
+		}).ExecResult()
+
+		exitCode, err := res.ExitCode(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 124, exitCode)
+
+		duration, err := res.Duration(ctx)
+		require.NoError(t, err)
+		require.Less(t, duration, 30000)
+
+		stdout, err := res.Stdout(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "started\n", stdout)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := ctr.WithExec([]string{"true"}, dagger.ContainerWithExecOpts{
+			MemoryLimit: "lots",
+		}).ExitCode(ctx)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid memory limit")
+	})
+}
+
+func TestContainerExecResult(t *testing.T) {
+	t.Parallel()
+
+	res := struct {
+		Container struct {
+			From struct {
+				WithExec struct {
+					ExecResult struct {
+						ExitCode   int
+						Stdout     string
+						Stderr     string
+						StartedAt  string
+						FinishedAt string
+						Duration   int
+					}
+				}
+			}
+		}
+	}{}
+
+	err := testutil.Query(
+		`{
+			container {
+				from(address: "alpine:3.16.2") {
+					withExec(args: ["sh", "-c", "echo hello; sleep 1; echo goodbye >/dev/stderr"]) {
+						execResult {
+							exitCode
+							stdout
+							stderr
+							startedAt
+							finishedAt
+							duration
+						}
+					}
+				}
+			}
+		}`, &res, nil)
+	require.NoError(t, err)
+
+	result := res.Container.From.WithExec.ExecResult
+	require.Equal(t, 0, result.ExitCode)
+	require.Equal(t, "hello\n", result.Stdout)
+	require.Equal(t, "goodbye\n", result.Stderr)
+	require.GreaterOrEqual(t, result.Duration, 1000)
+
+	startedAt, err := time.Parse(time.RFC3339Nano, result.StartedAt)
+	require.NoError(t, err)
+	finishedAt, err := time.Parse(time.RFC3339Nano, result.FinishedAt)
+	require.NoError(t, err)
+	require.True(t, finishedAt.After(startedAt))
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	_, err = c.Container().From("alpine:3.16.2").ExecResult().ExitCode(ctx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), core.ErrContainerNoExec.Error())
+
+	redirected, err := c.Container().From("alpine:3.16.2").
+		WithExec([]string{"echo", "hello"}, dagger.ContainerWithExecOpts{
+			RedirectStdout: "/out",
+		}).
+		ExecResult().
+		Stdout(ctx)
+	require.NoError(t, err)
+	require.Empty(t, redirected)
+}
+
 func TestContainerExecWithWorkdir(t *testing.T) {
 	t.Parallel()
 
@@ -1691,6 +1878,41 @@ func TestContainerWithNewFile(t *testing.T) {
 	require.Equal(t, "some-content", contents)
 }
 
+func TestContainerWithSymlinkWithRename(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	ctr := c.Container().
+		From("alpine:3.16.2").
+		WithWorkdir("/workdir").
+		WithNewFile("build/app", dagger.ContainerWithNewFileOpts{
+			Contents: "app",
+		}).
+		WithMountedDirectory("/mnt", c.Directory().WithNewFile("data", "data"))
+
+	out, err := ctr.
+		WithRename("build/app", "/usr/local/bin/app").
+		WithSymlink("/usr/local/bin/app", "/usr/bin/app").
+		WithExec([]string{"sh", "-c", "cat /usr/bin/app; ls build"}).
+		Stdout(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "app", out)
+
+	out, err = ctr.
+		WithRename("/mnt/data", "/mnt/renamed").
+		WithSymlink("renamed", "/mnt/link").
+		WithExec([]string{"cat", "/mnt/link"}).
+		Stdout(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "data", out)
+
+	_, err = ctr.WithRename("/mnt/data", "/data").ID(ctx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "paths are on different mounts")
+}
+
 func TestContainerMountsWithoutMount(t *testing.T) {
 	t.Parallel()
 
@@ -2549,6 +2771,443 @@ func TestContainerPublish(t *testing.T) {
 	require.Equal(t, contents, "3.16.2\n")
 }
 
+func TestContainerPublishWithDigests(t *testing.T) {
+	t.Parallel()
+
+	res := struct {
+		Container struct {
+			From struct {
+				PublishWithDigests struct {
+					Refs           []string
+					Digest         string
+					ManifestDigest string
+					IndexDigest    *string
+					Platforms      []struct {
+						Platform string
+						Digest   string
+					}
+				}
+			}
+		}
+	}{}
+
+	testRef := registryRef("container-publish-digests")
+	latestRef := registryHost + "/container-publish-digests:latest"
+
+	err := testutil.Query(
+		`query Test($ref: String!, $latest: String!) {
+			container {
+				from(address: "alpine:3.16.2") {
+					publishWithDigests(
+						address: $ref,
+						addresses: [$latest],
+						annotations: [{name: "org.opencontainers.image.source", value: "https://example.com/repo"}]
+					) {
+						refs
+						digest
+						manifestDigest
+						indexDigest
+						platforms {
+							platform
+							digest
+						}
+					}
+				}
+			}
+		}`, &res, &testutil.QueryOptions{Variables: map[string]any{
+			"ref":    testRef,
+			"latest": latestRef,
+		}})
+	require.NoError(t, err)
+
+	published := res.Container.From.PublishWithDigests
+	require.Contains(t, published.Digest, "sha256:")
+	require.Equal(t, published.Digest, published.ManifestDigest)
+	require.Nil(t, published.IndexDigest)
+	require.Len(t, published.Platforms, 1)
+	require.Equal(t, published.Digest, published.Platforms[0].Digest)
+	require.Len(t, published.Refs, 2)
+	for _, ref := range published.Refs {
+		require.True(t, strings.HasSuffix(ref, "@"+published.Digest))
+	}
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	contents, err := c.Container().
+		From(latestRef).Rootfs().File("/etc/alpine-release").Contents(ctx)
+	require.NoError(t, err)
+	require.Equal(t, contents, "3.16.2\n")
+
+	manifest, err := registryGet(ctx, c, "/v2/container-publish-digests/manifests/"+published.Digest, "application/vnd.oci.image.manifest.v1+json")
+	require.NoError(t, err)
+	require.Contains(t, manifest, `"org.opencontainers.image.source":"https://example.com/repo"`)
+}
+
+func TestContainerPublishMediaTypes(t *testing.T) {
+	c, ctx := connect(t)
+	defer c.Close()
+
+	ctr := c.Container().From("alpine:3.16.2")
+
+	pushedRef, err := ctr.Publish(ctx, registryRef("container-publish-docker"), dagger.ContainerPublishOpts{
+		MediaTypes:        dagger.DockerMediaTypes,
+		ForcedCompression: dagger.LayerZstd,
+	})
+	require.NoError(t, err)
+
+	contents, err := c.Container().
+		From(pushedRef).Rootfs().File("/etc/alpine-release").Contents(ctx)
+	require.NoError(t, err)
+	require.Equal(t, contents, "3.16.2\n")
+
+	_, err = ctr.Publish(ctx, registryRef("container-publish-docker"), dagger.ContainerPublishOpts{
+		MediaTypes: dagger.DockerMediaTypes,
+		Annotations: []dagger.ImageAnnotation{
+			{Name: "org.opencontainers.image.source", Value: "https://example.com/repo"},
+		},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "annotations require OCI media types")
+}
+
+func TestContainerPublishSigned(t *testing.T) {
+	c, ctx := connect(t)
+	defer c.Close()
+
+	privKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(privKey)
+	require.NoError(t, err)
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	testRef := registryRef("container-publish-signed")
+	published := c.Container().
+		From("alpine:3.16.2").
+		PublishWithDigests(testRef, dagger.ContainerPublishWithDigestsOpts{
+			Sign: dagger.ImageSigningKey{
+				Key: c.SetSecret("signing-key", string(keyPEM)),
+			},
+		})
+
+	signatures, err := published.Signatures(ctx)
+	require.NoError(t, err)
+	require.Len(t, signatures, 1)
+
+	repo, sigTag, ok := strings.Cut(strings.TrimPrefix(signatures[0], registryHost+"/"), ":")
+	require.True(t, ok)
+	require.Equal(t, "container-publish-signed", repo)
+	require.True(t, strings.HasSuffix(sigTag, ".sig"))
+	imageDigest := strings.Replace(strings.TrimSuffix(sigTag, ".sig"), "sha256-", "sha256:", 1)
+
+	manifestJSON, err := registryGet(ctx, c, "/v2/"+repo+"/manifests/"+sigTag, "application/vnd.oci.image.manifest.v1+json")
+	require.NoError(t, err)
+
+	var manifest struct {
+		Layers []struct {
+			MediaType   string
+			Digest      string
+			Annotations map[string]string
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(manifestJSON), &manifest))
+	require.Len(t, manifest.Layers, 1)
+	require.Equal(t, "application/vnd.dev.cosign.simplesigning.v1+json", manifest.Layers[0].MediaType)
+
+	payload, err := registryGet(ctx, c, "/v2/"+repo+"/blobs/"+manifest.Layers[0].Digest, "")
+	require.NoError(t, err)
+	require.Contains(t, payload, `"docker-manifest-digest":"`+imageDigest+`"`)
+	require.Contains(t, payload, `"docker-reference":"`+registryHost+"/"+repo+`"`)
+
+	sig, err := base64.StdEncoding.DecodeString(manifest.Layers[0].Annotations["dev.cosignproject.cosign/signature"])
+	require.NoError(t, err)
+	sum := sha256.Sum256([]byte(payload))
+	require.True(t, ecdsa.VerifyASN1(&privKey.PublicKey, sum[:], sig))
+}
+
+func TestContainerWithAttestation(t *testing.T) {
+	c, ctx := connect(t)
+	defer c.Close()
+
+	predicateType := "https://example.com/predicate/v1"
+	predicate := c.Directory().
+		WithNewFile("predicate.json", `{"hello":"world"}`).
+		File("predicate.json")
+
+	testRef := registryRef("container-attestation")
+	published := c.Container().
+		From("alpine:3.16.2").
+		WithAttestation(predicateType, predicate).
+		PublishWithDigests(testRef)
+
+	platforms, err := published.Platforms(ctx)
+	require.NoError(t, err)
+	require.Len(t, platforms, 1)
+
+	indexDigest, err := published.IndexDigest(ctx)
+	require.NoError(t, err)
+	require.Contains(t, indexDigest, "sha256:")
+
+	repo := "container-attestation"
+	indexJSON, err := registryGet(ctx, c, "/v2/"+repo+"/manifests/"+indexDigest, "application/vnd.oci.image.index.v1+json")
+	require.NoError(t, err)
+
+	var index struct {
+		Manifests []struct {
+			Digest      string
+			Annotations map[string]string
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(indexJSON), &index))
+
+	var attestationDigest string
+	for _, desc := range index.Manifests {
+		if desc.Annotations["vnd.docker.reference.type"] == "attestation-manifest" {
+			attestationDigest = desc.Digest
+		}
+	}
+	require.NotEmpty(t, attestationDigest)
+
+	attestationJSON, err := registryGet(ctx, c, "/v2/"+repo+"/manifests/"+attestationDigest, "application/vnd.oci.image.manifest.v1+json")
+	require.NoError(t, err)
+	require.Contains(t, attestationJSON, `"in-toto.io/predicate-type":"`+predicateType+`"`)
+
+	// attestation manifests are not runnable images, so the pushed image must
+	// still pull as the container's own platform
+	contents, err := c.Container().
+		From(testRef).Rootfs().File("/etc/alpine-release").Contents(ctx)
+	require.NoError(t, err)
+	require.Equal(t, contents, "3.16.2\n")
+}
+
+func TestContainerSbom(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	t.Run("apk and go binaries as spdx", func(t *testing.T) {
+		contents, err := c.Container().
+			From("registry:2").
+			Sbom().
+			Contents(ctx)
+		require.NoError(t, err)
+
+		var doc struct {
+			SPDXVersion string
+			Packages    []struct {
+				Name         string
+				ExternalRefs []struct {
+					ReferenceLocator string
+				}
+			}
+		}
+		require.NoError(t, json.Unmarshal([]byte(contents), &doc))
+		require.Equal(t, "SPDX-2.3", doc.SPDXVersion)
+
+		purls := []string{}
+		for _, pkg := range doc.Packages {
+			purls = append(purls, pkg.ExternalRefs[0].ReferenceLocator)
+		}
+		require.Contains(t, strings.Join(purls, "\n"), "pkg:apk/alpine/musl@")
+		require.Contains(t, strings.Join(purls, "\n"), "pkg:golang/stdlib@go")
+	})
+
+	t.Run("dpkg as cyclonedx", func(t *testing.T) {
+		contents, err := c.Container().
+			From("nginx").
+			Sbom(dagger.ContainerSbomOpts{Format: dagger.Cyclonedx}).
+			Contents(ctx)
+		require.NoError(t, err)
+
+		var doc struct {
+			BOMFormat  string
+			Components []struct {
+				Name string
+				PURL string
+			}
+		}
+		require.NoError(t, json.Unmarshal([]byte(contents), &doc))
+		require.Equal(t, "CycloneDX", doc.BOMFormat)
+
+		var found bool
+		for _, component := range doc.Components {
+			if component.Name == "nginx" {
+				found = true
+				require.Contains(t, component.PURL, "pkg:deb/debian/nginx@")
+			}
+		}
+		require.True(t, found)
+	})
+
+	t.Run("stable across runs", func(t *testing.T) {
+		ctr := c.Container().From("alpine:3.16.2")
+
+		first, err := ctr.Sbom().Contents(ctx)
+		require.NoError(t, err)
+
+		second, err := ctr.WithEnvVariable("FOO", "bar").Sbom().Contents(ctx)
+		require.NoError(t, err)
+		require.Equal(t, first, second)
+	})
+}
+
+func TestContainerWithSbom(t *testing.T) {
+	c, ctx := connect(t)
+	defer c.Close()
+
+	testRef := registryRef("container-with-sbom")
+	indexDigest, err := c.Container().
+		From("alpine:3.16.2").
+		WithSbom().
+		PublishWithDigests(testRef).
+		IndexDigest(ctx)
+	require.NoError(t, err)
+	require.Contains(t, indexDigest, "sha256:")
+
+	repo := "container-with-sbom"
+	indexJSON, err := registryGet(ctx, c, "/v2/"+repo+"/manifests/"+indexDigest, "application/vnd.oci.image.index.v1+json")
+	require.NoError(t, err)
+
+	var index struct {
+		Manifests []struct {
+			Digest      string
+			Annotations map[string]string
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(indexJSON), &index))
+
+	var attestationDigest string
+	for _, desc := range index.Manifests {
+		if desc.Annotations["vnd.docker.reference.type"] == "attestation-manifest" {
+			attestationDigest = desc.Digest
+		}
+	}
+	require.NotEmpty(t, attestationDigest)
+
+	attestationJSON, err := registryGet(ctx, c, "/v2/"+repo+"/manifests/"+attestationDigest, "application/vnd.oci.image.manifest.v1+json")
+	require.NoError(t, err)
+	require.Contains(t, attestationJSON, `"in-toto.io/predicate-type":"https://spdx.dev/Document"`)
+}
+
+func TestContainerSquash(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	testRef := registryRef("container-squash")
+	_, err := c.Container().
+		From("alpine:3.16.2").
+		WithExec([]string{"touch", "/a"}).
+		WithExec([]string{"touch", "/b"}).
+		WithExec([]string{"rm", "/etc/motd"}).
+		Squash().
+		Publish(ctx, testRef)
+	require.NoError(t, err)
+
+	layers, err := c.Image(testRef).Layers(ctx)
+	require.NoError(t, err)
+	require.Len(t, layers, 2)
+
+	_, err = c.Container().
+		From(testRef).
+		WithExec([]string{"sh", "-c", "test -e /a && test -e /b && test ! -e /etc/motd"}).
+		ExitCode(ctx)
+	require.NoError(t, err)
+
+	t.Run("without base image", func(t *testing.T) {
+		testRef := registryRef("container-squash-rootfs")
+		_, err := c.Container().
+			WithRootfs(c.Container().From("alpine:3.16.2").Rootfs()).
+			WithExec([]string{"touch", "/a"}).
+			Squash().
+			Publish(ctx, testRef)
+		require.NoError(t, err)
+
+		layers, err := c.Image(testRef).Layers(ctx)
+		require.NoError(t, err)
+		require.Len(t, layers, 1)
+	})
+}
+
+func TestContainerWithHistory(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	repo := "container-with-history"
+	digest, err := c.Container().
+		From("alpine:3.16.2").
+		WithExec([]string{"touch", "/a"}).
+		WithExec([]string{"touch", "/b"}).
+		WithHistory("touch /a /b", dagger.ContainerWithHistoryOpts{
+			Comment: "add files",
+		}).
+		WithEnvVariable("FOO", "bar").
+		WithHistory("ENV FOO=bar", dagger.ContainerWithHistoryOpts{
+			EmptyLayer: true,
+		}).
+		PublishWithDigests(registryRef(repo)).
+		Digest(ctx)
+	require.NoError(t, err)
+
+	manifestJSON, err := registryGet(ctx, c, "/v2/"+repo+"/manifests/"+digest, "application/vnd.oci.image.manifest.v1+json")
+	require.NoError(t, err)
+
+	var manifest struct {
+		Config struct {
+			Digest string
+		}
+		Layers []struct {
+			Digest string
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(manifestJSON), &manifest))
+	require.Len(t, manifest.Layers, 2)
+
+	configJSON, err := registryGet(ctx, c, "/v2/"+repo+"/blobs/"+manifest.Config.Digest, "")
+	require.NoError(t, err)
+
+	var config struct {
+		History []struct {
+			CreatedBy  string `json:"created_by"`
+			Comment    string `json:"comment"`
+			EmptyLayer bool   `json:"empty_layer"`
+		}
+	}
+	require.NoError(t, json.Unmarshal([]byte(configJSON), &config))
+	require.Len(t, config.History, 4)
+
+	// alpine's own history is kept
+	require.Contains(t, config.History[0].CreatedBy, "ADD file:")
+	require.True(t, config.History[1].EmptyLayer)
+
+	require.Equal(t, "touch /a /b", config.History[2].CreatedBy)
+	require.Equal(t, "add files", config.History[2].Comment)
+	require.False(t, config.History[2].EmptyLayer)
+
+	require.Equal(t, "ENV FOO=bar", config.History[3].CreatedBy)
+	require.True(t, config.History[3].EmptyLayer)
+}
+
+// registryGet fetches a path from the test registry, which is only reachable
+// from containers.
+func registryGet(ctx context.Context, c *dagger.Client, path, accept string) (string, error) {
+	args := []string{"wget", "-q", "-O-"}
+	if accept != "" {
+		args = append(args, "--header", "Accept: "+accept)
+	}
+	args = append(args, "http://"+registryHost+path)
+
+	return c.Container().
+		From("alpine:3.16.2").
+		WithEnvVariable("CACHEBUST", identity.NewID()).
+		WithExec(args).
+		Stdout(ctx)
+}
+
 func TestExecFromScratch(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -2689,6 +3348,145 @@ func TestContainerImport(t *testing.T) {
 	})
 }
 
+func TestContainerExportToHost(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	sock, images := fakeDockerAPI(t)
+	socket := c.Host().UnixSocket(sock)
+
+	ok, err := c.Container().
+		From("alpine:3.16.2").
+		WithNewFile("/hello", dagger.ContainerWithNewFileOpts{
+			Contents: "world",
+		}).
+		ExportToHost(ctx, "dagger-test:export", dagger.ContainerExportToHostOpts{
+			Socket: socket,
+		})
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.Contains(t, images.tags(), "dagger-test:export")
+
+	t.Run("host image", func(t *testing.T) {
+		out, err := c.HostImage("dagger-test:export", dagger.HostImageOpts{
+			Socket: socket,
+		}).
+			WithExec([]string{"cat", "/hello"}).
+			Stdout(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "world", out)
+	})
+
+	t.Run("missing host image", func(t *testing.T) {
+		_, err := c.HostImage("dagger-test:missing", dagger.HostImageOpts{
+			Socket: socket,
+		}).ID(ctx)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "No such image")
+	})
+}
+
+// fakeImageStore holds the image tarballs loaded through a fake Docker API.
+type fakeImageStore struct {
+	mu       sync.Mutex
+	tarballs map[string][]byte
+}
+
+func (store *fakeImageStore) tags() []string {
+	store.mu.Lock()
+	defer store.mu.Unlock()
+
+	tags := []string{}
+	for tag := range store.tarballs {
+		tags = append(tags, tag)
+	}
+	return tags
+}
+
+// fakeDockerAPI serves the image load and save endpoints of the Docker API on
+// a unix socket.
+func fakeDockerAPI(t *testing.T) (string, *fakeImageStore) {
+	t.Helper()
+
+	store := &fakeImageStore{
+		tarballs: map[string][]byte{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/_ping", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("API-Version", "1.41")
+		fmt.Fprint(w, "OK")
+	})
+	mux.HandleFunc("/v1.41/images/load", func(w http.ResponseWriter, r *http.Request) {
+		tarball, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var manifest []struct {
+			RepoTags []string
+		}
+		tr := tar.NewReader(bytes.NewReader(tarball))
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				http.Error(w, "no manifest.json in tarball", http.StatusBadRequest)
+				return
+			}
+			if hdr.Name == "manifest.json" {
+				if err := json.NewDecoder(tr).Decode(&manifest); err != nil {
+					http.Error(w, err.Error(), http.StatusBadRequest)
+					return
+				}
+				break
+			}
+		}
+
+		store.mu.Lock()
+		for _, m := range manifest {
+			for _, tag := range m.RepoTags {
+				store.tarballs[tag] = tarball
+			}
+		}
+		store.mu.Unlock()
+
+		fmt.Fprintln(w, `{"stream":"Loaded image"}`)
+	})
+	mux.HandleFunc("/v1.41/images/get", func(w http.ResponseWriter, r *http.Request) {
+		ref := r.URL.Query().Get("names")
+
+		store.mu.Lock()
+		tarball, found := store.tarballs[ref]
+		store.mu.Unlock()
+
+		if !found {
+			http.Error(w, `{"message":"No such image: `+ref+`"}`, http.StatusNotFound)
+			return
+		}
+
+		w.Write(tarball)
+	})
+
+	sock := filepath.Join(t.TempDir(), "docker.sock")
+	l, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+
+	srv := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go srv.Serve(l)
+	t.Cleanup(func() {
+		srv.Close()
+	})
+
+	return sock, store
+}
+
 func TestContainerMultiPlatformExport(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -123,6 +123,97 @@ func TestDirectoryEntriesOfPath(t *testing.T) {
 	require.Equal(t, []string{"sub-file"}, res.Directory.WithNewFile.WithNewFile.Entries)
 }
 
+func TestDirectoryGlob(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	dir := c.Directory().
+		WithNewFile("go.mod", "").
+		WithNewFile("main.go", "").
+		WithNewFile("pkg/a/go.mod", "").
+		WithNewFile("pkg/a/a.go", "").
+		WithNewFile("pkg/b/b.go", "").
+		WithNewFile("pkg/b/c/go.mod", "")
+
+	for pattern, expected := range map[string][]string{
+		"*.go":          {"main.go"},
+		"**/go.mod":     {"go.mod", "pkg/a/go.mod", "pkg/b/c/go.mod"},
+		"pkg/*/*.go":    {"pkg/a/a.go", "pkg/b/b.go"},
+		"pkg/**":        {"pkg", "pkg/a", "pkg/a/a.go", "pkg/a/go.mod", "pkg/b", "pkg/b/b.go", "pkg/b/c", "pkg/b/c/go.mod"},
+		"pkg/*":         {"pkg/a", "pkg/b"},
+		"nothing/**/*":  {},
+		"pkg/b/c/go.mo": {},
+	} {
+		pattern, expected := pattern, expected
+		t.Run(pattern, func(t *testing.T) {
+			matches, err := dir.Glob(ctx, pattern)
+			require.NoError(t, err)
+			require.ElementsMatch(t, expected, matches)
+		})
+	}
+
+	matches, err := dir.Directory("pkg").Glob(ctx, "**/*.go")
+	require.NoError(t, err)
+	require.ElementsMatch(t, []string{"a/a.go", "b/b.go"}, matches)
+
+	_, err = dir.Glob(ctx, "[")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "invalid pattern")
+}
+
+func TestDirectoryStat(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	dir := c.Directory().
+		WithNewFile("sub/script.sh", "echo hi", dagger.DirectoryWithNewFileOpts{
+			Permissions: 0o755,
+		}).
+		WithTimestamps(1672531199).
+		WithSymlink("sub/script.sh", "link")
+
+	stat := dir.Stat("sub/script.sh")
+
+	name, err := stat.Name(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "script.sh", name)
+
+	size, err := stat.Size(ctx)
+	require.NoError(t, err)
+	require.Equal(t, len("echo hi"), size)
+
+	mode, err := stat.Mode(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0o755, mode)
+
+	uid, err := stat.UID(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, uid)
+
+	modifiedAt, err := stat.ModifiedAt(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "2022-12-31T23:59:59Z", modifiedAt)
+
+	isDir, err := dir.Stat("sub").IsDirectory(ctx)
+	require.NoError(t, err)
+	require.True(t, isDir)
+
+	target, err := dir.Stat("link").LinkTarget(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "sub/script.sh", target)
+
+	fileMode, err := dir.File("sub/script.sh").Stat().Mode(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0o755, fileMode)
+
+	_, err = dir.Stat("missing").Size(ctx)
+	require.Error(t, err)
+}
+
 func TestDirectoryDirectory(t *testing.T) {
 	t.Parallel()
 
@@ -509,6 +600,65 @@ func TestDirectoryWithoutDirectoryWithoutFile(t *testing.T) {
 	require.Equal(t, []string{"some-dir"}, entries)
 }
 
+func TestDirectoryWithSymlink(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	dir := c.Directory().
+		WithNewFile("lib/data.txt", "some-content").
+		WithSymlink("../lib/data.txt", "bin/data").
+		WithSymlink("/nowhere", "dangling")
+
+	out, err := c.Container().From("alpine:3.16.2").
+		WithMountedDirectory("/src", dir).
+		WithExec([]string{"sh", "-c", "readlink /src/bin/data; cat /src/bin/data; echo; readlink /src/dangling"}).
+		Stdout(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "../lib/data.txt\nsome-content\n/nowhere\n", out)
+
+	_, err = c.Directory().WithSymlink("foo", "../escape").ID(ctx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cannot create symlink outside parent")
+}
+
+func TestDirectoryWithRename(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	dir := c.Directory().
+		WithNewFile("build/app", "app").
+		WithNewFile("build/lib/util", "util").
+		WithNewDirectory("bin")
+
+	renamed := dir.WithRename("build/app", "bin/app")
+
+	contents, err := renamed.File("bin/app").Contents(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "app", contents)
+
+	entries, err := renamed.Entries(ctx, dagger.DirectoryEntriesOpts{Path: "build"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"lib"}, entries)
+
+	// moving into an existing directory
+	entries, err = dir.WithRename("build/lib", "bin").Entries(ctx, dagger.DirectoryEntriesOpts{Path: "bin"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"lib"}, entries)
+
+	// moving a directory to a new path
+	contents, err = dir.WithRename("build", "out").File("out/lib/util").Contents(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "util", contents)
+
+	_, err = dir.WithRename("build", "build/nested").ID(ctx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "into itself")
+}
+
 func TestDirectoryDiff(t *testing.T) {
 	t.Parallel()
 
@@ -817,3 +967,162 @@ func TestDirectoryWithFileExceedingLength(t *testing.T) {
 	require.Error(t, err)
 	require.Contains(t, err.Error(), "File name length exceeds the maximum supported 255 characters")
 }
+
+func TestDirectoryWithTemplate(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	dir := c.Directory().
+		WithNewFile("config.yaml.tmpl", "version: {{ .VERSION }}\nname: {{ .NAME }}\n", dagger.DirectoryWithNewFileOpts{
+			Permissions: 0o600,
+		})
+
+	vars := []dagger.TemplateVariable{
+		{Name: "VERSION", Value: "1.2.3"},
+		{Name: "NAME", Value: "app"},
+	}
+
+	t.Run("in place", func(t *testing.T) {
+		rendered := dir.WithTemplate("config.yaml.tmpl", dagger.DirectoryWithTemplateOpts{
+			Variables: vars,
+		})
+
+		contents, err := rendered.File("config.yaml.tmpl").Contents(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "version: 1.2.3\nname: app\n", contents)
+
+		mode, err := rendered.Stat("config.yaml.tmpl").Mode(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 0o600, mode)
+	})
+
+	t.Run("to dest", func(t *testing.T) {
+		rendered := dir.WithTemplate("config.yaml.tmpl", dagger.DirectoryWithTemplateOpts{
+			Dest:      "out/config.yaml",
+			Variables: vars,
+		})
+
+		contents, err := rendered.File("out/config.yaml").Contents(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "version: 1.2.3\nname: app\n", contents)
+
+		contents, err = rendered.File("config.yaml.tmpl").Contents(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "version: {{ .VERSION }}\nname: {{ .NAME }}\n", contents)
+	})
+
+	t.Run("missing variable", func(t *testing.T) {
+		_, err := dir.WithTemplate("config.yaml.tmpl", dagger.DirectoryWithTemplateOpts{
+			Variables: vars[:1],
+		}).ID(ctx)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "render template")
+	})
+}
+
+func TestDirectoryAsTarball(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	dir := c.Directory().
+		WithNewFile("bin/app", "#!/bin/sh\necho hello\n", dagger.DirectoryWithNewFileOpts{
+			Permissions: 0o755,
+		}).
+		WithNewFile("README.md", "read me\n").
+		WithNewDirectory("empty").
+		WithSymlink("bin/app", "app")
+
+	for _, compression := range []dagger.ArchiveCompression{
+		dagger.Uncompressed,
+		dagger.Gzip,
+		dagger.Zstd,
+		dagger.Zip,
+	} {
+		compression := compression
+		t.Run(string(compression), func(t *testing.T) {
+			t.Parallel()
+
+			unpacked := dir.AsTarball(dagger.DirectoryAsTarballOpts{
+				Compression: compression,
+			}).Unpack()
+
+			entries, err := unpacked.Glob(ctx, "**")
+			require.NoError(t, err)
+			require.ElementsMatch(t, []string{"README.md", "app", "bin", "bin/app", "empty"}, entries)
+
+			contents, err := unpacked.File("bin/app").Contents(ctx)
+			require.NoError(t, err)
+			require.Equal(t, "#!/bin/sh\necho hello\n", contents)
+
+			mode, err := unpacked.Stat("bin/app").Mode(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 0o755, mode)
+
+			target, err := unpacked.Stat("app").LinkTarget(ctx)
+			require.NoError(t, err)
+			require.Equal(t, "bin/app", target)
+		})
+	}
+
+	t.Run("readable by tar", func(t *testing.T) {
+		t.Parallel()
+
+		out, err := c.Container().From("alpine:3.16.2").
+			WithMountedFile("/archive.tar.gz", dir.AsTarball(dagger.DirectoryAsTarballOpts{
+				Compression: dagger.Gzip,
+			})).
+			WithExec([]string{"tar", "xzf", "/archive.tar.gz", "-C", "/tmp"}).
+			WithExec([]string{"/tmp/app"}).
+			Stdout(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "hello\n", out)
+	})
+}
+
+func TestDirectoryDigest(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	dir := c.Directory().
+		WithNewFile("a/b.txt", "b\n").
+		WithNewFile("c.txt", "c\n")
+
+	dgst, err := dir.Digest(ctx)
+	require.NoError(t, err)
+	require.Regexp(t, `^sha256:[0-9a-f]{64}$`, dgst)
+
+	t.Run("ignores timestamps", func(t *testing.T) {
+		stamped, err := dir.WithTimestamps(1672531199).Digest(ctx)
+		require.NoError(t, err)
+		require.Equal(t, dgst, stamped)
+	})
+
+	t.Run("ignores how the tree was built", func(t *testing.T) {
+		rebuilt, err := c.Container().From("alpine:3.16.2").
+			WithExec([]string{"sh", "-c", "mkdir -p /out/a && echo c > /out/c.txt && echo b > /out/a/b.txt && chmod 644 /out/*.txt /out/a/*.txt"}).
+			Directory("/out").
+			Digest(ctx)
+		require.NoError(t, err)
+		require.Equal(t, dgst, rebuilt)
+	})
+
+	t.Run("changes with contents", func(t *testing.T) {
+		changed, err := dir.WithNewFile("c.txt", "changed\n").Digest(ctx)
+		require.NoError(t, err)
+		require.NotEqual(t, dgst, changed)
+	})
+
+	t.Run("changes with permissions", func(t *testing.T) {
+		changed, err := dir.WithNewFile("c.txt", "c\n", dagger.DirectoryWithNewFileOpts{
+			Permissions: 0o600,
+		}).Digest(ctx)
+		require.NoError(t, err)
+		require.NotEqual(t, dgst, changed)
+	})
+}
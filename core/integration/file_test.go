@@ -192,3 +192,131 @@ func TestFileWithTimestamps(t *testing.T) {
 	require.Contains(t, ls, "Access: 1985-10-26 08:15:00.000000000 +0000")
 	require.Contains(t, ls, "Modify: 1985-10-26 08:15:00.000000000 +0000")
 }
+
+func TestFileWithReplaced(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	file := c.Directory().
+		WithNewFile("version.go", "const Version = \"0.0.0-dev\" // 0.0.0-dev", dagger.DirectoryWithNewFileOpts{
+			Permissions: 0o600,
+		}).
+		File("version.go")
+
+	contents, err := file.WithReplaced("0.0.0-dev", "1.2.3").Contents(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "const Version = \"1.2.3\" // 0.0.0-dev", contents)
+
+	contents, err = file.WithReplaced("0.0.0-dev", "1.2.3", dagger.FileWithReplacedOpts{
+		All: true,
+	}).Contents(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "const Version = \"1.2.3\" // 1.2.3", contents)
+
+	mode, err := file.WithReplaced("0.0.0-dev", "1.2.3").Stat().Mode(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0o600, mode)
+
+	_, err = file.WithReplaced("9.9.9", "1.2.3").Contents(ctx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "not found")
+}
+
+func TestFileWithAppended(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	file := c.Directory().
+		WithNewFile("profile", "export A=1\n").
+		File("profile").
+		WithAppended("export B=2\n").
+		WithAppended("export C=3\n")
+
+	contents, err := file.Contents(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "export A=1\nexport B=2\nexport C=3\n", contents)
+
+	out, err := c.Container().From("alpine:3.16.2").
+		WithMountedFile("/profile", file).
+		WithExec([]string{"sh", "-c", ". /profile; echo $A$B$C"}).
+		Stdout(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "123\n", out)
+}
+
+func TestFileUnpack(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	t.Run("tarball", func(t *testing.T) {
+		tarball := c.Container().From("alpine:3.16.2").
+			WithExec([]string{"sh", "-c", "mkdir -p /src/sub && echo hi > /src/sub/hello && tar czf /archive.tgz -C /src ."}).
+			File("/archive.tgz")
+
+		contents, err := tarball.Unpack().File("sub/hello").Contents(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "hi\n", contents)
+	})
+
+	t.Run("not an archive", func(t *testing.T) {
+		_, err := c.Directory().
+			WithNewFile("README.md", "read me\n").
+			File("README.md").
+			Unpack().
+			Entries(ctx)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "not a tar or zip archive")
+	})
+}
+
+func TestFileDigest(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	file := c.Directory().WithNewFile("hello", "hello\n").File("hello")
+
+	dgst, err := file.Digest(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "sha256:5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03", dgst)
+
+	dgst, err = file.Digest(ctx, dagger.FileDigestOpts{
+		Algorithm: dagger.Sha512,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "sha512:e7c22b994c59d9cf2b48e549b1e24666636045930d3da7c1acb299d1c3b7f931f94aae41edda2c2b207a36e10f8bcb8d45223e54878f5b316e7ce3b6bc019629", dgst)
+}
+
+func TestFileEditsKeepMode(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	file := c.Container().From("alpine:3.16.2").
+		WithExec([]string{"sh", "-c", "echo 'echo 0.0.0-dev' > /tool && chmod 4755 /tool && chown 1000:1000 /tool"}).
+		File("/tool")
+
+	for name, edited := range map[string]*dagger.File{
+		"replaced": file.WithReplaced("0.0.0-dev", "1.2.3"),
+		"appended": file.WithAppended("echo done\n"),
+	} {
+		edited := edited
+		t.Run(name, func(t *testing.T) {
+			mode, err := edited.Stat().Mode(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 0o4755, mode)
+
+			uid, err := edited.Stat().UID(ctx)
+			require.NoError(t, err)
+			require.Equal(t, 1000, uid)
+		})
+	}
+}
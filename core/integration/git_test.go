@@ -191,3 +191,250 @@ func TestGitKeepGitDir(t *testing.T) {
 		require.NotContains(t, ent, ".git")
 	})
 }
+
+func TestGitHistory(t *testing.T) {
+	checkNotDisabled(t, engine.ServicesDNSEnvName)
+
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	const gitPort = 9418
+	gitDaemon := c.Container().
+		From("alpine:3.16.2").
+		WithExec([]string{"apk", "add", "git", "git-daemon"}).
+		WithNewFile("/root/start.sh", dagger.ContainerWithNewFileOpts{
+			Contents: `#!/bin/sh
+
+set -e -u -x
+
+cd /root
+
+git config --global user.email "root@localhost"
+git config --global user.name "Test User"
+
+mkdir repo srv
+
+cd repo
+	git init
+	git branch -m main
+	echo init > README.md
+	git add README.md
+	git commit -m "init"
+	git tag v1
+
+	git checkout -b feature
+	echo b > b.txt
+	git add b.txt
+	git commit -m "add b"
+
+	git checkout main
+	echo a > a.txt
+	git add a.txt
+	git commit -m "add a"
+cd ..
+
+cd srv
+	git clone --bare ../repo repo.git
+cd ..
+
+git daemon --verbose --export-all --base-path=/root/srv
+`,
+		}).
+		WithExposedPort(gitPort).
+		WithExec([]string{"sh", "/root/start.sh"})
+
+	gitHost, err := gitDaemon.Hostname(ctx)
+	require.NoError(t, err)
+
+	repo := c.Git(fmt.Sprintf("git://%s/repo.git", gitHost), dagger.GitOpts{
+		ExperimentalServiceHost: gitDaemon,
+	})
+
+	t.Run("commitInfo", func(t *testing.T) {
+		info := repo.Tag("v1").CommitInfo()
+
+		msg, err := info.Message(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "init\n", msg)
+
+		author, err := info.Author(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "Test User <root@localhost>", author)
+
+		parents, err := info.Parents(ctx)
+		require.NoError(t, err)
+		require.Empty(t, parents)
+
+		hash, err := info.Hash(ctx)
+		require.NoError(t, err)
+
+		parents, err = repo.Branch("main").CommitInfo().Parents(ctx)
+		require.NoError(t, err)
+		require.Equal(t, []string{hash}, parents)
+	})
+
+	t.Run("log", func(t *testing.T) {
+		commits, err := repo.Log(ctx, "main", dagger.GitRepositoryLogOpts{
+			From: "v1",
+		})
+		require.NoError(t, err)
+		require.Len(t, commits, 1)
+
+		msg, err := commits[0].Message(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "add a\n", msg)
+
+		commits, err = repo.Log(ctx, "main")
+		require.NoError(t, err)
+		require.Len(t, commits, 2)
+	})
+
+	t.Run("changedFiles", func(t *testing.T) {
+		files, err := repo.Branch("feature").ChangedFiles(ctx, "main")
+		require.NoError(t, err)
+		require.Equal(t, []string{"b.txt"}, files)
+
+		files, err = repo.Branch("main").ChangedFiles(ctx, "v1")
+		require.NoError(t, err)
+		require.Equal(t, []string{"a.txt"}, files)
+	})
+}
+
+func TestGitSubmodulesAndDepth(t *testing.T) {
+	checkNotDisabled(t, engine.ServicesDNSEnvName)
+
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	const gitPort = 9418
+	gitDaemon := c.Container().
+		From("alpine:3.16.2").
+		WithExec([]string{"apk", "add", "git", "git-daemon"}).
+		WithNewFile("/root/start.sh", dagger.ContainerWithNewFileOpts{
+			Contents: `#!/bin/sh
+
+set -e -u -x
+
+cd /root
+
+git config --global user.email "root@localhost"
+git config --global user.name "Test User"
+git config --global protocol.file.allow always
+
+mkdir sub repo srv
+
+cd sub
+	git init
+	git branch -m main
+	echo sub > sub.txt
+	git add sub.txt
+	git commit -m "sub"
+cd ..
+
+cd srv
+	git clone --bare ../sub sub.git
+cd ..
+
+cd repo
+	git init
+	git branch -m main
+	echo one > README.md
+	git add README.md
+	git commit -m "one"
+	echo two > README.md
+	git commit -am "two"
+	git submodule add ../sub.git sub
+	git commit -m "three"
+cd ..
+
+cd srv
+	git clone --bare ../repo repo.git
+cd ..
+
+git daemon --verbose --export-all --base-path=/root/srv
+`,
+		}).
+		WithExposedPort(gitPort).
+		WithExec([]string{"sh", "/root/start.sh"})
+
+	gitHost, err := gitDaemon.Hostname(ctx)
+	require.NoError(t, err)
+
+	repoURL := fmt.Sprintf("git://%s/repo.git", gitHost)
+
+	t.Run("submodules by default", func(t *testing.T) {
+		contents, err := c.Git(repoURL, dagger.GitOpts{
+			ExperimentalServiceHost: gitDaemon,
+		}).Branch("main").Tree().File("sub/sub.txt").Contents(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "sub\n", contents)
+	})
+
+	t.Run("without submodules", func(t *testing.T) {
+		svcID, err := gitDaemon.ID(ctx)
+		require.NoError(t, err)
+
+		var res struct {
+			Git struct {
+				Branch struct {
+					Tree struct {
+						Entries []string
+						Sub     struct {
+							Entries []string
+						}
+					}
+				}
+			}
+		}
+		err = testutil.Query(
+			`query Test($url: String!, $svc: ContainerID!) {
+				git(url: $url, submodules: false, experimentalServiceHost: $svc) {
+					branch(name: "main") {
+						tree {
+							entries
+							sub: directory(path: "sub") {
+								entries
+							}
+						}
+					}
+				}
+			}`, &res, &testutil.QueryOptions{
+				Variables: map[string]any{
+					"url": repoURL,
+					"svc": svcID,
+				},
+			})
+		require.NoError(t, err)
+		require.Contains(t, res.Git.Branch.Tree.Entries, "README.md")
+		require.NotContains(t, res.Git.Branch.Tree.Entries, ".git")
+		require.Empty(t, res.Git.Branch.Tree.Sub.Entries)
+	})
+
+	t.Run("depth", func(t *testing.T) {
+		tree := c.Git(repoURL, dagger.GitOpts{
+			KeepGitDir:              true,
+			Depth:                   2,
+			ExperimentalServiceHost: gitDaemon,
+		}).Branch("main").Tree()
+
+		out, err := c.Container().From("alpine:3.16.2").
+			WithExec([]string{"apk", "add", "git"}).
+			WithMountedDirectory("/src", tree).
+			WithWorkdir("/src").
+			WithExec([]string{"git", "rev-list", "--count", "HEAD"}).
+			Stdout(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "2\n", out)
+
+		commits, err := c.Git(repoURL, dagger.GitOpts{
+			Depth:                   1,
+			ExperimentalServiceHost: gitDaemon,
+		}).Log(ctx, "main")
+		require.NoError(t, err)
+		require.Len(t, commits, 1)
+	})
+}
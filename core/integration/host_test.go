@@ -3,7 +3,9 @@ package core
 import (
 	"context"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"dagger.io/dagger"
@@ -262,3 +264,93 @@ func TestHostVariable(t *testing.T) {
 
 	require.Contains(t, env, "SECRET=***")
 }
+
+func TestHostGitRepository(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	git := func(args ...string) string {
+		t.Helper()
+		cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=Test User",
+			"GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=Test User",
+			"GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		out, err := cmd.CombinedOutput()
+		require.NoError(t, err, string(out))
+		return strings.TrimSpace(string(out))
+	}
+
+	git("init")
+	git("checkout", "-b", "main")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("ignored.txt\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("one\n"), 0600))
+	git("add", ".")
+	git("commit", "-m", "one")
+	git("tag", "v1")
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("two\n"), 0600))
+	git("commit", "-am", "two")
+	head := git("rev-parse", "HEAD")
+
+	ctx := context.Background()
+	c, err := dagger.Connect(ctx, dagger.WithWorkdir(dir))
+	require.NoError(t, err)
+	defer c.Close()
+
+	t.Run("clean", func(t *testing.T) {
+		repo := c.Host().GitRepository(".")
+
+		branch, err := repo.HeadBranch(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "main", branch)
+
+		dirty, err := repo.IsDirty(ctx)
+		require.NoError(t, err)
+		require.False(t, dirty)
+
+		hash, err := repo.Head().CommitInfo().Hash(ctx)
+		require.NoError(t, err)
+		require.Equal(t, head, hash)
+
+		contents, err := repo.Tag("v1").Tree().File("README.md").Contents(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "one\n", contents)
+
+		commits, err := repo.Log(ctx, "main", dagger.GitRepositoryLogOpts{From: "v1"})
+		require.NoError(t, err)
+		require.Len(t, commits, 1)
+	})
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("three\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "new.txt"), []byte("new\n"), 0600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "ignored.txt"), []byte("ignored\n"), 0600))
+
+	t.Run("dirty", func(t *testing.T) {
+		repo := c.Host().GitRepository(".")
+
+		dirty, err := repo.IsDirty(ctx)
+		require.NoError(t, err)
+		require.True(t, dirty)
+
+		contents, err := repo.Head().Tree().File("README.md").Contents(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "two\n", contents)
+	})
+
+	t.Run("include uncommitted", func(t *testing.T) {
+		tree := c.Host().GitRepository(".", dagger.HostGitRepositoryOpts{
+			IncludeUncommitted: true,
+		}).Head().Tree()
+
+		contents, err := tree.File("README.md").Contents(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "three\n", contents)
+
+		entries, err := tree.Entries(ctx)
+		require.NoError(t, err)
+		require.ElementsMatch(t, []string{".gitignore", "README.md", "new.txt"}, entries)
+	})
+}
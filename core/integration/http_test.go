@@ -42,3 +42,116 @@ func TestHTTPService(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, contents, "Hello, world!")
 }
+
+func TestHTTPChecksum(t *testing.T) {
+	checkNotDisabled(t, engine.ServicesDNSEnvName)
+
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	svc, url := httpService(ctx, t, c, "Hello, world!")
+
+	t.Run("matching", func(t *testing.T) {
+		file := c.HTTP(url, dagger.HTTPOpts{
+			Checksum:                "sha256:315f5bdb76d078c43b8ac0064e4a0164612b1fce77c869345bfc94c75894edd3",
+			Name:                    "hello.txt",
+			Permissions:             0o644,
+			ExperimentalServiceHost: svc,
+		})
+
+		contents, err := file.Contents(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "Hello, world!", contents)
+
+		name, err := file.Stat().Name(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "hello.txt", name)
+
+		mode, err := file.Stat().Mode(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 0o644, mode)
+	})
+
+	t.Run("mismatched", func(t *testing.T) {
+		_, err := c.HTTP(url, dagger.HTTPOpts{
+			Checksum:                "sha256:0000000000000000000000000000000000000000000000000000000000000000",
+			ExperimentalServiceHost: svc,
+		}).Contents(ctx)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "mismatch")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		_, err := c.HTTP(url, dagger.HTTPOpts{
+			Checksum:                "not-a-digest",
+			ExperimentalServiceHost: svc,
+		}).Contents(ctx)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "invalid checksum")
+	})
+}
+
+func TestHTTPHeaders(t *testing.T) {
+	checkNotDisabled(t, engine.ServicesDNSEnvName)
+
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	// responds with the request's X-Test header, as long as it is authorized
+	srv := c.Container().
+		From("python").
+		WithNewFile("/srv/server.py", dagger.ContainerWithNewFileOpts{
+			Contents: `from http.server import BaseHTTPRequestHandler, HTTPServer
+
+class Handler(BaseHTTPRequestHandler):
+    def do_GET(self):
+        if self.headers.get("Authorization") != "Bearer hunter2":
+            self.send_response(401)
+            self.end_headers()
+            return
+        body = self.headers.get("X-Test", "").encode()
+        self.send_response(200)
+        self.send_header("Content-Length", str(len(body)))
+        self.end_headers()
+        self.wfile.write(body)
+
+HTTPServer(("", 8000), Handler).serve_forever()
+`,
+		}).
+		WithExposedPort(8000).
+		WithExec([]string{"python", "/srv/server.py"})
+
+	url, err := srv.Endpoint(ctx, dagger.ContainerEndpointOpts{
+		Scheme: "http",
+	})
+	require.NoError(t, err)
+
+	token := c.SetSecret("token", "Bearer hunter2")
+
+	t.Run("authorized", func(t *testing.T) {
+		contents, err := c.HTTP(url, dagger.HTTPOpts{
+			Headers: []dagger.HTTPHeader{
+				{Name: "X-Test", Value: "hello"},
+			},
+			AuthHeader:              token,
+			ExperimentalServiceHost: srv,
+		}).Contents(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "hello", contents)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		_, err := c.HTTP(url, dagger.HTTPOpts{
+			Headers: []dagger.HTTPHeader{
+				{Name: "X-Test", Value: "hello"},
+			},
+			ExperimentalServiceHost: srv,
+		}).Contents(ctx)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "401")
+	})
+}
@@ -0,0 +1,258 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"dagger.io/dagger"
+	"github.com/dagger/dagger/engine"
+	internalengine "github.com/dagger/dagger/internal/engine"
+	"github.com/dagger/dagger/router"
+	"github.com/moby/buildkit/identity"
+	"github.com/stretchr/testify/require"
+)
+
+func TestImage(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	image := c.Image("alpine:3.16.2", dagger.ImageOpts{
+		Platform: "linux/arm64",
+	})
+
+	exists, err := image.Exists(ctx)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	digest, err := image.Digest(ctx)
+	require.NoError(t, err)
+	require.Contains(t, digest, "sha256:")
+
+	platforms, err := image.Platforms(ctx)
+	require.NoError(t, err)
+
+	names := []dagger.Platform{}
+	for _, platform := range platforms {
+		name, err := platform.Platform(ctx)
+		require.NoError(t, err)
+		names = append(names, name)
+	}
+	require.Contains(t, names, dagger.Platform("linux/amd64"))
+	require.Contains(t, names, dagger.Platform("linux/arm64/v8"))
+
+	platform, err := image.Platform(ctx)
+	require.NoError(t, err)
+	require.Equal(t, dagger.Platform("linux/arm64/v8"), platform)
+
+	manifestDigest, err := image.ManifestDigest(ctx)
+	require.NoError(t, err)
+	require.NotEqual(t, digest, manifestDigest)
+
+	created, err := image.Created(ctx)
+	require.NoError(t, err)
+	require.NotEmpty(t, created)
+
+	layers, err := image.Layers(ctx)
+	require.NoError(t, err)
+	require.Len(t, layers, 1)
+
+	size, err := layers[0].Size(ctx)
+	require.NoError(t, err)
+	require.Greater(t, size, 0)
+}
+
+func TestImageNotFound(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	image := c.Image(registryRef("image-not-found"))
+
+	exists, err := image.Exists(ctx)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	layers, err := image.Layers(ctx)
+	require.NoError(t, err)
+	require.Empty(t, layers)
+}
+
+func TestImageNotFoundDockerHub(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	// Docker Hub answers unauthorized for repositories that don't exist
+	exists, err := c.Image("docker.io/dagger/this-image-does-not-exist:latest").Exists(ctx)
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestImagePublished(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	testRef := registryRef("image-published")
+	digest, err := c.Container().
+		From("alpine:3.16.2").
+		WithLabel("org.opencontainers.image.title", "published").
+		PublishWithDigests(testRef).
+		Digest(ctx)
+	require.NoError(t, err)
+
+	image := c.Image(testRef)
+
+	imageDigest, err := image.Digest(ctx)
+	require.NoError(t, err)
+	require.Equal(t, digest, imageDigest)
+
+	labels, err := image.Labels(ctx)
+	require.NoError(t, err)
+
+	values := map[string]string{}
+	for _, label := range labels {
+		name, err := label.Name(ctx)
+		require.NoError(t, err)
+		value, err := label.Value(ctx)
+		require.NoError(t, err)
+		values[name] = value
+	}
+	require.Equal(t, "published", values["org.opencontainers.image.title"])
+}
+
+func TestImageOtherPlatform(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	testRef := registryRef("image-other-platform")
+	digest, err := c.Container().
+		From("alpine:3.16.2").
+		PublishWithDigests(testRef).
+		Digest(ctx)
+	require.NoError(t, err)
+
+	// published for the engine's platform only
+	image := c.Image(testRef, dagger.ImageOpts{
+		Platform: "linux/s390x",
+	})
+
+	exists, err := image.Exists(ctx)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	imageDigest, err := image.Digest(ctx)
+	require.NoError(t, err)
+	require.Equal(t, digest, imageDigest)
+
+	platforms, err := image.Platforms(ctx)
+	require.NoError(t, err)
+	require.Len(t, platforms, 1)
+
+	manifestDigest, err := image.ManifestDigest(ctx)
+	require.NoError(t, err)
+	require.Empty(t, manifestDigest)
+
+	layers, err := image.Layers(ctx)
+	require.NoError(t, err)
+	require.Empty(t, layers)
+}
+
+func TestRegistryMirror(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	prefix := "mirror-" + identity.NewID()
+	tag := identity.NewID()
+
+	_, err := c.Container().
+		From("alpine:3.16.2").
+		WithNewFile("/mirrored", dagger.ContainerWithNewFileOpts{
+			Contents: "from the mirror",
+		}).
+		Publish(ctx, registryHost+"/"+prefix+"/library/alpine:"+tag)
+	require.NoError(t, err)
+
+	var res struct {
+		Container struct {
+			From struct {
+				File struct {
+					Contents string
+				}
+			}
+		}
+		Image struct {
+			Exists bool
+		}
+	}
+
+	err = engine.Start(ctx, &engine.Config{
+		RunnerHost:      internalengine.RunnerHost(),
+		RegistryMirrors: []string{"docker.io=" + registryHost + "/" + prefix},
+	}, func(ctx context.Context, r *router.Router) error {
+		_, err := r.Do(ctx,
+			`{
+				container {
+					from(address: "alpine:`+tag+`") {
+						file(path: "/mirrored") {
+							contents
+						}
+					}
+				}
+				image(address: "docker.io/library/alpine:`+tag+`") {
+					exists
+				}
+			}`, "", nil, &res)
+		return err
+	})
+	require.NoError(t, err)
+	require.Equal(t, "from the mirror", res.Container.From.File.Contents)
+	require.True(t, res.Image.Exists)
+}
+
+func TestRegistryMirrorScoped(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	prefix := "mirror-" + identity.NewID()
+	tag := identity.NewID()
+
+	_, err := c.Container().
+		From("alpine:3.16.2").
+		WithNewFile("/mirrored", dagger.ContainerWithNewFileOpts{
+			Contents: "from the mirror",
+		}).
+		Publish(ctx, registryHost+"/"+prefix+"/library/alpine:"+tag)
+	require.NoError(t, err)
+
+	mirrored := c.WithRegistryMirror("docker.io", registryHost+"/"+prefix)
+
+	contents, err := mirrored.Container().
+		From("alpine:" + tag).
+		File("/mirrored").
+		Contents(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "from the mirror", contents)
+
+	exists, err := mirrored.Image("docker.io/library/alpine:" + tag).Exists(ctx)
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	// the rest of the session doesn't use the mirror
+	_, err = c.Container().
+		From("alpine:" + tag).
+		File("/mirrored").
+		Contents(ctx)
+	require.Error(t, err)
+}
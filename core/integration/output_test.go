@@ -0,0 +1,146 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dagger/dagger/core"
+	"github.com/dagger/dagger/engine"
+	internalengine "github.com/dagger/dagger/internal/engine"
+	"github.com/dagger/dagger/router"
+	"github.com/moby/buildkit/identity"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerOutputStream(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	err := engine.Start(ctx, &engine.Config{
+		RunnerHost: internalengine.RunnerHost(),
+	}, func(ctx context.Context, r *router.Router) error {
+		srv := httptest.NewServer(r)
+		defer srv.Close()
+
+		bust := identity.NewID()
+
+		// each stream is served once, so resolve the endpoint for every request
+		outputStream := func() string {
+			var res struct {
+				Container struct {
+					From struct {
+						WithEnvVariable struct {
+							WithExec struct {
+								OutputStream string
+							}
+						}
+					}
+				}
+			}
+
+			_, err := r.Do(ctx,
+				`{
+					container {
+						from(address: "alpine:3.16.2") {
+							withEnvVariable(name: "BUST", value: "`+bust+`") {
+								withExec(args: ["sh", "-c", "echo out1; echo err1 >&2; sleep 3; echo out2; echo err2 >&2"]) {
+									outputStream
+								}
+							}
+						}
+					}
+				}`, "", nil, &res)
+			require.NoError(t, err)
+
+			endpoint := res.Container.From.WithEnvVariable.WithExec.OutputStream
+			require.True(t, strings.HasPrefix(endpoint, "/execs/"))
+			return endpoint
+		}
+
+		open := func(query string) *http.Response {
+			resp, err := http.Get(srv.URL + outputStream() + query)
+			require.NoError(t, err)
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			return resp
+		}
+
+		get := func(query string) string {
+			resp := open(query)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			return string(body)
+		}
+
+		t.Run("streams output while running", func(t *testing.T) {
+			resp := open("?stream=stdout")
+			defer resp.Body.Close()
+
+			body := bufio.NewReader(resp.Body)
+
+			line, err := body.ReadString('\n')
+			require.NoError(t, err)
+			require.Equal(t, "out1\n", line)
+			firstLineAt := time.Now()
+
+			rest, err := io.ReadAll(body)
+			require.NoError(t, err)
+			require.Equal(t, "out2\n", string(rest))
+
+			// the first line arrived while the command was still sleeping
+			require.GreaterOrEqual(t, time.Since(firstLineAt), 2*time.Second)
+		})
+
+		t.Run("writes recorded output once cached", func(t *testing.T) {
+			require.Equal(t, "err1\nerr2\n", get("?stream=stderr"))
+
+			all := get("")
+			require.Contains(t, all, "out2\n")
+			require.Contains(t, all, "err2\n")
+		})
+
+		t.Run("rejects unknown streams", func(t *testing.T) {
+			resp, err := http.Get(srv.URL + outputStream() + "?stream=bogus")
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+		})
+
+		t.Run("stops serving once the stream ends", func(t *testing.T) {
+			endpoint := outputStream()
+
+			resp, err := http.Get(srv.URL + endpoint)
+			require.NoError(t, err)
+			_, err = io.ReadAll(resp.Body)
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			resp, err = http.Get(srv.URL + endpoint)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, http.StatusNotFound, resp.StatusCode)
+		})
+
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestContainerOutputStreamNoExec(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	_, err := c.Container().From("alpine:3.16.2").OutputStream(ctx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), core.ErrContainerNoExec.Error())
+}
@@ -67,6 +67,45 @@ func TestPlatformEmulatedExecAndPush(t *testing.T) {
 	}
 }
 
+func TestPlatformPublishWithDigests(t *testing.T) {
+	c, ctx := connect(t)
+	defer c.Close()
+
+	variants := make([]*dagger.Container, 0, len(platformToUname))
+	for platform := range platformToUname {
+		variants = append(variants, c.Container(dagger.ContainerOpts{Platform: platform}).
+			From("alpine:3.16.2"))
+	}
+
+	published := c.Container().PublishWithDigests(registryRef("platform-publish-digests"), dagger.ContainerPublishWithDigestsOpts{
+		PlatformVariants: variants,
+		Annotations: []dagger.ImageAnnotation{
+			{Name: "org.opencontainers.image.source", Value: "https://example.com/repo"},
+		},
+	})
+
+	digest, err := published.Digest(ctx)
+	require.NoError(t, err)
+
+	indexDigest, err := published.IndexDigest(ctx)
+	require.NoError(t, err)
+	require.Equal(t, digest, indexDigest)
+
+	platforms, err := published.Platforms(ctx)
+	require.NoError(t, err)
+	require.Len(t, platforms, len(platformToUname))
+
+	for _, platform := range platforms {
+		name, err := platform.Platform(ctx)
+		require.NoError(t, err)
+		require.Contains(t, platformToUname, name)
+
+		manifestDigest, err := platform.Digest(ctx)
+		require.NoError(t, err)
+		require.NotEqual(t, digest, manifestDigest)
+	}
+}
+
 func TestPlatformCrossCompile(t *testing.T) {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
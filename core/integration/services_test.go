@@ -16,9 +16,13 @@ import (
 	"context"
 	_ "embed"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
@@ -337,7 +341,7 @@ func TestContainerExecServicesError(t *testing.T) {
 	srv := c.Container().
 		From("alpine:3.16.2").
 		WithExposedPort(8080).
-		WithExec([]string{"sh", "-c", "echo nope; exit 42"})
+		WithExec([]string{"sh", "-c", "echo nope; echo oh no >&2; exit 42"})
 
 	host, err := srv.Hostname(ctx)
 	require.NoError(t, err)
@@ -349,7 +353,10 @@ func TestContainerExecServicesError(t *testing.T) {
 
 	_, err = client.ExitCode(ctx)
 	require.Error(t, err)
-	require.Contains(t, err.Error(), "start "+host+" (aliased as www): exited:")
+	require.Contains(t, err.Error(), "start "+host+" (aliased as www): exited with code 42:")
+	require.Contains(t, err.Error(), "Last 2 lines of service output:")
+	require.Contains(t, err.Error(), "nope")
+	require.Contains(t, err.Error(), "oh no")
 }
 
 func TestContainerServiceNoExecError(t *testing.T) {
@@ -1084,6 +1091,325 @@ func TestFileServiceSecret(t *testing.T) {
 	})
 }
 
+func TestServiceLifecycle(t *testing.T) {
+	t.Parallel()
+
+	checkNotDisabled(t, engine.ServicesDNSEnvName)
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	srv, url := httpService(ctx, t, c, "Hello, world!")
+
+	svc := srv.AsService()
+
+	srvID, err := srv.ID(ctx)
+	require.NoError(t, err)
+	ctrID, err := svc.Container().ID(ctx)
+	require.NoError(t, err)
+	require.Equal(t, srvID, ctrID)
+
+	status, err := svc.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, dagger.ServiceStopped, status)
+
+	id, err := svc.Start(ctx)
+	require.NoError(t, err)
+
+	status, err = c.Service(id).Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, dagger.ServiceRunning, status)
+
+	out, err := c.Container().
+		From("alpine:3.16.2").
+		WithServiceBinding("www", srv).
+		WithExec([]string{"wget", "-O-", url}).
+		Stdout(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "Hello, world!", out)
+
+	require.Eventually(t, func() bool {
+		logs, err := svc.Logs(ctx)
+		require.NoError(t, err)
+		return strings.Contains(logs, `"GET / HTTP/1.1" 200`)
+	}, 10*time.Second, 100*time.Millisecond)
+
+	_, err = svc.Stop(ctx)
+	require.NoError(t, err)
+
+	status, err = svc.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, dagger.ServiceStopped, status)
+
+	logs, err := svc.Logs(ctx)
+	require.NoError(t, err)
+	require.Empty(t, logs)
+}
+
+func TestServiceKeptRunning(t *testing.T) {
+	t.Parallel()
+
+	checkNotDisabled(t, engine.ServicesDNSEnvName)
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	srv := c.Container().
+		From("python").
+		WithNewFile("/srv/counter.py", dagger.ContainerWithNewFileOpts{
+			Contents: `import http.server
+
+count = 0
+
+class Handler(http.server.BaseHTTPRequestHandler):
+    def do_GET(self):
+        global count
+        count += 1
+        self.send_response(200)
+        self.end_headers()
+        self.wfile.write(str(count).encode())
+
+http.server.HTTPServer(("", 8000), Handler).serve_forever()
+`,
+		}).
+		WithExposedPort(8000).
+		WithExec([]string{"python", "/srv/counter.py"})
+
+	_, err := srv.AsService().Start(ctx)
+	require.NoError(t, err)
+
+	count := func() string {
+		out, err := c.Container().
+			From("alpine:3.16.2").
+			WithServiceBinding("counter", srv).
+			WithEnvVariable("CACHEBUST", identity.NewID()).
+			WithExec([]string{"wget", "-q", "-O-", "http://counter:8000"}).
+			Stdout(ctx)
+		require.NoError(t, err)
+		return out
+	}
+
+	require.Equal(t, "1", count())
+
+	// outlast the delay after which bound services are detached from
+	time.Sleep(15 * time.Second)
+
+	require.Equal(t, "2", count())
+}
+
+func TestHostTunnel(t *testing.T) {
+	t.Parallel()
+
+	checkNotDisabled(t, engine.ServicesDNSEnvName)
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	srv, _ := httpService(ctx, t, c, "Hello, world!")
+
+	addrs, err := c.Host().Tunnel(ctx, srv.AsService(), dagger.HostTunnelOpts{
+		Ports: []dagger.PortForward{
+			{Frontend: 0, Backend: 8000},
+		},
+	})
+	require.NoError(t, err)
+	require.Len(t, addrs, 1)
+
+	for i := 0; i < 3; i++ {
+		res, err := http.Get("http://" + addrs[0])
+		require.NoError(t, err)
+
+		body, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		require.NoError(t, err)
+		require.Equal(t, "Hello, world!", string(body))
+	}
+
+	_, err = c.Host().Tunnel(ctx, srv.AsService(), dagger.HostTunnelOpts{
+		Ports: []dagger.PortForward{
+			{Frontend: 0, Backend: 8000, Protocol: dagger.Udp},
+		},
+	})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "only TCP ports can be tunneled")
+}
+
+func TestHostService(t *testing.T) {
+	t.Parallel()
+
+	checkNotDisabled(t, engine.ServicesDNSEnvName)
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	httpL, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer httpL.Close()
+
+	go http.Serve(httpL, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { //nolint:gosec
+		fmt.Fprint(w, "Hello from the host!")
+	}))
+
+	udpConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer udpConn.Close()
+
+	go func() {
+		buf := make([]byte, 1024)
+		for {
+			n, addr, err := udpConn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			_, _ = udpConn.WriteTo([]byte(strings.ToUpper(string(buf[:n]))), addr)
+		}
+	}()
+
+	srv := c.Host().Service([]dagger.PortForward{
+		{Frontend: 8080, Backend: httpL.Addr().(*net.TCPAddr).Port},
+		{Frontend: 5353, Backend: udpConn.LocalAddr().(*net.UDPAddr).Port, Protocol: dagger.Udp},
+	})
+
+	client := c.Container().
+		From("alpine:3.16.2").
+		WithServiceBinding("www", srv).
+		WithEnvVariable("CACHEBUST", identity.NewID())
+
+	out, err := client.
+		WithExec([]string{"wget", "-q", "-O-", "http://www:8080"}).
+		Stdout(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "Hello from the host!", out)
+
+	out, err = client.
+		WithExec([]string{"sh", "-c", "echo -n hello | nc -u -w1 www 5353"}).
+		Stdout(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "HELLO", out)
+
+	// the proxy runs as an internal command without leaving that in the
+	// container's config
+	env, err := srv.EnvVariables(ctx)
+	require.NoError(t, err)
+	for _, v := range env {
+		name, err := v.Name(ctx)
+		require.NoError(t, err)
+		require.NotEqual(t, "_DAGGER_INTERNAL_COMMAND", name)
+	}
+}
+
+func TestContainerHealthcheck(t *testing.T) {
+	t.Parallel()
+
+	checkNotDisabled(t, engine.ServicesDNSEnvName)
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	t.Run("command", func(t *testing.T) {
+		srv := c.Container().
+			From("python").
+			WithWorkdir("/srv/www").
+			WithExposedPort(8000).
+			WithHealthcheck(dagger.ContainerWithHealthcheckOpts{
+				Args: []string{"test", "-f", "/srv/www/index.html"},
+			}).
+			WithExec([]string{"sh", "-c", "python -m http.server & sleep 3; echo ready > index.html; wait"})
+
+		out, err := c.Container().
+			From("alpine:3.16.2").
+			WithServiceBinding("www", srv).
+			WithEnvVariable("CACHEBUST", identity.NewID()).
+			WithExec([]string{"wget", "-q", "-O-", "http://www:8000/index.html"}).
+			Stdout(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "ready\n", out)
+	})
+
+	t.Run("http", func(t *testing.T) {
+		srv := c.Container().
+			From("python").
+			WithWorkdir("/srv/www").
+			WithExposedPort(8000).
+			WithHealthcheck(dagger.ContainerWithHealthcheckOpts{
+				HTTPPath: "/health",
+			}).
+			WithExec([]string{"sh", "-c", "python -m http.server & sleep 3; echo ok > health; wait"})
+
+		out, err := c.Container().
+			From("alpine:3.16.2").
+			WithServiceBinding("www", srv).
+			WithEnvVariable("CACHEBUST", identity.NewID()).
+			WithExec([]string{"wget", "-q", "-O-", "http://www:8000/health"}).
+			Stdout(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "ok\n", out)
+	})
+
+	t.Run("unhealthy", func(t *testing.T) {
+		srv := c.Container().
+			From("python").
+			WithExposedPort(8000).
+			WithHealthcheck(dagger.ContainerWithHealthcheckOpts{
+				Args:    []string{"sh", "-c", "echo not yet; exit 1"},
+				Retries: 2,
+			}).
+			WithExec([]string{"sh", "-c", "echo booting; python -m http.server"})
+
+		_, err := c.Container().
+			From("alpine:3.16.2").
+			WithServiceBinding("www", srv).
+			WithEnvVariable("CACHEBUST", identity.NewID()).
+			WithExec([]string{"wget", "-q", "-O-", "http://www:8000"}).
+			Stdout(ctx)
+		require.Error(t, err)
+		require.Contains(t, err.Error(), "unhealthy")
+		require.Contains(t, err.Error(), "failed 2 times in a row")
+		require.Contains(t, err.Error(), "not yet")
+		require.Contains(t, err.Error(), "booting")
+	})
+
+	t.Run("not run outside services", func(t *testing.T) {
+		_, err := c.Container().
+			From("alpine:3.16.2").
+			WithHealthcheck(dagger.ContainerWithHealthcheckOpts{
+				Args: []string{"touch", "/checked"},
+			}).
+			WithEnvVariable("CACHEBUST", identity.NewID()).
+			WithExec([]string{"sh", "-c", "sleep 3; test ! -e /checked"}).
+			ExitCode(ctx)
+		require.NoError(t, err)
+	})
+
+	t.Run("image config", func(t *testing.T) {
+		ctr := c.Container().
+			From("alpine:3.16.2").
+			WithHealthcheck(dagger.ContainerWithHealthcheckOpts{
+				Args:        []string{"pg_isready"},
+				Interval:    5,
+				Retries:     4,
+				StartPeriod: 10,
+			})
+
+		imageTar := filepath.Join(t.TempDir(), "image.tar")
+
+		_, err := ctr.Export(ctx, imageTar)
+		require.NoError(t, err)
+
+		image, err := tarball.ImageFromPath(imageTar, nil)
+		require.NoError(t, err)
+
+		config, err := image.ConfigFile()
+		require.NoError(t, err)
+		require.NotNil(t, config.Config.Healthcheck)
+		require.Equal(t, []string{"CMD", "pg_isready"}, config.Config.Healthcheck.Test)
+		require.Equal(t, 5*time.Second, config.Config.Healthcheck.Interval)
+		require.Equal(t, 10*time.Second, config.Config.Healthcheck.StartPeriod)
+		require.Equal(t, 4, config.Config.Healthcheck.Retries)
+	})
+}
+
 func httpService(ctx context.Context, t *testing.T, c *dagger.Client, content string) (*dagger.Container, string) {
 	t.Helper()
 
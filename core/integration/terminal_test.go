@@ -0,0 +1,191 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dagger/dagger/engine"
+	internalengine "github.com/dagger/dagger/internal/engine"
+	"github.com/dagger/dagger/router"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestContainerTerminal(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	err := engine.Start(ctx, &engine.Config{
+		RunnerHost: internalengine.RunnerHost(),
+	}, func(ctx context.Context, r *router.Router) error {
+		srv := httptest.NewServer(r)
+		defer srv.Close()
+
+		var res struct {
+			Container struct {
+				From struct {
+					WithEnvVariable struct {
+						Terminal struct {
+							WebsocketEndpoint string
+						}
+					}
+				}
+			}
+		}
+
+		_, err := r.Do(ctx,
+			`{
+				container {
+					from(address: "alpine:3.16.2") {
+						withEnvVariable(name: "FOO", value: "hello from the terminal") {
+							terminal(args: ["sh", "-c", "echo $FOO; read line; exit 3"]) {
+								websocketEndpoint
+							}
+						}
+					}
+				}
+			}`, "", nil, &res)
+		require.NoError(t, err)
+
+		endpoint := res.Container.From.WithEnvVariable.Terminal.WebsocketEndpoint
+		require.True(t, strings.HasPrefix(endpoint, "/terminals/"))
+
+		wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + endpoint
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		err = conn.WriteMessage(websocket.TextMessage, []byte("resize;80;24"))
+		require.NoError(t, err)
+
+		output := new(strings.Builder)
+		var sentInput bool
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				var closeErr *websocket.CloseError
+				require.True(t, errors.As(err, &closeErr), "unexpected error: %s", err)
+				require.Equal(t, websocket.CloseNormalClosure, closeErr.Code)
+				require.Equal(t, "exit 3", closeErr.Text)
+				break
+			}
+
+			output.Write(msg)
+
+			if !sentInput && strings.Contains(output.String(), "hello from the terminal") {
+				err := conn.WriteMessage(websocket.BinaryMessage, []byte("bye\n"))
+				require.NoError(t, err)
+				sentInput = true
+			}
+		}
+
+		require.True(t, sentInput)
+
+		t.Run("stops serving once the session ends", func(t *testing.T) {
+			_, res, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+			require.Error(t, err)
+			require.NotNil(t, res)
+			res.Body.Close()
+			require.Equal(t, http.StatusNotFound, res.StatusCode)
+		})
+
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestContainerTerminalSecretVariable(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	err := engine.Start(ctx, &engine.Config{
+		RunnerHost: internalengine.RunnerHost(),
+	}, func(ctx context.Context, r *router.Router) error {
+		srv := httptest.NewServer(r)
+		defer srv.Close()
+
+		var secretRes struct {
+			SetSecret struct {
+				ID string
+			}
+		}
+
+		_, err := r.Do(ctx,
+			`{
+				setSecret(name: "terminal-secret", plaintext: "hunter2") {
+					id
+				}
+			}`, "", nil, &secretRes)
+		require.NoError(t, err)
+
+		var res struct {
+			Container struct {
+				From struct {
+					WithSecretVariable struct {
+						Terminal struct {
+							WebsocketEndpoint string
+						}
+					}
+				}
+			}
+		}
+
+		_, err = r.Do(ctx,
+			`query Test($secret: SecretID!) {
+				container {
+					from(address: "alpine:3.16.2") {
+						withSecretVariable(name: "SECRET", secret: $secret) {
+							terminal(args: ["sh", "-c", "echo secret:$SECRET"]) {
+								websocketEndpoint
+							}
+						}
+					}
+				}
+			}`, "Test", map[string]any{
+				"secret": secretRes.SetSecret.ID,
+			}, &res)
+		require.NoError(t, err)
+
+		endpoint := res.Container.From.WithSecretVariable.Terminal.WebsocketEndpoint
+
+		wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + endpoint
+		conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsURL, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		output := new(strings.Builder)
+		for {
+			_, msg, err := conn.ReadMessage()
+			if err != nil {
+				var closeErr *websocket.CloseError
+				require.True(t, errors.As(err, &closeErr), "unexpected error: %s", err)
+				require.Equal(t, "exit 0", closeErr.Text)
+				break
+			}
+
+			output.Write(msg)
+		}
+
+		require.Contains(t, output.String(), "secret:hunter2")
+
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestContainerTerminalEmpty(t *testing.T) {
+	t.Parallel()
+
+	c, ctx := connect(t)
+	defer c.Close()
+
+	_, err := c.Container().Terminal().WebsocketEndpoint(ctx)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "cannot open a terminal in an empty container")
+}
@@ -0,0 +1,468 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/armon/circbuf"
+	bkclient "github.com/moby/buildkit/client"
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/solver/pb"
+	"github.com/opencontainers/go-digest"
+)
+
+// Vertex log stream numbers, as reported by buildkit.
+const (
+	outputStreamStdout = 1
+	outputStreamStderr = 2
+)
+
+// outputCompletionTimeout is how long to wait for the tail of an exec's output
+// to arrive through the progress stream once the exec has been evaluated.
+const outputCompletionTimeout = 5 * time.Second
+
+// ExecOutputs fans out the output of execs, as reported by the session's
+// progress stream, to clients following them live.
+type ExecOutputs struct {
+	mu     sync.Mutex
+	subs   map[digest.Digest]map[*outputSubscription]struct{}
+	closed bool
+}
+
+func NewExecOutputs() *ExecOutputs {
+	return &ExecOutputs{
+		subs: map[digest.Digest]map[*outputSubscription]struct{}{},
+	}
+}
+
+// Consume dispatches events to subscribers until ch is closed.
+func (outputs *ExecOutputs) Consume(ch <-chan *bkclient.SolveStatus) {
+	for ev := range ch {
+		outputs.dispatch(ev)
+	}
+
+	outputs.mu.Lock()
+	defer outputs.mu.Unlock()
+
+	outputs.closed = true
+	for _, subs := range outputs.subs {
+		for sub := range subs {
+			sub.finish()
+		}
+	}
+}
+
+func (outputs *ExecOutputs) dispatch(ev *bkclient.SolveStatus) {
+	outputs.mu.Lock()
+	defer outputs.mu.Unlock()
+
+	if len(outputs.subs) == 0 {
+		return
+	}
+
+	for _, l := range ev.Logs {
+		for sub := range outputs.subs[l.Vertex] {
+			sub.write(l)
+		}
+	}
+
+	for _, v := range ev.Vertexes {
+		if v.Completed == nil && !v.Cached {
+			continue
+		}
+
+		for sub := range outputs.subs[v.Digest] {
+			sub.finish()
+		}
+	}
+}
+
+// errOutputsNotFollowed is returned when subscribing to the outputs of a
+// session that doesn't follow them.
+var errOutputsNotFollowed = errors.New("exec outputs are not followed in this session")
+
+func (outputs *ExecOutputs) subscribe(vtx digest.Digest) (*outputSubscription, error) {
+	if outputs == nil {
+		return nil, errOutputsNotFollowed
+	}
+
+	outputs.mu.Lock()
+	defer outputs.mu.Unlock()
+
+	sub := &outputSubscription{
+		notify: make(chan struct{}, 1),
+	}
+
+	if outputs.closed {
+		sub.finish()
+		return sub, nil
+	}
+
+	subs, found := outputs.subs[vtx]
+	if !found {
+		subs = map[*outputSubscription]struct{}{}
+		outputs.subs[vtx] = subs
+	}
+
+	subs[sub] = struct{}{}
+
+	return sub, nil
+}
+
+func (outputs *ExecOutputs) unsubscribe(vtx digest.Digest, sub *outputSubscription) {
+	outputs.mu.Lock()
+	defer outputs.mu.Unlock()
+
+	delete(outputs.subs[vtx], sub)
+	if len(outputs.subs[vtx]) == 0 {
+		delete(outputs.subs, vtx)
+	}
+}
+
+// outputSubscription buffers the logs of a single vertex until they're read.
+//
+// The buffer is unbounded so that dispatching never blocks the progress
+// stream on a slow client.
+type outputSubscription struct {
+	mu       sync.Mutex
+	logs     []*bkclient.VertexLog
+	received bool
+	done     bool
+	notify   chan struct{}
+}
+
+func (sub *outputSubscription) write(l *bkclient.VertexLog) {
+	sub.mu.Lock()
+	sub.logs = append(sub.logs, l)
+	sub.received = true
+	sub.mu.Unlock()
+	sub.signal()
+}
+
+func (sub *outputSubscription) finish() {
+	sub.mu.Lock()
+	sub.done = true
+	sub.mu.Unlock()
+	sub.signal()
+}
+
+func (sub *outputSubscription) signal() {
+	select {
+	case sub.notify <- struct{}{}:
+	default:
+	}
+}
+
+// drain returns the buffered logs, whether any logs have ever been received,
+// and whether the vertex has completed.
+func (sub *outputSubscription) drain() ([]*bkclient.VertexLog, bool, bool) {
+	sub.mu.Lock()
+	defer sub.mu.Unlock()
+
+	logs := sub.logs
+	sub.logs = nil
+	return logs, sub.received, sub.done
+}
+
+// maxLogTailBytes is how much of an exec's output a logTail keeps.
+const maxLogTailBytes = 16 * 1024
+
+// logTail keeps the tail of the output of an exec, as it's reported by the
+// progress stream, with stdout and stderr interleaved.
+//
+// A nil logTail keeps nothing.
+type logTail struct {
+	outputs *ExecOutputs
+	vtx     digest.Digest
+	sub     *outputSubscription
+
+	mu  sync.Mutex
+	buf *circbuf.Buffer
+
+	done chan struct{}
+}
+
+// tailOutput follows the output of the container's last exec until the tail
+// is stopped. It returns nil if the session's outputs aren't followed.
+func tailOutput(container *Container, outputs *ExecOutputs) *logTail {
+	vtx, err := container.execVertex()
+	if err != nil {
+		return nil
+	}
+
+	buf, err := circbuf.NewBuffer(maxLogTailBytes)
+	if err != nil {
+		return nil
+	}
+
+	sub, err := outputs.subscribe(vtx)
+	if err != nil {
+		return nil
+	}
+
+	tail := &logTail{
+		outputs: outputs,
+		vtx:     vtx,
+		sub:     sub,
+		buf:     buf,
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		defer close(tail.done)
+
+		for range tail.sub.notify {
+			logs, _, finished := tail.sub.drain()
+
+			tail.mu.Lock()
+			for _, l := range logs {
+				_, _ = tail.buf.Write(l.Data)
+			}
+			tail.mu.Unlock()
+
+			if finished {
+				return
+			}
+		}
+	}()
+
+	return tail
+}
+
+// Stop stops following the output.
+func (tail *logTail) Stop() {
+	if tail == nil {
+		return
+	}
+
+	tail.outputs.unsubscribe(tail.vtx, tail.sub)
+	tail.sub.finish()
+	<-tail.done
+}
+
+// Finish waits for the exec to complete, giving the progress stream a moment
+// to catch up, and stops following the output.
+func (tail *logTail) Finish(ctx context.Context) {
+	if tail == nil {
+		return
+	}
+
+	select {
+	case <-tail.done:
+	case <-time.After(outputCompletionTimeout):
+	case <-ctx.Done():
+	}
+
+	tail.Stop()
+}
+
+// Lines returns up to the last n lines of the output.
+func (tail *logTail) Lines(n int) []string {
+	if tail == nil {
+		return nil
+	}
+
+	out := strings.TrimRight(tail.String(), "\n")
+	if out == "" {
+		return nil
+	}
+
+	lines := strings.Split(out, "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+
+	return lines
+}
+
+// String returns the output kept, starting at the first whole line.
+func (tail *logTail) String() string {
+	if tail == nil {
+		return ""
+	}
+
+	tail.mu.Lock()
+	defer tail.mu.Unlock()
+
+	out := tail.buf.String()
+	if int64(len(out)) < tail.buf.TotalWritten() {
+		// the first line was cut off
+		_, out, _ = strings.Cut(out, "\n")
+	}
+
+	return out
+}
+
+// OutputEndpoint returns the session-relative path the output of the
+// container's last exec is streamed on.
+func (container *Container) OutputEndpoint() (string, error) {
+	vtx, err := container.execVertex()
+	if err != nil {
+		return "", err
+	}
+
+	return "/execs/" + vtx.Encoded() + "/output", nil
+}
+
+// OutputHandler returns an HTTP handler that streams the output of the
+// container's last exec in chunks as it's written, running the exec if it
+// hasn't already.
+//
+// Both streams are written by default; a "stream" query parameter of "stdout"
+// or "stderr" selects only one of them. If the exec was cached, its recorded
+// output is written in full instead.
+func (container *Container) OutputHandler(gw bkgw.Client, outputs *ExecOutputs) (http.Handler, error) {
+	if outputs == nil {
+		return nil, errOutputsNotFollowed
+	}
+
+	vtx, err := container.execVertex()
+	if err != nil {
+		return nil, err
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var streams []int
+		switch r.URL.Query().Get("stream") {
+		case "":
+			streams = []int{outputStreamStdout, outputStreamStderr}
+		case "stdout":
+			streams = []int{outputStreamStdout}
+		case "stderr":
+			streams = []int{outputStreamStderr}
+		default:
+			http.Error(w, "stream must be one of stdout or stderr", http.StatusBadRequest)
+			return
+		}
+
+		ctx := r.Context()
+
+		sub, err := outputs.subscribe(vtx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer outputs.unsubscribe(vtx, sub)
+
+		evalErr := make(chan error, 1)
+		go func() {
+			evalErr <- container.Evaluate(ctx, gw, nil)
+		}()
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(http.StatusOK)
+
+		flush := func() {
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+
+		write := func(logs []*bkclient.VertexLog) bool {
+			for _, l := range logs {
+				if !containsStream(streams, l.Stream) {
+					continue
+				}
+
+				if _, err := w.Write(l.Data); err != nil {
+					return false
+				}
+			}
+
+			flush()
+			return true
+		}
+
+		var evaluated bool
+		var completionTimeout <-chan time.Time
+		for {
+			select {
+			case <-sub.notify:
+			case <-evalErr:
+				// the exec is done; give the progress stream a moment to catch up
+				// if it hasn't yet reported completion
+				evaluated = true
+				evalErr = nil
+				completionTimeout = time.After(outputCompletionTimeout)
+			case <-completionTimeout:
+				sub.finish()
+			case <-ctx.Done():
+				return
+			}
+
+			logs, received, done := sub.drain()
+			if !write(logs) {
+				return
+			}
+
+			if !done || !evaluated {
+				continue
+			}
+
+			if !received {
+				// the exec was cached, so nothing was logged; write what it recorded
+				// instead
+				for _, stream := range streams {
+					filePath := "stdout"
+					if stream == outputStreamStderr {
+						filePath = "stderr"
+					}
+
+					content, err := container.MetaFileContents(ctx, gw, filePath)
+					if err != nil {
+						return
+					}
+
+					if _, err := w.Write([]byte(content)); err != nil {
+						return
+					}
+				}
+
+				flush()
+			}
+
+			return
+		}
+	}), nil
+}
+
+// execVertex returns the digest of the container's last exec, which is the
+// vertex its output is logged under.
+func (container *Container) execVertex() (digest.Digest, error) {
+	if container.Meta == nil {
+		return "", ErrContainerNoExec
+	}
+
+	defs := container.Meta.Def
+	if len(defs) == 0 {
+		return "", errors.New("empty meta definition")
+	}
+
+	// the last op of a definition is a terminal op referencing its output
+	var op pb.Op
+	if err := op.Unmarshal(defs[len(defs)-1]); err != nil {
+		return "", fmt.Errorf("unmarshal meta op: %w", err)
+	}
+
+	if len(op.Inputs) == 0 {
+		return "", errors.New("meta definition has no output")
+	}
+
+	return op.Inputs[0].Digest, nil
+}
+
+func containsStream(streams []int, stream int) bool {
+	for _, s := range streams {
+		if s == stream {
+			return true
+		}
+	}
+
+	return false
+}
@@ -67,10 +67,15 @@ func loadRootLabels(workdir, engineName string) []Label {
 // ServiceHostnameLabel is annotated on all services started by Dagger.
 const ServiceHostnameLabel = "dagger.io/service.hostname"
 
-func LoadGitLabels(workdir string) ([]Label, error) {
-	repo, err := git.PlainOpenWithOptions(workdir, &git.PlainOpenOptions{
+// OpenGitRepository opens the git repository containing workdir.
+func OpenGitRepository(workdir string) (*git.Repository, error) {
+	return git.PlainOpenWithOptions(workdir, &git.PlainOpenOptions{
 		DetectDotGit: true,
 	})
+}
+
+func LoadGitLabels(workdir string) ([]Label, error) {
+	repo, err := OpenGitRepository(workdir)
 	if err != nil {
 		if errors.Is(err, git.ErrRepositoryNotExists) {
 			return nil, nil
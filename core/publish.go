@@ -0,0 +1,313 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/content/proxy"
+	"github.com/containerd/containerd/images"
+	"github.com/containerd/containerd/platforms"
+	"github.com/dagger/dagger/auth"
+	"github.com/docker/distribution/reference"
+	bkclient "github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/exporter/containerimage/exptypes"
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/opencontainers/go-digest"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// ImageMediaTypes is the set of media types used for a published image's
+// manifests and layers.
+type ImageMediaTypes string
+
+const (
+	OCIMediaTypes    ImageMediaTypes = "OCI_MEDIA_TYPES"
+	DockerMediaTypes ImageMediaTypes = "DOCKER_MEDIA_TYPES"
+)
+
+// ImageLayerCompression is the compression forced on every layer of a
+// published image.
+type ImageLayerCompression string
+
+const (
+	LayerGzip    ImageLayerCompression = "LAYER_GZIP"
+	LayerZstd    ImageLayerCompression = "LAYER_ZSTD"
+	LayerEstargz ImageLayerCompression = "LAYER_ESTARGZ"
+)
+
+// ImageAnnotation is an annotation set on a published image's manifests.
+type ImageAnnotation struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// PublishOpts configures how a container is pushed to a registry.
+type PublishOpts struct {
+	// Additional addresses the image is pushed to.
+	Addresses []string
+
+	// Annotations set on each manifest, and on the index of multi-platform
+	// images.
+	Annotations []ImageAnnotation
+
+	MediaTypes        ImageMediaTypes
+	ForcedCompression ImageLayerCompression
+
+	// Key to sign the image with, if any.
+	Sign *ImageSigningKey
+}
+
+// PublishResult describes an image pushed to a registry.
+type PublishResult struct {
+	// The addresses the image was pushed to, pinned to its digest if the
+	// exporter reported one.
+	Refs []string `json:"refs"`
+
+	// The digest of what was pushed: the index of a multi-platform image, or
+	// the manifest otherwise.
+	Digest string `json:"digest"`
+
+	// The digest of the manifest for the container's own platform.
+	ManifestDigest string `json:"manifestDigest"`
+
+	// The digest of the index, if one was pushed.
+	IndexDigest *string `json:"indexDigest"`
+
+	// The digest of the manifest for each platform.
+	Platforms []PlatformDigest `json:"platforms"`
+
+	// The refs of the pushed signatures.
+	Signatures []string `json:"signatures"`
+}
+
+// PlatformDigest is the digest of a published image's manifest for a
+// platform.
+type PlatformDigest struct {
+	Platform specs.Platform `json:"platform"`
+	Digest   string         `json:"digest"`
+}
+
+// annotation buildkit sets on the attestation manifests of an index
+const (
+	attestationReferenceTypeAnnotation = "vnd.docker.reference.type"
+	attestationManifestType            = "attestation-manifest"
+)
+
+func (opts PublishOpts) exporterAttrs(refs []string, multiPlatform bool) (map[string]string, error) {
+	for _, ref := range refs {
+		if _, err := reference.ParseNormalizedNamed(ref); err != nil {
+			return nil, fmt.Errorf("invalid address %q: %w", ref, err)
+		}
+	}
+
+	attrs := map[string]string{
+		"name": strings.Join(refs, ","),
+		"push": "true",
+	}
+
+	switch opts.MediaTypes {
+	case "", OCIMediaTypes:
+	case DockerMediaTypes:
+		if len(opts.Annotations) > 0 {
+			return nil, fmt.Errorf("annotations require OCI media types")
+		}
+		attrs["oci-mediatypes"] = "false"
+	default:
+		return nil, fmt.Errorf("unknown media types %q", opts.MediaTypes)
+	}
+
+	switch opts.ForcedCompression {
+	case "":
+	case LayerGzip:
+		attrs["compression"] = "gzip"
+	case LayerZstd:
+		attrs["compression"] = "zstd"
+	case LayerEstargz:
+		attrs["compression"] = "estargz"
+	default:
+		return nil, fmt.Errorf("unknown layer compression %q", opts.ForcedCompression)
+	}
+	if opts.ForcedCompression != "" {
+		attrs["force-compression"] = "true"
+	}
+
+	for _, annotation := range opts.Annotations {
+		attrs[exptypes.AnnotationManifestKey(nil, annotation.Name)] = annotation.Value
+		if multiPlatform {
+			// buildkit refuses index annotations on single-platform images
+			attrs[exptypes.AnnotationIndexKey(annotation.Name)] = annotation.Value
+		}
+	}
+
+	return attrs, nil
+}
+
+func (container *Container) Publish(
+	ctx context.Context,
+	ref string,
+	platformVariants []ContainerID,
+	opts PublishOpts,
+	registryAuth *auth.RegistryAuthProvider,
+	secrets SecretStore,
+	bkClient *bkclient.Client,
+	solveOpts bkclient.SolveOpt,
+	solveCh chan<- *bkclient.SolveStatus,
+) (*PublishResult, error) {
+	variants, err := container.exportVariants(platformVariants)
+	if err != nil {
+		return nil, err
+	}
+
+	refs := append([]string{ref}, opts.Addresses...)
+
+	// attestations are attached to an index even for a single platform
+	hasIndex := len(variants) > 1
+	for _, variant := range variants {
+		if variant.hasAttestations() {
+			hasIndex = true
+		}
+	}
+
+	attrs, err := opts.exporterAttrs(refs, hasIndex)
+	if err != nil {
+		return nil, err
+	}
+
+	// NOTE: be careful to not overwrite any values from original solveOpts (i.e. with append).
+	exportOpts := solveOpts
+	exportOpts.Exports = []bkclient.ExportEntry{
+		{
+			Type:  bkclient.ExporterImage,
+			Attrs: attrs,
+		},
+	}
+
+	ch, wg := mirrorCh(solveCh)
+	defer wg.Wait()
+
+	res, err := bkClient.Build(ctx, exportOpts, "", func(ctx context.Context, gw bkgw.Client) (*bkgw.Result, error) {
+		return container.export(ctx, gw, platformVariants)
+	}, ch)
+	if err != nil {
+		return nil, err
+	}
+
+	imageDigest, found := res.ExporterResponse[exptypes.ExporterImageDigestKey]
+	if !found {
+		if opts.Sign != nil {
+			return nil, fmt.Errorf("cannot sign image: digest not found in exporter response")
+		}
+
+		// nothing to pin the refs to
+		return &PublishResult{
+			Refs:       refs,
+			Platforms:  []PlatformDigest{},
+			Signatures: []string{},
+		}, nil
+	}
+
+	dig, err := digest.Parse(imageDigest)
+	if err != nil {
+		return nil, fmt.Errorf("parse digest: %w", err)
+	}
+
+	result := &PublishResult{
+		Digest: dig.String(),
+	}
+
+	for _, ref := range refs {
+		refName, err := reference.ParseNormalizedNamed(ref)
+		if err != nil {
+			return nil, err
+		}
+
+		withDig, err := reference.WithDigest(refName, dig)
+		if err != nil {
+			return nil, fmt.Errorf("with digest: %w", err)
+		}
+
+		result.Refs = append(result.Refs, withDig.String())
+	}
+
+	if opts.Sign != nil {
+		result.Signatures, err = signImage(ctx, refs, dig, *opts.Sign, container.Pipeline, registryAuth, secrets, bkClient, solveOpts, solveCh)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	desc, err := exportedDescriptor(res.ExporterResponse)
+	if err != nil {
+		return nil, err
+	}
+
+	if !images.IsIndexType(desc.MediaType) {
+		result.ManifestDigest = dig.String()
+		result.Platforms = []PlatformDigest{
+			{
+				Platform: variants[0].Platform,
+				Digest:   dig.String(),
+			},
+		}
+		return result, nil
+	}
+
+	result.IndexDigest = &result.Digest
+
+	// the exporter only reports the index, so read the manifests it points to
+	// back from buildkit's content store
+	store := proxy.NewContentStore(bkClient.ContentClient())
+	indexBlob, err := content.ReadBlob(ctx, store, desc)
+	if err != nil {
+		return nil, fmt.Errorf("read index: %w", err)
+	}
+
+	var index specs.Index
+	if err := json.Unmarshal(indexBlob, &index); err != nil {
+		return nil, fmt.Errorf("unmarshal index: %w", err)
+	}
+
+	ownPlatform := platforms.Only(container.Platform)
+	for _, manifest := range index.Manifests {
+		if manifest.Platform == nil || manifest.Annotations[attestationReferenceTypeAnnotation] == attestationManifestType {
+			continue
+		}
+
+		if result.ManifestDigest == "" && container.FS != nil && ownPlatform.Match(*manifest.Platform) {
+			result.ManifestDigest = manifest.Digest.String()
+		}
+
+		result.Platforms = append(result.Platforms, PlatformDigest{
+			Platform: *manifest.Platform,
+			Digest:   manifest.Digest.String(),
+		})
+	}
+
+	return result, nil
+}
+
+// exportedDescriptor decodes the descriptor of the image written by the
+// image exporter.
+func exportedDescriptor(resp map[string]string) (specs.Descriptor, error) {
+	var desc specs.Descriptor
+
+	encoded, found := resp[exptypes.ExporterImageDescriptorKey]
+	if !found {
+		return desc, fmt.Errorf("image descriptor not found in exporter response")
+	}
+
+	descJSON, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return desc, fmt.Errorf("decode descriptor: %w", err)
+	}
+
+	if err := json.Unmarshal(descJSON, &desc); err != nil {
+		return desc, fmt.Errorf("unmarshal descriptor: %w", err)
+	}
+
+	return desc, nil
+}
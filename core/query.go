@@ -1,6 +1,7 @@
 package core
 
 import (
+	"github.com/dagger/dagger/auth"
 	"github.com/dagger/dagger/core/pipeline"
 )
 
@@ -28,7 +29,22 @@ func (query *Query) PipelinePath() pipeline.Path {
 	return pipeline
 }
 
+// RegistryMirrors returns the mirrors configured within the query, on top of
+// the session's.
+//
+// When called against a nil receiver, it returns no mirrors.
+func (query *Query) RegistryMirrors() auth.RegistryMirrors {
+	if query == nil {
+		return nil
+	}
+
+	return query.Context.RegistryMirrors
+}
+
 type QueryContext struct {
 	// Pipeline
 	Pipeline pipeline.Path `json:"pipeline"`
+
+	// Mirrors to pull images from within this query, on top of the session's.
+	RegistryMirrors auth.RegistryMirrors `json:"registryMirrors,omitempty"`
 }
@@ -0,0 +1,263 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/containerd/containerd"
+	"github.com/containerd/containerd/content"
+	"github.com/containerd/containerd/images/archive"
+	"github.com/containerd/containerd/platforms"
+	"github.com/docker/distribution/reference"
+	"github.com/docker/docker/client"
+	bkclient "github.com/moby/buildkit/client"
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+)
+
+// ContainerRuntime is a container runtime on the host that images can be
+// loaded into and taken from.
+type ContainerRuntime string
+
+const (
+	RuntimeDocker     ContainerRuntime = "DOCKER"
+	RuntimeContainerd ContainerRuntime = "CONTAINERD"
+	RuntimePodman     ContainerRuntime = "PODMAN"
+)
+
+// containerdNamespace is the containerd namespace images are loaded into and
+// taken from, the same one nerdctl and ctr use by default.
+const containerdNamespace = "default"
+
+// name returns the runtime's name for messages, resolving the default
+// runtime.
+func (runtime ContainerRuntime) name() string {
+	if runtime == "" {
+		runtime = RuntimeDocker
+	}
+	return strings.ToLower(string(runtime))
+}
+
+// socketPath returns the path of the socket the runtime listens on by
+// default.
+func (runtime ContainerRuntime) socketPath() (string, error) {
+	switch runtime {
+	case "", RuntimeDocker:
+		if host, found := os.LookupEnv("DOCKER_HOST"); found {
+			path, isUnix := strings.CutPrefix(host, "unix://")
+			if !isUnix {
+				return "", fmt.Errorf("unsupported DOCKER_HOST %q: only unix sockets are supported", host)
+			}
+			return path, nil
+		}
+		return "/var/run/docker.sock", nil
+	case RuntimeContainerd:
+		if address, found := os.LookupEnv("CONTAINERD_ADDRESS"); found {
+			return address, nil
+		}
+		return "/run/containerd/containerd.sock", nil
+	case RuntimePodman:
+		if runtimeDir, found := os.LookupEnv("XDG_RUNTIME_DIR"); found && os.Getuid() != 0 {
+			return filepath.Join(runtimeDir, "podman", "podman.sock"), nil
+		}
+		return "/run/podman/podman.sock", nil
+	default:
+		return "", fmt.Errorf("unknown container runtime %q", runtime)
+	}
+}
+
+// runtimeSocket returns the path of the socket to reach the runtime through:
+// the given host socket, or the runtime's default one.
+func (host *Host) runtimeSocket(ctx context.Context, runtime ContainerRuntime, socket *Socket) (string, error) {
+	if socket != nil {
+		if socket.HostPath == "" {
+			return "", fmt.Errorf("container runtime socket must be a host Unix socket")
+		}
+		return socket.HostPath, nil
+	}
+
+	path, err := runtime.socketPath()
+	if err != nil {
+		return "", err
+	}
+
+	socket, err = host.Socket(ctx, path)
+	if err != nil {
+		return "", err
+	}
+
+	return socket.HostPath, nil
+}
+
+// ExportToHost loads the container's image into a container runtime on the
+// host, tagged as tag.
+func (container *Container) ExportToHost(
+	ctx context.Context,
+	host *Host,
+	runtime ContainerRuntime,
+	socket *Socket,
+	tag string,
+	bkClient *bkclient.Client,
+	solveOpts bkclient.SolveOpt,
+	solveCh chan<- *bkclient.SolveStatus,
+) error {
+	sockPath, err := host.runtimeSocket(ctx, runtime, socket)
+	if err != nil {
+		return err
+	}
+
+	named, err := reference.ParseNormalizedNamed(tag)
+	if err != nil {
+		return fmt.Errorf("invalid tag %q: %w", tag, err)
+	}
+
+	tag = reference.TagNameOnly(named).String()
+
+	r, w := io.Pipe()
+
+	loaded := make(chan error, 1)
+	go func() {
+		err := loadImage(ctx, runtime, sockPath, r, container.Platform)
+		r.CloseWithError(err)
+		loaded <- err
+	}()
+
+	exportErr := host.Export(ctx, bkclient.ExportEntry{
+		Type: bkclient.ExporterDocker,
+		Attrs: map[string]string{
+			"name": tag,
+		},
+		Output: func(map[string]string) (io.WriteCloser, error) {
+			return w, nil
+		},
+	}, bkClient, solveOpts, solveCh, func(ctx context.Context, gw bkgw.Client) (*bkgw.Result, error) {
+		return container.export(ctx, gw, nil)
+	})
+	w.CloseWithError(exportErr)
+
+	if err := <-loaded; err != nil {
+		return fmt.Errorf("load image into %s: %w", runtime.name(), err)
+	}
+
+	return exportErr
+}
+
+// FromHost initializes the container from an image in a container runtime on
+// the host.
+func (container *Container) FromHost(
+	ctx context.Context,
+	host *Host,
+	runtime ContainerRuntime,
+	socket *Socket,
+	ref string,
+	store content.Store,
+) (*Container, error) {
+	sockPath, err := host.runtimeSocket(ctx, runtime, socket)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := saveImage(ctx, runtime, sockPath, ref, container.Platform)
+	if err != nil {
+		return nil, fmt.Errorf("save image %s from %s: %w", ref, runtime.name(), err)
+	}
+
+	defer src.Close()
+
+	return container.Import(ctx, host, src, "", store)
+}
+
+// loadImage loads an image tarball into the runtime.
+func loadImage(ctx context.Context, runtime ContainerRuntime, sockPath string, tarball io.Reader, platform platforms.Platform) error {
+	if runtime == RuntimeContainerd {
+		ctrd, err := containerd.New(sockPath, containerd.WithDefaultNamespace(containerdNamespace))
+		if err != nil {
+			return err
+		}
+
+		defer ctrd.Close()
+
+		_, err = ctrd.Import(ctx, tarball, containerd.WithImportPlatform(platforms.Only(platform)))
+		return err
+	}
+
+	// podman serves the docker API too
+	docker, err := dockerClient(sockPath)
+	if err != nil {
+		return err
+	}
+
+	defer docker.Close()
+
+	res, err := docker.ImageLoad(ctx, tarball, true)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	// errors are reported in the progress stream rather than the status
+	dec := json.NewDecoder(res.Body)
+	for {
+		var msg struct {
+			Error string `json:"error"`
+		}
+		if err := dec.Decode(&msg); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if msg.Error != "" {
+			return errors.New(msg.Error)
+		}
+	}
+}
+
+// saveImage returns a tarball of an image in the runtime.
+func saveImage(ctx context.Context, runtime ContainerRuntime, sockPath, ref string, platform platforms.Platform) (io.ReadCloser, error) {
+	if runtime == RuntimeContainerd {
+		named, err := reference.ParseNormalizedNamed(ref)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ref %q: %w", ref, err)
+		}
+
+		ctrd, err := containerd.New(sockPath, containerd.WithDefaultNamespace(containerdNamespace))
+		if err != nil {
+			return nil, err
+		}
+
+		r, w := io.Pipe()
+		go func() {
+			defer ctrd.Close()
+
+			w.CloseWithError(ctrd.Export(ctx, w,
+				archive.WithImage(ctrd.ImageService(), reference.TagNameOnly(named).String()),
+				// only the runtime's own platform is likely to be complete
+				archive.WithPlatform(platforms.Only(platform)),
+			))
+		}()
+
+		return r, nil
+	}
+
+	docker, err := dockerClient(sockPath)
+	if err != nil {
+		return nil, err
+	}
+
+	return docker.ImageSave(ctx, []string{ref})
+}
+
+func dockerClient(sockPath string) (*client.Client, error) {
+	return client.NewClientWithOpts(
+		client.WithHost("unix://"+sockPath),
+		client.WithAPIVersionNegotiation(),
+	)
+}
@@ -0,0 +1,546 @@
+package core
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"debug/buildinfo"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/moby/buildkit/client/llb"
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/opencontainers/go-digest"
+	fstypes "github.com/tonistiigi/fsutil/types"
+)
+
+// SBOMFormat is the format of a software bill of materials.
+type SBOMFormat string
+
+const (
+	SBOMSPDX      SBOMFormat = "SPDX"
+	SBOMCycloneDX SBOMFormat = "CYCLONEDX"
+)
+
+// PredicateType returns the in-toto predicate type of an SBOM in the format.
+func (format SBOMFormat) PredicateType() string {
+	switch format {
+	case SBOMCycloneDX:
+		return "https://cyclonedx.org/bom"
+	default:
+		return "https://spdx.dev/Document"
+	}
+}
+
+func (format SBOMFormat) filename() (string, error) {
+	switch format {
+	case SBOMSPDX, "":
+		return "sbom.spdx.json", nil
+	case SBOMCycloneDX:
+		return "sbom.cdx.json", nil
+	default:
+		return "", fmt.Errorf("unknown SBOM format %q", format)
+	}
+}
+
+// sbomPackage is a package found in a container's rootfs.
+type sbomPackage struct {
+	Name    string
+	Version string
+	License string
+
+	// The purl of the package, e.g. "pkg:apk/alpine/musl@1.2.3-r0?arch=x86_64".
+	PURL string
+}
+
+// SBOM returns a software bill of materials listing the packages installed
+// in the container's rootfs: apk, dpkg and rpm packages, and the modules
+// built into Go binaries.
+//
+// The SBOM is dated at the Unix epoch so that it only changes along with the
+// rootfs.
+func (container *Container) SBOM(ctx context.Context, gw bkgw.Client, format SBOMFormat) (*File, error) {
+	filename, err := format.filename()
+	if err != nil {
+		return nil, err
+	}
+
+	if container.FS == nil {
+		return nil, fmt.Errorf("container has no rootfs")
+	}
+
+	pkgs, err := WithServices(ctx, gw, container.Services, func() ([]sbomPackage, error) {
+		ref, err := gwRef(ctx, gw, container.FS)
+		if err != nil {
+			return nil, err
+		}
+
+		return container.sbomPackages(ctx, gw, ref)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(pkgs, func(i, j int) bool {
+		return pkgs[i].PURL < pkgs[j].PURL
+	})
+
+	// identify the document by the packages it lists
+	id, err := json.Marshal(pkgs)
+	if err != nil {
+		return nil, err
+	}
+	docDigest := digest.FromBytes(id)
+
+	var doc any
+	switch format {
+	case SBOMCycloneDX:
+		doc = cycloneDXDocument(docDigest, pkgs)
+	default:
+		doc = spdxDocument(docDigest, pkgs)
+	}
+
+	content, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	st := llb.Scratch().File(
+		llb.Mkfile(filename, 0o644, content),
+		container.Pipeline.LLBOpt(),
+	)
+
+	return NewFile(ctx, st, filename, container.Pipeline, container.Platform, nil)
+}
+
+func (container *Container) sbomPackages(ctx context.Context, gw bkgw.Client, ref bkgw.Reference) ([]sbomPackage, error) {
+	distro := osReleaseID(ctx, ref)
+
+	pkgs := []sbomPackage{}
+
+	apkDB, err := readOptionalFile(ctx, ref, "/lib/apk/db/installed")
+	if err != nil {
+		return nil, err
+	}
+	pkgs = append(pkgs, apkPackages(apkDB, distro)...)
+
+	dpkgDB, err := readOptionalFile(ctx, ref, "/var/lib/dpkg/status")
+	if err != nil {
+		return nil, err
+	}
+	pkgs = append(pkgs, dpkgPackages(dpkgDB, distro)...)
+
+	// distroless images list each package in a separate file
+	statusDir, err := ref.ReadDir(ctx, bkgw.ReadDirRequest{Path: "/var/lib/dpkg/status.d"})
+	if err == nil {
+		for _, entry := range statusDir {
+			status, err := readOptionalFile(ctx, ref, path.Join("/var/lib/dpkg/status.d", entry.GetPath()))
+			if err != nil {
+				return nil, err
+			}
+			pkgs = append(pkgs, dpkgPackages(status, distro)...)
+		}
+	}
+
+	rpmPkgs, err := container.rpmPackages(ctx, gw, ref, distro)
+	if err != nil {
+		return nil, err
+	}
+	pkgs = append(pkgs, rpmPkgs...)
+
+	goPkgs, err := goPackages(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	pkgs = append(pkgs, goPkgs...)
+
+	return pkgs, nil
+}
+
+func readOptionalFile(ctx context.Context, ref bkgw.Reference, filePath string) ([]byte, error) {
+	if _, err := ref.StatFile(ctx, bkgw.StatRequest{Path: filePath}); err != nil {
+		// not there
+		return nil, nil
+	}
+
+	return ref.ReadFile(ctx, bkgw.ReadRequest{Filename: filePath})
+}
+
+// osReleaseID returns the ID of the distribution, e.g. "debian".
+func osReleaseID(ctx context.Context, ref bkgw.Reference) string {
+	for _, p := range []string{"/etc/os-release", "/usr/lib/os-release"} {
+		content, err := readOptionalFile(ctx, ref, p)
+		if err != nil || content == nil {
+			continue
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		for scanner.Scan() {
+			key, val, ok := strings.Cut(scanner.Text(), "=")
+			if ok && key == "ID" {
+				return strings.Trim(val, `"'`)
+			}
+		}
+	}
+
+	return ""
+}
+
+// apkPackages parses an apk database, where each package is a block of
+// single letter fields.
+func apkPackages(db []byte, distro string) []sbomPackage {
+	if distro == "" {
+		distro = "alpine"
+	}
+
+	pkgs := []sbomPackage{}
+	for _, block := range strings.Split(string(db), "\n\n") {
+		fields := map[string]string{}
+		for _, line := range strings.Split(block, "\n") {
+			key, val, ok := strings.Cut(line, ":")
+			if ok {
+				fields[key] = val
+			}
+		}
+
+		if fields["P"] == "" {
+			continue
+		}
+
+		pkgs = append(pkgs, sbomPackage{
+			Name:    fields["P"],
+			Version: fields["V"],
+			License: fields["L"],
+			PURL:    purl("apk", distro, fields["P"], fields["V"], fields["A"]),
+		})
+	}
+
+	return pkgs
+}
+
+// dpkgPackages parses a dpkg status file, where each package is a paragraph
+// of fields.
+func dpkgPackages(db []byte, distro string) []sbomPackage {
+	if distro == "" {
+		distro = "debian"
+	}
+
+	pkgs := []sbomPackage{}
+	for _, paragraph := range strings.Split(string(db), "\n\n") {
+		fields := map[string]string{}
+		for _, line := range strings.Split(paragraph, "\n") {
+			if strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t") {
+				// continuation of a multi-line field
+				continue
+			}
+
+			key, val, ok := strings.Cut(line, ":")
+			if ok {
+				fields[key] = strings.TrimSpace(val)
+			}
+		}
+
+		if fields["Package"] == "" {
+			continue
+		}
+
+		// distroless status files have no Status field
+		if status, found := fields["Status"]; found && !strings.HasSuffix(status, " installed") {
+			continue
+		}
+
+		pkgs = append(pkgs, sbomPackage{
+			Name:    fields["Package"],
+			Version: fields["Version"],
+			PURL:    purl("deb", distro, fields["Package"], fields["Version"], fields["Architecture"]),
+		})
+	}
+
+	return pkgs
+}
+
+// rpmPackages lists rpm packages by running rpm in the container, since
+// only rpm itself can read its database.
+func (container *Container) rpmPackages(ctx context.Context, gw bkgw.Client, ref bkgw.Reference, distro string) ([]sbomPackage, error) {
+	var found bool
+	for _, dbPath := range []string{"/var/lib/rpm", "/usr/lib/sysimage/rpm"} {
+		if _, err := ref.StatFile(ctx, bkgw.StatRequest{Path: dbPath}); err == nil {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, nil
+	}
+
+	execed, err := container.WithExec(ctx, gw, container.Platform, ContainerExecOpts{
+		Args: []string{
+			"rpm", "--query", "--all",
+			"--queryformat", `%{NAME}\t%|EPOCH?{%{EPOCH}:}:{}|%{VERSION}-%{RELEASE}\t%{ARCH}\t%{LICENSE}\n`,
+		},
+		SkipEntrypoint: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := execed.MetaFileContents(ctx, gw, "stdout")
+	if err != nil {
+		return nil, fmt.Errorf("list rpm packages: %w", err)
+	}
+
+	pkgs := []sbomPackage{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 || fields[0] == "gpg-pubkey" {
+			continue
+		}
+
+		name, version, arch, license := fields[0], fields[1], fields[2], fields[3]
+		pkgs = append(pkgs, sbomPackage{
+			Name:    name,
+			Version: version,
+			License: license,
+			PURL:    purl("rpm", distro, name, version, arch),
+		})
+	}
+
+	return pkgs, nil
+}
+
+// goPackages lists the modules built into the Go binaries of the rootfs.
+func goPackages(ctx context.Context, ref bkgw.Reference) ([]sbomPackage, error) {
+	pkgs := []sbomPackage{}
+	seen := map[string]bool{}
+
+	add := func(name, version string) {
+		p := purl("golang", "", name, version, "")
+		if !seen[p] {
+			seen[p] = true
+			pkgs = append(pkgs, sbomPackage{
+				Name:    name,
+				Version: version,
+				PURL:    p,
+			})
+		}
+	}
+
+	err := walkRef(ctx, ref, "/", func(entryPath string, stat *fstypes.Stat) (bool, error) {
+		mode := fs.FileMode(stat.GetMode())
+		if mode.IsDir() {
+			switch entryPath {
+			case "proc", "sys", "dev":
+				return false, nil
+			}
+			return true, nil
+		}
+
+		if !mode.IsRegular() || mode.Perm()&0o111 == 0 || stat.GetSize_() < 4 {
+			return false, nil
+		}
+
+		r := &refReaderAt{ctx: ctx, ref: ref, path: path.Join("/", entryPath)}
+
+		magic := make([]byte, 4)
+		if _, err := r.ReadAt(magic, 0); err != nil {
+			return false, nil
+		}
+		if string(magic) != "\x7fELF" {
+			return false, nil
+		}
+
+		info, err := buildinfo.Read(r)
+		if err != nil {
+			// not a Go binary
+			return false, nil
+		}
+
+		add("stdlib", info.GoVersion)
+		add(info.Main.Path, info.Main.Version)
+		for _, dep := range info.Deps {
+			if dep.Replace != nil {
+				dep = dep.Replace
+			}
+			add(dep.Path, dep.Version)
+		}
+
+		return false, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return pkgs, nil
+}
+
+// refReaderAt reads a file from a reference in ranges.
+type refReaderAt struct {
+	ctx  context.Context
+	ref  bkgw.Reference
+	path string
+}
+
+func (r *refReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	data, err := r.ref.ReadFile(r.ctx, bkgw.ReadRequest{
+		Filename: r.path,
+		Range: &bkgw.FileRange{
+			Offset: int(off),
+			Length: len(p),
+		},
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	n := copy(p, data)
+	if n < len(p) {
+		return n, io.EOF
+	}
+
+	return n, nil
+}
+
+// purlVersionEscaper escapes the characters of versions (e.g. Debian epochs)
+// that path escaping keeps but purls reserve.
+var purlVersionEscaper = strings.NewReplacer(":", "%3A", "+", "%2B")
+
+// purl returns the package URL of a package.
+func purl(typ, namespace, name, version, arch string) string {
+	p := "pkg:" + typ + "/"
+	if namespace != "" {
+		p += url.PathEscape(namespace) + "/"
+	}
+
+	if typ == "golang" {
+		// module paths keep their slashes
+		p += name
+	} else {
+		p += url.PathEscape(name)
+	}
+
+	if version != "" {
+		p += "@" + purlVersionEscaper.Replace(url.PathEscape(version))
+	}
+
+	if arch != "" {
+		p += "?arch=" + url.QueryEscape(arch)
+	}
+
+	return p
+}
+
+func spdxDocument(id digest.Digest, pkgs []sbomPackage) any {
+	type externalRef struct {
+		ReferenceCategory string `json:"referenceCategory"`
+		ReferenceType     string `json:"referenceType"`
+		ReferenceLocator  string `json:"referenceLocator"`
+	}
+
+	type spdxPackage struct {
+		Name             string        `json:"name"`
+		SPDXID           string        `json:"SPDXID"`
+		VersionInfo      string        `json:"versionInfo,omitempty"`
+		DownloadLocation string        `json:"downloadLocation"`
+		LicenseConcluded string        `json:"licenseConcluded"`
+		LicenseDeclared  string        `json:"licenseDeclared"`
+		FilesAnalyzed    bool          `json:"filesAnalyzed"`
+		ExternalRefs     []externalRef `json:"externalRefs"`
+	}
+
+	packages := make([]spdxPackage, 0, len(pkgs))
+	for i, pkg := range pkgs {
+		license := pkg.License
+		if license == "" {
+			license = "NOASSERTION"
+		}
+
+		packages = append(packages, spdxPackage{
+			Name:             pkg.Name,
+			SPDXID:           fmt.Sprintf("SPDXRef-Package-%d", i),
+			VersionInfo:      pkg.Version,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  license,
+			ExternalRefs: []externalRef{
+				{
+					ReferenceCategory: "PACKAGE-MANAGER",
+					ReferenceType:     "purl",
+					ReferenceLocator:  pkg.PURL,
+				},
+			},
+		})
+	}
+
+	return map[string]any{
+		"spdxVersion":       "SPDX-2.3",
+		"dataLicense":       "CC0-1.0",
+		"SPDXID":            "SPDXRef-DOCUMENT",
+		"name":              "container",
+		"documentNamespace": "https://dagger.io/spdx/" + id.Encoded(),
+		"creationInfo": map[string]any{
+			"created":  "1970-01-01T00:00:00Z",
+			"creators": []string{"Tool: dagger"},
+		},
+		"packages": packages,
+	}
+}
+
+func cycloneDXDocument(id digest.Digest, pkgs []sbomPackage) any {
+	type license struct {
+		License struct {
+			Name string `json:"name"`
+		} `json:"license"`
+	}
+
+	type component struct {
+		Type     string    `json:"type"`
+		BOMRef   string    `json:"bom-ref"`
+		Name     string    `json:"name"`
+		Version  string    `json:"version,omitempty"`
+		PURL     string    `json:"purl"`
+		Licenses []license `json:"licenses,omitempty"`
+	}
+
+	components := make([]component, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		c := component{
+			Type:    "library",
+			BOMRef:  pkg.PURL,
+			Name:    pkg.Name,
+			Version: pkg.Version,
+			PURL:    pkg.PURL,
+		}
+
+		if pkg.License != "" {
+			var l license
+			l.License.Name = pkg.License
+			c.Licenses = []license{l}
+		}
+
+		components = append(components, c)
+	}
+
+	// derive a stable serial number from the document's digest
+	hex := id.Encoded()
+	serial := fmt.Sprintf("urn:uuid:%s-%s-%s-%s-%s", hex[0:8], hex[8:12], hex[12:16], hex[16:20], hex[20:32])
+
+	return map[string]any{
+		"bomFormat":    "CycloneDX",
+		"specVersion":  "1.4",
+		"serialNumber": serial,
+		"version":      1,
+		"metadata": map[string]any{
+			"timestamp": "1970-01-01T00:00:00Z",
+			"tools": []map[string]string{
+				{"vendor": "Dagger", "name": "dagger"},
+			},
+		},
+		"components": components,
+	}
+}
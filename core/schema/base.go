@@ -1,6 +1,8 @@
 package schema
 
 import (
+	"context"
+
 	"github.com/containerd/containerd/content"
 	"github.com/dagger/dagger/auth"
 	"github.com/dagger/dagger/core"
@@ -13,12 +15,16 @@ import (
 )
 
 type InitializeArgs struct {
+	// SessionContext is canceled when the session ends.
+	SessionContext context.Context
+
 	Router        *router.Router
 	Workdir       string
 	Gateway       *core.GatewayClient
 	BKClient      *bkclient.Client
 	SolveOpts     bkclient.SolveOpt
 	SolveCh       chan *bkclient.SolveStatus
+	ExecOutputs   *core.ExecOutputs
 	OCIStore      content.Store
 	Platform      specs.Platform
 	DisableHostRW bool
@@ -31,24 +37,33 @@ type InitializeArgs struct {
 
 func New(params InitializeArgs) (router.ExecutableSchema, error) {
 	base := &baseSchema{
-		router:    params.Router,
-		gw:        params.Gateway,
-		bkClient:  params.BKClient,
-		solveOpts: params.SolveOpts,
-		solveCh:   params.SolveCh,
-		platform:  params.Platform,
-		auth:      params.Auth,
-		secrets:   params.Secrets,
+		sessionCtx: params.SessionContext,
+		router:     params.Router,
+		gw:         params.Gateway,
+		bkClient:   params.BKClient,
+		solveOpts:  params.SolveOpts,
+		solveCh:    params.SolveCh,
+		outputs:    params.ExecOutputs,
+		platform:   params.Platform,
+		auth:       params.Auth,
+		secrets:    params.Secrets,
+		services:   core.NewServices(params.ExecOutputs),
 
 		// TODO(vito): remove when stable
 		servicesEnabled: params.EnableServices,
 	}
 	host := core.NewHost(params.Workdir, params.DisableHostRW)
+	if params.SessionContext != nil {
+		go func() {
+			<-params.SessionContext.Done()
+			host.Close()
+		}()
+	}
 	return router.MergeExecutableSchemas("core",
 		&querySchema{base},
 		&directorySchema{base, host},
 		&fileSchema{base, host},
-		&gitSchema{base},
+		&gitSchema{base, host},
 		&containerSchema{base, host, params.OCIStore},
 		&cacheSchema{base},
 		&secretSchema{base},
@@ -60,18 +75,23 @@ func New(params InitializeArgs) (router.ExecutableSchema, error) {
 		&httpSchema{base},
 		&platformSchema{base},
 		&socketSchema{base, host},
+		&imageSchema{base},
+		&serviceSchema{base},
 	)
 }
 
 type baseSchema struct {
-	router    *router.Router
-	gw        bkgw.Client
-	bkClient  *bkclient.Client
-	solveOpts bkclient.SolveOpt
-	solveCh   chan *bkclient.SolveStatus
-	platform  specs.Platform
-	auth      *auth.RegistryAuthProvider
-	secrets   *secret.Store
+	sessionCtx context.Context
+	router     *router.Router
+	gw         bkgw.Client
+	bkClient   *bkclient.Client
+	solveOpts  bkclient.SolveOpt
+	solveCh    chan *bkclient.SolveStatus
+	outputs    *core.ExecOutputs
+	platform   specs.Platform
+	auth       *auth.RegistryAuthProvider
+	secrets    *secret.Store
+	services   *core.Services
 
 	// TODO(vito): remove when stable
 	servicesEnabled bool
@@ -2,8 +2,10 @@ package schema
 
 import (
 	"fmt"
+	"net/http"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/containerd/containerd/content"
 	"github.com/dagger/dagger/core"
@@ -34,6 +36,10 @@ func (s *containerSchema) Resolvers() router.Resolvers {
 		"ContainerID": stringResolver(core.ContainerID("")),
 		"Query": router.ObjectResolver{
 			"container": router.ToResolver(s.container),
+			"hostImage": router.ToResolver(s.hostImage),
+		},
+		"Terminal": router.ObjectResolver{
+			"websocketEndpoint": router.ToResolver(s.terminalWebsocketEndpoint),
 		},
 		"Container": router.ObjectResolver{
 			"id":                   router.ToResolver(s.id),
@@ -44,6 +50,8 @@ func (s *containerSchema) Resolvers() router.Resolvers {
 			"fs":                   router.ToResolver(s.rootfs), // deprecated
 			"withRootfs":           router.ToResolver(s.withRootfs),
 			"withFS":               router.ToResolver(s.withRootfs), // deprecated
+			"squash":               router.ToResolver(s.squash),
+			"withHistory":          router.ToResolver(s.withHistory),
 			"file":                 router.ToResolver(s.file),
 			"directory":            router.ToResolver(s.directory),
 			"user":                 router.ToResolver(s.user),
@@ -74,15 +82,25 @@ func (s *containerSchema) Resolvers() router.Resolvers {
 			"withoutMount":         router.ToResolver(s.withoutMount),
 			"withFile":             router.ToResolver(s.withFile),
 			"withNewFile":          router.ToResolver(s.withNewFile),
+			"withSymlink":          router.ToResolver(s.withSymlink),
+			"withRename":           router.ToResolver(s.withRename),
 			"withDirectory":        router.ToResolver(s.withDirectory),
 			"withExec":             router.ToResolver(s.withExec),
 			"exec":                 router.ToResolver(s.withExec), // deprecated
+			"terminal":             router.ToResolver(s.terminal),
 			"exitCode":             router.ToResolver(s.exitCode),
 			"stdout":               router.ToResolver(s.stdout),
 			"stderr":               router.ToResolver(s.stderr),
+			"outputStream":         router.ToResolver(s.outputStream),
+			"execResult":           router.ToResolver(s.execResult),
 			"publish":              router.ToResolver(s.publish),
+			"publishWithDigests":   router.ToResolver(s.publishWithDigests),
+			"withAttestation":      router.ToResolver(s.withAttestation),
+			"sbom":                 router.ToResolver(s.sbom),
+			"withSbom":             router.ToResolver(s.withSbom),
 			"platform":             router.ToResolver(s.platform),
 			"export":               router.ToResolver(s.export),
+			"exportToHost":         router.ToResolver(s.exportToHost),
 			"import":               router.ToResolver(s.import_),
 			"withRegistryAuth":     router.ToResolver(s.withRegistryAuth),
 			"withoutRegistryAuth":  router.ToResolver(s.withoutRegistryAuth),
@@ -93,6 +111,8 @@ func (s *containerSchema) Resolvers() router.Resolvers {
 			"hostname":             router.ToResolver(s.hostname),
 			"endpoint":             router.ToResolver(s.endpoint),
 			"withServiceBinding":   router.ToResolver(s.withServiceBinding),
+			"withHealthcheck":      router.ToResolver(s.withHealthcheck),
+			"asService":            router.ToResolver(s.asService),
 		},
 	}
 }
@@ -119,9 +139,38 @@ func (s *containerSchema) container(ctx *router.Context, parent *core.Query, arg
 	if err != nil {
 		return nil, err
 	}
+	if args.ID == "" {
+		ctr.RegistryMirrors = parent.RegistryMirrors()
+	}
 	return ctr, err
 }
 
+type hostImageArgs struct {
+	Ref      string
+	Runtime  core.ContainerRuntime
+	Socket   core.SocketID
+	Platform *specs.Platform
+}
+
+func (s *containerSchema) hostImage(ctx *router.Context, parent *core.Query, args hostImageArgs) (*core.Container, error) {
+	platform := s.baseSchema.platform
+	if args.Platform != nil {
+		platform = *args.Platform
+	}
+
+	socket, err := runtimeSocketArg(args.Socket)
+	if err != nil {
+		return nil, err
+	}
+
+	ctr, err := core.NewContainer("", parent.PipelinePath(), platform)
+	if err != nil {
+		return nil, err
+	}
+
+	return ctr.FromHost(ctx, s.host, args.Runtime, socket, args.Ref, s.ociStore)
+}
+
 func (s *containerSchema) id(ctx *router.Context, parent *core.Container, args any) (core.ContainerID, error) {
 	return parent.ID()
 }
@@ -131,7 +180,7 @@ type containerFromArgs struct {
 }
 
 func (s *containerSchema) from(ctx *router.Context, parent *core.Container, args containerFromArgs) (*core.Container, error) {
-	return parent.From(ctx, s.gw, args.Address)
+	return parent.From(ctx, s.gw, args.Address, s.auth)
 }
 
 type containerBuildArgs struct {
@@ -162,6 +211,20 @@ func (s *containerSchema) withRootfs(ctx *router.Context, parent *core.Container
 	return parent.WithRootFS(ctx, dir)
 }
 
+func (s *containerSchema) squash(ctx *router.Context, parent *core.Container, args any) (*core.Container, error) {
+	return parent.Squash(ctx)
+}
+
+type containerWithHistoryArgs struct {
+	CreatedBy  string
+	Comment    string
+	EmptyLayer bool
+}
+
+func (s *containerSchema) withHistory(ctx *router.Context, parent *core.Container, args containerWithHistoryArgs) (*core.Container, error) {
+	return parent.WithHistory(ctx, args.CreatedBy, args.Comment, args.EmptyLayer)
+}
+
 type containerPipelineArgs struct {
 	Name        string
 	Description string
@@ -184,6 +247,43 @@ func (s *containerSchema) withExec(ctx *router.Context, parent *core.Container,
 	return parent.WithExec(ctx, s.gw, s.baseSchema.platform, args.ContainerExecOpts)
 }
 
+type containerTerminalArgs struct {
+	Args []string
+}
+
+func (s *containerSchema) terminal(ctx *router.Context, parent *core.Container, args containerTerminalArgs) (*core.Terminal, error) {
+	term, err := core.NewTerminal(parent, args.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint, err := term.WebsocketEndpoint()
+	if err != nil {
+		return nil, err
+	}
+
+	s.muxStream(endpoint, term.Handler(s.gw))
+
+	return term, nil
+}
+
+// muxStream serves a stream's handler at the endpoint until a stream served
+// from it ends.
+func (s *containerSchema) muxStream(endpoint string, handler http.Handler) {
+	var unregister func()
+	registered := make(chan struct{})
+	unregister = s.router.MuxEndpoint(endpoint, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-registered
+		defer unregister()
+		handler.ServeHTTP(w, r)
+	}))
+	close(registered)
+}
+
+func (s *containerSchema) terminalWebsocketEndpoint(ctx *router.Context, parent *core.Terminal, args any) (string, error) {
+	return parent.WebsocketEndpoint()
+}
+
 func (s *containerSchema) exitCode(ctx *router.Context, parent *core.Container, args any) (int, error) {
 	return parent.ExitCode(ctx, s.gw)
 }
@@ -196,6 +296,48 @@ func (s *containerSchema) stderr(ctx *router.Context, parent *core.Container, ar
 	return parent.MetaFileContents(ctx, s.gw, "stderr")
 }
 
+// ExecResult is the GraphQL representation of a core.ExecResult.
+type ExecResult struct {
+	ExitCode   int    `json:"exitCode"`
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	StartedAt  string `json:"startedAt"`
+	FinishedAt string `json:"finishedAt"`
+	Duration   int    `json:"duration"`
+}
+
+func (s *containerSchema) execResult(ctx *router.Context, parent *core.Container, args any) (*ExecResult, error) {
+	result, err := parent.ExecResult(ctx, s.gw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ExecResult{
+		ExitCode:   result.ExitCode,
+		Stdout:     result.Stdout,
+		Stderr:     result.Stderr,
+		StartedAt:  result.StartedAt.Format(time.RFC3339Nano),
+		FinishedAt: result.FinishedAt.Format(time.RFC3339Nano),
+		Duration:   int(result.Duration().Milliseconds()),
+	}, nil
+}
+
+func (s *containerSchema) outputStream(ctx *router.Context, parent *core.Container, args any) (string, error) {
+	endpoint, err := parent.OutputEndpoint()
+	if err != nil {
+		return "", err
+	}
+
+	handler, err := parent.OutputHandler(s.gw, s.outputs)
+	if err != nil {
+		return "", err
+	}
+
+	s.muxStream(endpoint, handler)
+
+	return endpoint, nil
+}
+
 type containerWithEntrypointArgs struct {
 	Args []string
 }
@@ -428,12 +570,86 @@ func (s *containerSchema) withMountedDirectory(ctx *router.Context, parent *core
 }
 
 type containerPublishArgs struct {
-	Address          string
-	PlatformVariants []core.ContainerID
+	Address           string
+	PlatformVariants  []core.ContainerID
+	Addresses         []string
+	Annotations       []core.ImageAnnotation
+	MediaTypes        core.ImageMediaTypes
+	ForcedCompression core.ImageLayerCompression
+	Sign              *imageSigningKeyArg
+}
+
+type imageSigningKeyArg struct {
+	Key      core.SecretID
+	Password core.SecretID
+}
+
+func (s *containerSchema) publishOpts(ctx *router.Context, args containerPublishArgs) (core.PublishOpts, error) {
+	opts := core.PublishOpts{
+		Addresses:         args.Addresses,
+		Annotations:       args.Annotations,
+		MediaTypes:        args.MediaTypes,
+		ForcedCompression: args.ForcedCompression,
+	}
+
+	if args.Sign != nil {
+		key, err := s.secrets.GetSecret(ctx, args.Sign.Key.String())
+		if err != nil {
+			return opts, err
+		}
+
+		opts.Sign = &core.ImageSigningKey{Key: key}
+
+		if args.Sign.Password != "" {
+			opts.Sign.Password, err = s.secrets.GetSecret(ctx, args.Sign.Password.String())
+			if err != nil {
+				return opts, err
+			}
+		}
+	}
+
+	return opts, nil
 }
 
 func (s *containerSchema) publish(ctx *router.Context, parent *core.Container, args containerPublishArgs) (string, error) {
-	return parent.Publish(ctx, args.Address, args.PlatformVariants, s.bkClient, s.solveOpts, s.solveCh)
+	res, err := s.publishWithDigests(ctx, parent, args)
+	if err != nil {
+		return "", err
+	}
+	return res.Refs[0], nil
+}
+
+func (s *containerSchema) publishWithDigests(ctx *router.Context, parent *core.Container, args containerPublishArgs) (*core.PublishResult, error) {
+	opts, err := s.publishOpts(ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	return parent.Publish(ctx, args.Address, args.PlatformVariants, opts, s.auth, s.secrets, s.bkClient, s.solveOpts, s.solveCh)
+}
+
+type containerWithAttestationArgs struct {
+	PredicateType string
+	File          core.FileID
+}
+
+func (s *containerSchema) withAttestation(ctx *router.Context, parent *core.Container, args containerWithAttestationArgs) (*core.Container, error) {
+	file, err := args.File.ToFile()
+	if err != nil {
+		return nil, err
+	}
+	return parent.WithAttestation(ctx, args.PredicateType, file)
+}
+
+type containerSbomArgs struct {
+	Format core.SBOMFormat
+}
+
+func (s *containerSchema) sbom(ctx *router.Context, parent *core.Container, args containerSbomArgs) (*core.File, error) {
+	return parent.SBOM(ctx, s.gw, args.Format)
+}
+
+func (s *containerSchema) withSbom(ctx *router.Context, parent *core.Container, args containerSbomArgs) (*core.Container, error) {
+	return parent.WithSBOM(ctx, args.Format)
 }
 
 type containerWithMountedFileArgs struct {
@@ -612,6 +828,14 @@ func (s *containerSchema) withNewFile(ctx *router.Context, parent *core.Containe
 	return parent.WithNewFile(ctx, s.gw, args.Path, []byte(args.Contents), args.Permissions, args.Owner)
 }
 
+func (s *containerSchema) withSymlink(ctx *router.Context, parent *core.Container, args withSymlinkArgs) (*core.Container, error) {
+	return parent.WithSymlink(ctx, s.gw, args.Target, args.LinkName)
+}
+
+func (s *containerSchema) withRename(ctx *router.Context, parent *core.Container, args withRenameArgs) (*core.Container, error) {
+	return parent.WithRename(ctx, s.gw, args.From, args.To)
+}
+
 type containerWithUnixSocketArgs struct {
 	Path   string
 	Source core.SocketID
@@ -651,6 +875,35 @@ func (s *containerSchema) export(ctx *router.Context, parent *core.Container, ar
 	return true, nil
 }
 
+type containerExportToHostArgs struct {
+	Tag     string
+	Runtime core.ContainerRuntime
+	Socket  core.SocketID
+}
+
+func (s *containerSchema) exportToHost(ctx *router.Context, parent *core.Container, args containerExportToHostArgs) (bool, error) {
+	socket, err := runtimeSocketArg(args.Socket)
+	if err != nil {
+		return false, err
+	}
+
+	if err := parent.ExportToHost(ctx, s.host, args.Runtime, socket, args.Tag, s.bkClient, s.solveOpts, s.solveCh); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// runtimeSocketArg loads the socket a container runtime is reached through,
+// if one was given.
+func runtimeSocketArg(id core.SocketID) (*core.Socket, error) {
+	if id == "" {
+		return nil, nil
+	}
+
+	return id.ToSocket()
+}
+
 type containerImportArgs struct {
 	Source core.FileID
 	Tag    string
@@ -715,6 +968,14 @@ func (s *containerSchema) hostname(ctx *router.Context, parent *core.Container,
 	return parent.HostnameOrErr()
 }
 
+func (s *containerSchema) asService(ctx *router.Context, parent *core.Container, args any) (*core.Service, error) {
+	if !s.servicesEnabled {
+		return nil, ErrServicesDisabled
+	}
+
+	return core.NewService(parent)
+}
+
 type containerEndpointArgs struct {
 	Port   int
 	Scheme string
@@ -764,6 +1025,30 @@ func (s *containerSchema) withExposedPort(ctx *router.Context, parent *core.Cont
 	})
 }
 
+type containerWithHealthcheckArgs struct {
+	Args        []string
+	HTTPPath    string
+	Port        int
+	Interval    int
+	Retries     int
+	StartPeriod int
+}
+
+func (s *containerSchema) withHealthcheck(ctx *router.Context, parent *core.Container, args containerWithHealthcheckArgs) (*core.Container, error) {
+	if !s.servicesEnabled {
+		return nil, ErrServicesDisabled
+	}
+
+	return parent.WithHealthcheck(core.ContainerHealthcheck{
+		Args:        args.Args,
+		HTTPPath:    args.HTTPPath,
+		HTTPPort:    args.Port,
+		Interval:    args.Interval,
+		Retries:     args.Retries,
+		StartPeriod: args.StartPeriod,
+	})
+}
+
 type containerWithoutExposedPortArgs struct {
 	Protocol core.NetworkProtocol
 	Port     int
@@ -38,6 +38,9 @@ func (s *directorySchema) Resolvers() router.Resolvers {
 			"id":               router.ToResolver(s.id),
 			"pipeline":         router.ToResolver(s.pipeline),
 			"entries":          router.ToResolver(s.entries),
+			"glob":             router.ToResolver(s.glob),
+			"stat":             router.ToResolver(s.stat),
+			"digest":           router.ToResolver(s.digest),
 			"file":             router.ToResolver(s.file),
 			"withFile":         router.ToResolver(s.withFile),
 			"withNewFile":      router.ToResolver(s.withNewFile),
@@ -47,6 +50,10 @@ func (s *directorySchema) Resolvers() router.Resolvers {
 			"withTimestamps":   router.ToResolver(s.withTimestamps),
 			"withNewDirectory": router.ToResolver(s.withNewDirectory),
 			"withoutDirectory": router.ToResolver(s.withoutDirectory),
+			"withSymlink":      router.ToResolver(s.withSymlink),
+			"withRename":       router.ToResolver(s.withRename),
+			"withTemplate":     router.ToResolver(s.withTemplate),
+			"asTarball":        router.ToResolver(s.asTarball),
 			"diff":             router.ToResolver(s.diff),
 			"export":           router.ToResolver(s.export),
 			"dockerBuild":      router.ToResolver(s.dockerBuild),
@@ -133,6 +140,36 @@ func (s *directorySchema) entries(ctx *router.Context, parent *core.Directory, a
 	return parent.Entries(ctx, s.gw, args.Path)
 }
 
+type globArgs struct {
+	Pattern string
+}
+
+func (s *directorySchema) glob(ctx *router.Context, parent *core.Directory, args globArgs) ([]string, error) {
+	return parent.Glob(ctx, s.gw, args.Pattern)
+}
+
+type statArgs struct {
+	Path string
+}
+
+func (s *directorySchema) stat(ctx *router.Context, parent *core.Directory, args statArgs) (*Stat, error) {
+	info, err := parent.Stat(ctx, s.gw, args.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStat(args.Path, info), nil
+}
+
+func (s *directorySchema) digest(ctx *router.Context, parent *core.Directory, args any) (string, error) {
+	dgst, err := parent.Digest(ctx, s.gw)
+	if err != nil {
+		return "", err
+	}
+
+	return dgst.String(), nil
+}
+
 type dirFileArgs struct {
 	Path string
 }
@@ -182,6 +219,52 @@ func (s *directorySchema) withoutFile(ctx *router.Context, parent *core.Director
 	return parent.Without(ctx, args.Path)
 }
 
+type withSymlinkArgs struct {
+	Target   string
+	LinkName string
+}
+
+func (s *directorySchema) withSymlink(ctx *router.Context, parent *core.Directory, args withSymlinkArgs) (*core.Directory, error) {
+	return parent.WithSymlink(ctx, args.Target, args.LinkName)
+}
+
+type withRenameArgs struct {
+	From string
+	To   string
+}
+
+func (s *directorySchema) withRename(ctx *router.Context, parent *core.Directory, args withRenameArgs) (*core.Directory, error) {
+	return parent.WithRename(ctx, args.From, args.To)
+}
+
+type templateVariable struct {
+	Name  string
+	Value string
+}
+
+type withTemplateArgs struct {
+	Path      string
+	Dest      string
+	Variables []templateVariable
+}
+
+func (s *directorySchema) withTemplate(ctx *router.Context, parent *core.Directory, args withTemplateArgs) (*core.Directory, error) {
+	vars := map[string]string{}
+	for _, v := range args.Variables {
+		vars[v.Name] = v.Value
+	}
+
+	return parent.WithTemplate(ctx, s.gw, args.Path, args.Dest, vars)
+}
+
+type asTarballArgs struct {
+	Compression core.ArchiveCompression
+}
+
+func (s *directorySchema) asTarball(ctx *router.Context, parent *core.Directory, args asTarballArgs) (*core.File, error) {
+	return parent.AsTarball(ctx, args.Compression)
+}
+
 type diffArgs struct {
 	Other core.DirectoryID
 }
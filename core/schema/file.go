@@ -1,8 +1,13 @@
 package schema
 
 import (
+	"io/fs"
+	"path"
+	"time"
+
 	"github.com/dagger/dagger/core"
 	"github.com/dagger/dagger/router"
+	fstypes "github.com/tonistiigi/fsutil/types"
 )
 
 type fileSchema struct {
@@ -34,8 +39,13 @@ func (s *fileSchema) Resolvers() router.Resolvers {
 			"contents":       router.ToResolver(s.contents),
 			"secret":         router.ToResolver(s.secret),
 			"size":           router.ToResolver(s.size),
+			"stat":           router.ToResolver(s.stat),
 			"export":         router.ToResolver(s.export),
 			"withTimestamps": router.ToResolver(s.withTimestamps),
+			"withReplaced":   router.ToResolver(s.withReplaced),
+			"withAppended":   router.ToResolver(s.withAppended),
+			"unpack":         router.ToResolver(s.unpack),
+			"digest":         router.ToResolver(s.digest),
 		},
 	}
 }
@@ -78,6 +88,55 @@ func (s *fileSchema) size(ctx *router.Context, file *core.File, args any) (int64
 	return info.Size_, nil
 }
 
+func (s *fileSchema) stat(ctx *router.Context, file *core.File, args any) (*Stat, error) {
+	info, err := file.Stat(ctx, s.gw)
+	if err != nil {
+		return nil, err
+	}
+
+	return newStat(file.File, info), nil
+}
+
+// Stat is the GraphQL representation of a fstypes.Stat.
+type Stat struct {
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	Mode        int    `json:"mode"`
+	IsDirectory bool   `json:"isDirectory"`
+	IsSymlink   bool   `json:"isSymlink"`
+	LinkTarget  string `json:"linkTarget"`
+	UID         int    `json:"uid"`
+	GID         int    `json:"gid"`
+	ModifiedAt  string `json:"modifiedAt"`
+}
+
+func newStat(filePath string, info *fstypes.Stat) *Stat {
+	mode := fs.FileMode(info.Mode)
+
+	unixMode := int(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		unixMode |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		unixMode |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		unixMode |= 0o1000
+	}
+
+	return &Stat{
+		Name:        path.Base(filePath),
+		Size:        info.Size_,
+		Mode:        unixMode,
+		IsDirectory: mode.IsDir(),
+		IsSymlink:   mode&fs.ModeSymlink != 0,
+		LinkTarget:  info.Linkname,
+		UID:         int(info.Uid),
+		GID:         int(info.Gid),
+		ModifiedAt:  time.Unix(0, info.ModTime).UTC().Format(time.RFC3339Nano),
+	}
+}
+
 type fileExportArgs struct {
 	Path string
 }
@@ -98,3 +157,38 @@ type fileWithTimestampsArgs struct {
 func (s *fileSchema) withTimestamps(ctx *router.Context, parent *core.File, args fileWithTimestampsArgs) (*core.File, error) {
 	return parent.WithTimestamps(ctx, args.Timestamp)
 }
+
+type fileWithReplacedArgs struct {
+	Search      string
+	Replacement string
+	All         bool
+}
+
+func (s *fileSchema) withReplaced(ctx *router.Context, parent *core.File, args fileWithReplacedArgs) (*core.File, error) {
+	return parent.WithReplaced(ctx, args.Search, args.Replacement, args.All)
+}
+
+type fileWithAppendedArgs struct {
+	Contents string
+}
+
+func (s *fileSchema) withAppended(ctx *router.Context, parent *core.File, args fileWithAppendedArgs) (*core.File, error) {
+	return parent.WithAppended(ctx, args.Contents)
+}
+
+func (s *fileSchema) unpack(ctx *router.Context, parent *core.File, args any) (*core.Directory, error) {
+	return parent.Unpack(ctx)
+}
+
+type fileDigestArgs struct {
+	Algorithm core.DigestAlgorithm
+}
+
+func (s *fileSchema) digest(ctx *router.Context, parent *core.File, args fileDigestArgs) (string, error) {
+	dgst, err := parent.Digest(ctx, s.gw, args.Algorithm)
+	if err != nil {
+		return "", err
+	}
+
+	return dgst.String(), nil
+}
@@ -34,3 +34,9 @@ var Platform string
 
 //go:embed socket.graphqls
 var Socket string
+
+//go:embed image.graphqls
+var Image string
+
+//go:embed service.graphqls
+var Service string
@@ -1,6 +1,9 @@
 package schema
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/dagger/dagger/core"
 	"github.com/dagger/dagger/core/pipeline"
 	"github.com/dagger/dagger/router"
@@ -11,6 +14,8 @@ var _ router.ExecutableSchema = &gitSchema{}
 
 type gitSchema struct {
 	*baseSchema
+
+	host *core.Host
 }
 
 func (s *gitSchema) Name() string {
@@ -27,15 +32,21 @@ func (s *gitSchema) Resolvers() router.Resolvers {
 			"git": router.ToResolver(s.git),
 		},
 		"GitRepository": router.ObjectResolver{
-			"branches": router.ToResolver(s.branches),
-			"branch":   router.ToResolver(s.branch),
-			"tags":     router.ToResolver(s.tags),
-			"tag":      router.ToResolver(s.tag),
-			"commit":   router.ToResolver(s.commit),
+			"branches":   router.ToResolver(s.branches),
+			"branch":     router.ToResolver(s.branch),
+			"tags":       router.ToResolver(s.tags),
+			"tag":        router.ToResolver(s.tag),
+			"commit":     router.ToResolver(s.commit),
+			"log":        router.ToResolver(s.log),
+			"head":       router.ToResolver(s.head),
+			"headBranch": router.ToResolver(s.headBranch),
+			"isDirty":    router.ToResolver(s.isDirty),
 		},
 		"GitRef": router.ObjectResolver{
-			"digest": router.ToResolver(s.digest),
-			"tree":   router.ToResolver(s.tree),
+			"digest":       router.ToResolver(s.digest),
+			"tree":         router.ToResolver(s.tree),
+			"commitInfo":   router.ToResolver(s.commitInfo),
+			"changedFiles": router.ToResolver(s.changedFiles),
 		},
 	}
 }
@@ -45,10 +56,18 @@ func (s *gitSchema) Dependencies() []router.ExecutableSchema {
 }
 
 type gitRepository struct {
-	URL         string            `json:"url"`
-	KeepGitDir  bool              `json:"keepGitDir"`
-	Pipeline    pipeline.Path     `json:"pipeline"`
-	ServiceHost *core.ContainerID `json:"serviceHost,omitempty"`
+	URL            string            `json:"url"`
+	KeepGitDir     bool              `json:"keepGitDir"`
+	HTTPAuthToken  core.SecretID     `json:"httpAuthToken,omitempty"`
+	HTTPAuthHeader core.SecretID     `json:"httpAuthHeader,omitempty"`
+	Submodules     bool              `json:"submodules"`
+	Depth          int               `json:"depth,omitempty"`
+	Pipeline       pipeline.Path     `json:"pipeline"`
+	ServiceHost    *core.ContainerID `json:"serviceHost,omitempty"`
+
+	// HostPath is the root of the working tree of a repository on the host.
+	HostPath           string `json:"hostPath,omitempty"`
+	IncludeUncommitted bool   `json:"includeUncommitted,omitempty"`
 }
 
 type gitRef struct {
@@ -59,15 +78,32 @@ type gitRef struct {
 type gitArgs struct {
 	URL                     string            `json:"url"`
 	KeepGitDir              bool              `json:"keepGitDir"`
+	HTTPAuthToken           core.SecretID     `json:"httpAuthToken"`
+	HTTPAuthHeader          core.SecretID     `json:"httpAuthHeader"`
+	Submodules              *bool             `json:"submodules"`
+	Depth                   int               `json:"depth"`
 	ExperimentalServiceHost *core.ContainerID `json:"experimentalServiceHost"`
 }
 
 func (s *gitSchema) git(ctx *router.Context, parent *core.Query, args gitArgs) (gitRepository, error) {
+	if args.Depth < 0 {
+		return gitRepository{}, fmt.Errorf("invalid depth %d: must not be negative", args.Depth)
+	}
+
+	submodules := true
+	if args.Submodules != nil {
+		submodules = *args.Submodules
+	}
+
 	return gitRepository{
-		URL:         args.URL,
-		KeepGitDir:  args.KeepGitDir,
-		ServiceHost: args.ExperimentalServiceHost,
-		Pipeline:    parent.PipelinePath(),
+		URL:            args.URL,
+		KeepGitDir:     args.KeepGitDir,
+		HTTPAuthToken:  args.HTTPAuthToken,
+		HTTPAuthHeader: args.HTTPAuthHeader,
+		Submodules:     submodules,
+		Depth:          args.Depth,
+		ServiceHost:    args.ExperimentalServiceHost,
+		Pipeline:       parent.PipelinePath(),
 	}, nil
 }
 
@@ -93,6 +129,29 @@ func (s *gitSchema) branch(ctx *router.Context, parent gitRepository, args branc
 	}, nil
 }
 
+func (s *gitSchema) head(ctx *router.Context, parent gitRepository, args any) (gitRef, error) {
+	return gitRef{
+		Repository: parent,
+	}, nil
+}
+
+func (s *gitSchema) headBranch(ctx *router.Context, parent gitRepository, args any) (string, error) {
+	return queryHistory[string](ctx, s, parent, core.GitHeadBranchQuery)
+}
+
+func (s *gitSchema) isDirty(ctx *router.Context, parent gitRepository, args any) (bool, error) {
+	if parent.HostPath == "" {
+		return false, nil
+	}
+
+	history, err := s.host.GitHistory(parent.HostPath)
+	if err != nil {
+		return false, err
+	}
+
+	return history.IsDirty()
+}
+
 func (s *gitSchema) branches(ctx *router.Context, parent any, args any) (any, error) {
 	return nil, ErrNotImplementedYet
 }
@@ -122,6 +181,10 @@ type gitTreeArgs struct {
 }
 
 func (s *gitSchema) tree(ctx *router.Context, parent gitRef, args gitTreeArgs) (*core.Directory, error) {
+	if parent.Repository.HostPath != "" {
+		return s.hostTree(ctx, parent)
+	}
+
 	opts := []llb.GitOption{
 		parent.Repository.Pipeline.LLBOpt(),
 	}
@@ -135,10 +198,175 @@ func (s *gitSchema) tree(ctx *router.Context, parent gitRef, args gitTreeArgs) (
 	if args.SSHAuthSocket != "" {
 		opts = append(opts, llb.MountSSHSock(args.SSHAuthSocket.LLBID()))
 	}
+	if parent.Repository.HTTPAuthToken != "" {
+		opts = append(opts, llb.AuthTokenSecret(parent.Repository.HTTPAuthToken.String()))
+	}
+	if parent.Repository.HTTPAuthHeader != "" {
+		opts = append(opts, llb.AuthHeaderSecret(parent.Repository.HTTPAuthHeader.String()))
+	}
 	var svcs core.ServiceBindings
 	if parent.Repository.ServiceHost != nil {
 		svcs = core.ServiceBindings{*parent.Repository.ServiceHost: nil}
 	}
+
+	// buildkit always checks out submodules and keeps a single commit of
+	// history, so anything else is checked out by the shim
+	if !parent.Repository.Submodules || (parent.Repository.KeepGitDir && parent.Repository.Depth > 1) {
+		if args.SSHKnownHosts != "" || args.SSHAuthSocket != "" {
+			return nil, fmt.Errorf("sshKnownHosts and sshAuthSocket are not supported when skipping submodules or keeping more than one commit of history")
+		}
+
+		// pin the checkout to a commit, so a moving branch or tag is checked out
+		// again rather than cached
+		commit, err := queryHistory[*core.GitCommit](ctx, s, parent.Repository, core.GitCommitInfoQuery, parent.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		remote := parent.Repository.remote()
+		if remote.Depth == 0 && parent.Repository.KeepGitDir {
+			// keep a single commit of history by default, like buildkit
+			remote.Depth = 1
+		}
+
+		return core.NewGitTree(
+			ctx,
+			remote,
+			commit.Hash,
+			parent.Repository.Submodules,
+			parent.Repository.KeepGitDir,
+			parent.Repository.Pipeline,
+			s.platform,
+			svcs,
+		)
+	}
+
 	st := llb.Git(parent.Repository.URL, parent.Name, opts...)
 	return core.NewDirectory(ctx, st, "", parent.Repository.Pipeline, s.platform, svcs)
 }
+
+// hostTree returns the tree of a ref in a repository on the host.
+func (s *gitSchema) hostTree(ctx *router.Context, parent gitRef) (*core.Directory, error) {
+	history, err := s.host.GitHistory(parent.Repository.HostPath)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline := parent.Repository.Pipeline
+
+	if parent.Name == "" && parent.Repository.IncludeUncommitted {
+		files, err := history.WorktreeFiles()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(files) == 0 {
+			return core.NewDirectory(ctx, llb.Scratch(), "", pipeline, s.platform, nil)
+		}
+
+		// upload only what git would see, leaving out ignored files
+		return s.host.Directory(ctx, parent.Repository.HostPath, pipeline, s.platform, core.CopyFilter{
+			Include: files,
+		})
+	}
+
+	return s.host.GitTree(ctx, history, parent.Name, pipeline, s.platform)
+}
+
+// queryHistory answers a query about the repository's history, reading it
+// directly for a repository on the host, or having the shim fetch it inside
+// the engine for a remote one.
+func queryHistory[T any](ctx *router.Context, s *gitSchema, repo gitRepository, query ...string) (T, error) {
+	var res T
+
+	if repo.HostPath != "" {
+		history, err := s.host.GitHistory(repo.HostPath)
+		if err != nil {
+			return res, err
+		}
+
+		answer, err := history.Query(query...)
+		if err != nil {
+			return res, err
+		}
+
+		return answer.(T), nil
+	}
+
+	var svcs core.ServiceBindings
+	if repo.ServiceHost != nil {
+		svcs = core.ServiceBindings{*repo.ServiceHost: nil}
+	}
+
+	return core.WithServices(ctx, s.gw, svcs, func() (T, error) {
+		err := core.QueryGitHistory(ctx, s.gw, repo.remote(), query, &res)
+		return res, err
+	})
+}
+
+// remote returns the remote repository to fetch, with its auth secrets.
+func (repo gitRepository) remote() core.GitRemote {
+	return core.GitRemote{
+		URL:        repo.URL,
+		AuthToken:  repo.HTTPAuthToken,
+		AuthHeader: repo.HTTPAuthHeader,
+		Depth:      repo.Depth,
+	}
+}
+
+type GitCommit struct {
+	Hash      string   `json:"hash"`
+	Author    string   `json:"author"`
+	Committer string   `json:"committer"`
+	Message   string   `json:"message"`
+	Parents   []string `json:"parents"`
+	Timestamp string   `json:"timestamp"`
+}
+
+func newGitCommit(commit core.GitCommit) GitCommit {
+	return GitCommit{
+		Hash:      commit.Hash,
+		Author:    commit.Author.String(),
+		Committer: commit.Committer.String(),
+		Message:   commit.Message,
+		Parents:   commit.Parents,
+		Timestamp: commit.Timestamp.UTC().Format(time.RFC3339),
+	}
+}
+
+func (s *gitSchema) commitInfo(ctx *router.Context, parent gitRef, args any) (*GitCommit, error) {
+	info, err := queryHistory[*core.GitCommit](ctx, s, parent.Repository, core.GitCommitInfoQuery, parent.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	commit := newGitCommit(*info)
+	return &commit, nil
+}
+
+type gitLogArgs struct {
+	From string
+	To   string
+}
+
+func (s *gitSchema) log(ctx *router.Context, parent gitRepository, args gitLogArgs) ([]GitCommit, error) {
+	log, err := queryHistory[[]core.GitCommit](ctx, s, parent, core.GitLogQuery, args.From, args.To)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := make([]GitCommit, 0, len(log))
+	for _, commit := range log {
+		commits = append(commits, newGitCommit(commit))
+	}
+
+	return commits, nil
+}
+
+type gitChangedFilesArgs struct {
+	Base string
+}
+
+func (s *gitSchema) changedFiles(ctx *router.Context, parent gitRef, args gitChangedFilesArgs) ([]string, error) {
+	return queryHistory[[]string](ctx, s, parent.Repository, core.GitChangedFilesQuery, parent.Name, args.Base)
+}
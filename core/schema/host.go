@@ -29,10 +29,13 @@ func (s *hostSchema) Resolvers() router.Resolvers {
 			"host": router.PassthroughResolver,
 		},
 		"Host": router.ObjectResolver{
-			"workdir":     router.ToResolver(s.workdir),
-			"directory":   router.ToResolver(s.directory),
-			"envVariable": router.ToResolver(s.envVariable),
-			"unixSocket":  router.ToResolver(s.socket),
+			"workdir":       router.ToResolver(s.workdir),
+			"directory":     router.ToResolver(s.directory),
+			"envVariable":   router.ToResolver(s.envVariable),
+			"unixSocket":    router.ToResolver(s.socket),
+			"gitRepository": router.ToResolver(s.gitRepository),
+			"tunnel":        router.ToResolver(s.tunnel),
+			"service":       router.ToResolver(s.service),
 		},
 		"HostVariable": router.ObjectResolver{
 			"value":  router.ToResolver(s.envVariableValue),
@@ -88,3 +91,58 @@ type hostSocketArgs struct {
 func (s *hostSchema) socket(ctx *router.Context, parent any, args hostSocketArgs) (*core.Socket, error) {
 	return s.host.Socket(ctx, args.Path)
 }
+
+type hostGitRepositoryArgs struct {
+	Path               string
+	IncludeUncommitted bool
+}
+
+func (s *hostSchema) gitRepository(ctx *router.Context, parent *core.Query, args hostGitRepositoryArgs) (gitRepository, error) {
+	history, err := s.host.GitHistory(args.Path)
+	if err != nil {
+		return gitRepository{}, err
+	}
+
+	root, err := history.Root()
+	if err != nil {
+		return gitRepository{}, err
+	}
+
+	return gitRepository{
+		URL:                root,
+		HostPath:           root,
+		IncludeUncommitted: args.IncludeUncommitted,
+		Submodules:         true,
+		Pipeline:           parent.PipelinePath(),
+	}, nil
+}
+
+type hostTunnelArgs struct {
+	Service core.ServiceID
+	Ports   []core.PortForward
+}
+
+func (s *hostSchema) tunnel(ctx *router.Context, parent any, args hostTunnelArgs) ([]string, error) {
+	if !s.servicesEnabled {
+		return nil, ErrServicesDisabled
+	}
+
+	svc, err := args.Service.ToService()
+	if err != nil {
+		return nil, err
+	}
+
+	return s.host.Tunnel(ctx, s.sessionCtx, s.gw, s.services, svc, args.Ports)
+}
+
+type hostServiceArgs struct {
+	Ports []core.PortForward
+}
+
+func (s *hostSchema) service(ctx *router.Context, parent *core.Query, args hostServiceArgs) (*core.Container, error) {
+	if !s.servicesEnabled {
+		return nil, ErrServicesDisabled
+	}
+
+	return s.host.Service(ctx, s.gw, parent.PipelinePath(), s.platform, args.Ports)
+}
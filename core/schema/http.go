@@ -1,6 +1,9 @@
 package schema
 
 import (
+	"fmt"
+	"io/fs"
+
 	"github.com/dagger/dagger/core"
 	"github.com/dagger/dagger/router"
 	"github.com/moby/buildkit/client/llb"
@@ -35,23 +38,61 @@ func (s *httpSchema) Dependencies() []router.ExecutableSchema {
 
 type httpArgs struct {
 	URL                     string            `json:"url"`
+	Checksum                string            `json:"checksum"`
+	Headers                 []core.HTTPHeader `json:"headers"`
+	AuthHeader              core.SecretID     `json:"authHeader"`
+	Name                    string            `json:"name"`
+	Permissions             fs.FileMode       `json:"permissions"`
 	ExperimentalServiceHost *core.ContainerID `json:"experimentalServiceHost"`
 }
 
 func (s *httpSchema) http(ctx *router.Context, parent *core.Query, args httpArgs) (*core.File, error) {
 	pipeline := parent.PipelinePath()
 
-	// Use a filename that is set to the URL. Buildkit internally stores some cache metadata of etags
-	// and http checksums using an id based on this name, so setting it to the URL maximizes our chances
-	// of following more optimized cache codepaths.
+	var checksum digest.Digest
+	if args.Checksum != "" {
+		var err error
+		checksum, err = digest.Parse(args.Checksum)
+		if err != nil {
+			return nil, fmt.Errorf("invalid checksum %q: %w", args.Checksum, err)
+		}
+	}
+
+	// Unless a name is given, name the file after the URL. Buildkit internally
+	// stores some cache metadata of etags and http checksums using an id based
+	// on the filename, so deriving it from the URL maximizes our chances of
+	// following more optimized cache codepaths.
 	// Do a hash encode to prevent conflicts with use of `/` in the URL while also not hitting max filename limits
 	filename := digest.FromString(args.URL).Encoded()
-	st := llb.HTTP(args.URL, llb.Filename(filename), pipeline.LLBOpt())
+	if args.Name != "" {
+		filename = args.Name
+	}
 
 	svcs := core.ServiceBindings{}
 	if args.ExperimentalServiceHost != nil {
 		svcs[*args.ExperimentalServiceHost] = nil
 	}
 
-	return core.NewFile(ctx, st, filename, pipeline, s.platform, svcs)
+	if len(args.Headers) == 0 && args.AuthHeader == "" {
+		opts := []llb.HTTPOption{llb.Filename(filename), pipeline.LLBOpt()}
+		if checksum != "" {
+			opts = append(opts, llb.Checksum(checksum))
+		}
+		if args.Permissions != 0 {
+			opts = append(opts, llb.Chmod(args.Permissions))
+		}
+
+		st := llb.HTTP(args.URL, opts...)
+
+		return core.NewFile(ctx, st, filename, pipeline, s.platform, svcs)
+	}
+
+	// buildkit's HTTP source can't send headers, so have the shim fetch the
+	// file inside the engine instead
+	perm := args.Permissions
+	if perm == 0 {
+		perm = 0o600
+	}
+
+	return core.FetchHTTP(ctx, args.URL, filename, perm, checksum, args.Headers, args.AuthHeader, pipeline, s.platform, svcs)
 }
@@ -0,0 +1,68 @@
+package schema
+
+import (
+	"sort"
+
+	"github.com/dagger/dagger/core"
+	"github.com/dagger/dagger/router"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+var _ router.ExecutableSchema = &imageSchema{}
+
+type imageSchema struct {
+	*baseSchema
+}
+
+func (s *imageSchema) Name() string {
+	return "image"
+}
+
+func (s *imageSchema) Schema() string {
+	return Image
+}
+
+func (s *imageSchema) Resolvers() router.Resolvers {
+	return router.Resolvers{
+		"Query": router.ObjectResolver{
+			"image": router.ToResolver(s.image),
+		},
+		"RegistryImage": router.ObjectResolver{
+			"labels": router.ToResolver(s.labels),
+		},
+	}
+}
+
+func (s *imageSchema) Dependencies() []router.ExecutableSchema {
+	return nil
+}
+
+type imageArgs struct {
+	Address  string
+	Platform *specs.Platform
+}
+
+func (s *imageSchema) image(ctx *router.Context, parent *core.Query, args imageArgs) (*core.RegistryImage, error) {
+	platform := s.platform
+	if args.Platform != nil {
+		platform = *args.Platform
+	}
+
+	return core.InspectImage(ctx, s.gw, args.Address, platform, s.auth, parent.RegistryMirrors(), s.secrets)
+}
+
+func (s *imageSchema) labels(ctx *router.Context, parent *core.RegistryImage, args any) ([]Label, error) {
+	labels := make([]Label, 0, len(parent.Labels))
+	for name, value := range parent.Labels {
+		labels = append(labels, Label{
+			Name:  name,
+			Value: value,
+		})
+	}
+
+	sort.Slice(labels, func(i, j int) bool {
+		return labels[i].Name < labels[j].Name
+	})
+
+	return labels, nil
+}
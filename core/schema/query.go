@@ -23,7 +23,8 @@ func (s *querySchema) Schema() string {
 func (s *querySchema) Resolvers() router.Resolvers {
 	return router.Resolvers{
 		"Query": router.ObjectResolver{
-			"pipeline": router.ToResolver(s.pipeline),
+			"pipeline":           router.ToResolver(s.pipeline),
+			"withRegistryMirror": router.ToResolver(s.withRegistryMirror),
 		},
 	}
 }
@@ -49,3 +50,24 @@ func (s *querySchema) pipeline(ctx *router.Context, parent *core.Query, args pip
 	})
 	return parent, nil
 }
+
+type withRegistryMirrorArgs struct {
+	Registry string
+	Mirror   string
+}
+
+func (s *querySchema) withRegistryMirror(ctx *router.Context, parent *core.Query, args withRegistryMirrorArgs) (*core.Query, error) {
+	query := &core.Query{}
+	if parent != nil {
+		query.Context = parent.Context
+	}
+
+	mirrors, err := query.Context.RegistryMirrors.With(args.Registry, args.Mirror)
+	if err != nil {
+		return nil, err
+	}
+
+	query.Context.RegistryMirrors = mirrors
+
+	return query, nil
+}
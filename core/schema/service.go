@@ -0,0 +1,92 @@
+package schema
+
+import (
+	"github.com/dagger/dagger/core"
+	"github.com/dagger/dagger/router"
+)
+
+type serviceSchema struct {
+	*baseSchema
+}
+
+var _ router.ExecutableSchema = &serviceSchema{}
+
+func (s *serviceSchema) Name() string {
+	return "service"
+}
+
+func (s *serviceSchema) Schema() string {
+	return Service
+}
+
+var serviceIDResolver = stringResolver(core.ServiceID(""))
+
+func (s *serviceSchema) Resolvers() router.Resolvers {
+	return router.Resolvers{
+		"ServiceID": serviceIDResolver,
+		"Query": router.ObjectResolver{
+			"service": router.ToResolver(s.service),
+		},
+		"Service": router.ObjectResolver{
+			"id":        router.ToResolver(s.id),
+			"hostname":  router.ToResolver(s.hostname),
+			"container": router.ToResolver(s.container),
+			"start":     router.ToResolver(s.start),
+			"stop":      router.ToResolver(s.stop),
+			"status":    router.ToResolver(s.status),
+			"logs":      router.ToResolver(s.logs),
+		},
+	}
+}
+
+func (s *serviceSchema) Dependencies() []router.ExecutableSchema {
+	return nil
+}
+
+type serviceArgs struct {
+	ID core.ServiceID
+}
+
+func (s *serviceSchema) service(ctx *router.Context, parent any, args serviceArgs) (*core.Service, error) {
+	if !s.servicesEnabled {
+		return nil, ErrServicesDisabled
+	}
+
+	return args.ID.ToService()
+}
+
+func (s *serviceSchema) id(ctx *router.Context, parent *core.Service, args any) (core.ServiceID, error) {
+	return parent.ID()
+}
+
+func (s *serviceSchema) hostname(ctx *router.Context, parent *core.Service, args any) (string, error) {
+	return parent.Hostname(), nil
+}
+
+func (s *serviceSchema) container(ctx *router.Context, parent *core.Service, args any) (*core.Container, error) {
+	return parent.Container, nil
+}
+
+func (s *serviceSchema) start(ctx *router.Context, parent *core.Service, args any) (core.ServiceID, error) {
+	if err := s.services.Start(ctx, s.gw, parent); err != nil {
+		return "", err
+	}
+
+	return parent.ID()
+}
+
+func (s *serviceSchema) stop(ctx *router.Context, parent *core.Service, args any) (core.ServiceID, error) {
+	if err := s.services.Stop(ctx, parent); err != nil {
+		return "", err
+	}
+
+	return parent.ID()
+}
+
+func (s *serviceSchema) status(ctx *router.Context, parent *core.Service, args any) (core.ServiceStatus, error) {
+	return s.services.Status(parent), nil
+}
+
+func (s *serviceSchema) logs(ctx *router.Context, parent *core.Service, args any) (string, error) {
+	return s.services.Logs(parent), nil
+}
@@ -1,23 +1,95 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/moby/buildkit/client/llb"
 	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	gwpb "github.com/moby/buildkit/frontend/gateway/pb"
+	"github.com/moby/buildkit/solver/errdefs"
 	"github.com/moby/buildkit/solver/pb"
 	"golang.org/x/sync/errgroup"
 )
 
-type Service struct {
+// RunningService is a service container started by a client, running until
+// it exits or the client detaches from it.
+type RunningService struct {
 	Container *Container
 	Detach    func()
+
+	// Exited receives the result of the service once it exits.
+	Exited <-chan error
+}
+
+// serviceErrorLogLines is how many lines of a service's output are included
+// in the error returned when it fails to start.
+const serviceErrorLogLines = 50
+
+// ServiceError is returned when a service fails to start, along with what it
+// logged and how it exited, so that the failure can be diagnosed from the
+// error alone.
+type ServiceError struct {
+	Err error
+
+	// ExitCode of the service, or -1 if it didn't exit on its own.
+	ExitCode int
+
+	// Logs are the last lines the service logged, with stdout and stderr
+	// interleaved.
+	Logs []string
+}
+
+func (err *ServiceError) Error() string {
+	if len(err.Logs) == 0 {
+		return err.Err.Error()
+	}
+
+	return fmt.Sprintf("%s\nLast %d lines of service output:\n%s", err.Err, len(err.Logs), strings.Join(err.Logs, "\n"))
+}
+
+func (err *ServiceError) Unwrap() error {
+	return err.Err
+}
+
+// serviceExitedError returns the error for a service that exited before its
+// health check passed, with the given result.
+func serviceExitedError(err error, logs []string) *ServiceError {
+	if err == nil {
+		return &ServiceError{
+			Err:  errors.New("service exited before healthcheck"),
+			Logs: logs,
+		}
+	}
+
+	var exitErr *gwpb.ExitError
+	if !errors.As(err, &exitErr) {
+		return &ServiceError{
+			Err:      fmt.Errorf("exited: %w", err),
+			ExitCode: -1,
+			Logs:     logs,
+		}
+	}
+
+	var solveErr *errdefs.SolveError
+	if len(logs) > 0 && errors.As(err, &solveErr) {
+		// the logs supersede the output the gateway attaches to the error
+		err = solveErr
+	}
+
+	return &ServiceError{
+		Err:      fmt.Errorf("exited with code %d: %w", exitErr.ExitCode, err),
+		ExitCode: int(exitErr.ExitCode),
+		Logs:     logs,
+	}
 }
 
 type ServiceBindings map[ContainerID]AliasSet
@@ -89,7 +161,7 @@ func WithServices[T any](ctx context.Context, gw bkgw.Client, svcs ServiceBindin
 
 	// NB: don't use errgroup.WithCancel; we don't want to cancel on Wait
 	eg := new(errgroup.Group)
-	started := make(chan *Service, len(svcs))
+	started := make(chan *RunningService, len(svcs))
 
 	for svcID, aliases := range svcs {
 		svc, err := svcID.ToContainer()
@@ -139,13 +211,17 @@ type portHealthChecker struct {
 	gw    bkgw.Client
 	host  string
 	ports []ContainerPort
+
+	// whether to wait for the outcome of the container's healthcheck too
+	healthchecked bool
 }
 
-func newHealth(gw bkgw.Client, host string, ports []ContainerPort) *portHealthChecker {
+func newHealth(gw bkgw.Client, host string, ports []ContainerPort, healthchecked bool) *portHealthChecker {
 	return &portHealthChecker{
-		gw:    gw,
-		host:  host,
-		ports: ports,
+		gw:            gw,
+		host:          host,
+		ports:         ports,
+		healthchecked: healthchecked,
 	}
 }
 
@@ -194,18 +270,30 @@ func (d *portHealthChecker) Check(ctx context.Context) error {
 		args = append(args, fmt.Sprintf("%d/%s", port.Port, port.Protocol.Network()))
 	}
 
+	if d.healthchecked {
+		// checked last, once the service is listening
+		args = append(args, fmt.Sprintf("%d/health", HealthcheckPort))
+	}
+
 	var debugW io.WriteCloser
 	if debugHealthchecks {
 		debugW = os.Stderr
 	}
 
+	// keep the reason the check failed, which it writes last
+	stderr := new(bytes.Buffer)
+	stderrW := io.Writer(stderr)
+	if debugW != nil {
+		stderrW = io.MultiWriter(stderr, debugW)
+	}
+
 	proc, err := container.Start(ctx, bkgw.StartRequest{
 		Args: args,
 		Env:  []string{"_DAGGER_INTERNAL_COMMAND="},
 		// FIXME(vito): it would be great to send these to the progress stream
 		// somehow instead
 		Stdout: debugW,
-		Stderr: debugW,
+		Stderr: nopWriteCloser{stderrW},
 	})
 	if err != nil {
 		return err
@@ -219,6 +307,10 @@ func (d *portHealthChecker) Check(ctx context.Context) error {
 	select {
 	case err := <-exited:
 		if err != nil {
+			lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+			if reason := lines[len(lines)-1]; reason != "" {
+				return fmt.Errorf("%w: %s", err, reason)
+			}
 			return err
 		}
 
@@ -234,3 +326,254 @@ func (d *portHealthChecker) Check(ctx context.Context) error {
 		return ctx.Err()
 	}
 }
+
+// ServiceStatus is the state of a service in its explicit lifecycle.
+type ServiceStatus string
+
+const (
+	ServiceStopped  ServiceStatus = "SERVICE_STOPPED"
+	ServiceStarting ServiceStatus = "SERVICE_STARTING"
+	ServiceRunning  ServiceStatus = "SERVICE_RUNNING"
+	ServiceExited   ServiceStatus = "SERVICE_EXITED"
+)
+
+// Service is a container run as a service whose lifecycle is controlled
+// explicitly, rather than by the containers bound to it.
+type Service struct {
+	Container *Container `json:"container"`
+}
+
+// ServiceID is an opaque value representing a service.
+type ServiceID string
+
+func (id ServiceID) String() string {
+	return string(id)
+}
+
+func (id ServiceID) ToService() (*Service, error) {
+	var service Service
+	if err := decodeID(&service, id); err != nil {
+		return nil, err
+	}
+
+	return &service, nil
+}
+
+func NewService(container *Container) (*Service, error) {
+	if container.Hostname == "" {
+		return nil, ErrContainerNoExec
+	}
+
+	return &Service{
+		Container: container,
+	}, nil
+}
+
+func (svc *Service) ID() (ServiceID, error) {
+	return encodeID[ServiceID](svc)
+}
+
+func (svc *Service) Hostname() string {
+	return svc.Container.Hostname
+}
+
+// Services keeps track of the services started explicitly in a session,
+// keeping each of them running until it's stopped or the session ends.
+//
+// Containers bound to a running service share it rather than starting their
+// own, since identical services have the same hostname and are deduplicated
+// by the solver.
+type Services struct {
+	outputs *ExecOutputs
+
+	mu      sync.Mutex
+	started map[string]*startedService
+}
+
+func NewServices(outputs *ExecOutputs) *Services {
+	return &Services{
+		outputs: outputs,
+		started: map[string]*startedService{},
+	}
+}
+
+// startedService is a service started explicitly, along with the tail of
+// what it has logged since.
+type startedService struct {
+	mu       sync.Mutex
+	status   ServiceStatus
+	logs     *logTail
+	running  *RunningService
+	startErr error
+
+	// ready is closed once the service has started or failed to.
+	ready chan struct{}
+
+	// exited is closed once the service is no longer running.
+	exited chan struct{}
+}
+
+// Start starts the service and waits for its health check to pass, unless
+// it's already running.
+func (services *Services) Start(ctx context.Context, gw bkgw.Client, svc *Service) error {
+	services.mu.Lock()
+	started, found := services.started[svc.Hostname()]
+	if found {
+		select {
+		case <-started.exited:
+			// start it again
+			found = false
+		default:
+		}
+	}
+	if !found {
+		started = &startedService{
+			status: ServiceStarting,
+			ready:  make(chan struct{}),
+			exited: make(chan struct{}),
+		}
+		services.started[svc.Hostname()] = started
+	}
+	services.mu.Unlock()
+
+	if !found {
+		started.start(ctx, gw, svc.Container, services.outputs)
+	}
+
+	select {
+	case <-started.ready:
+		return started.startErr
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stop stops the service, if it's running, waits for it to exit, and forgets
+// it.
+func (services *Services) Stop(ctx context.Context, svc *Service) error {
+	services.mu.Lock()
+	started, found := services.started[svc.Hostname()]
+	services.mu.Unlock()
+
+	if !found {
+		return nil
+	}
+
+	select {
+	case <-started.ready:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	started.mu.Lock()
+	running := started.running
+	if running != nil {
+		started.status = ServiceStopped
+	}
+	started.mu.Unlock()
+
+	if running != nil {
+		running.Detach()
+	}
+
+	select {
+	case <-started.exited:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	services.mu.Lock()
+	if services.started[svc.Hostname()] == started {
+		delete(services.started, svc.Hostname())
+	}
+	services.mu.Unlock()
+
+	return nil
+}
+
+// Status returns the state of the service.
+func (services *Services) Status(svc *Service) ServiceStatus {
+	services.mu.Lock()
+	started, found := services.started[svc.Hostname()]
+	services.mu.Unlock()
+
+	if !found {
+		return ServiceStopped
+	}
+
+	started.mu.Lock()
+	defer started.mu.Unlock()
+
+	return started.status
+}
+
+// Logs returns the tail of the output the service has logged since it was last
+// started, with stdout and stderr interleaved.
+func (services *Services) Logs(svc *Service) string {
+	services.mu.Lock()
+	started, found := services.started[svc.Hostname()]
+	services.mu.Unlock()
+
+	if !found {
+		return ""
+	}
+
+	started.mu.Lock()
+	defer started.mu.Unlock()
+
+	return started.logs.String()
+}
+
+func (started *startedService) start(ctx context.Context, gw bkgw.Client, container *Container, outputs *ExecOutputs) {
+	defer close(started.ready)
+
+	// follow the exec that runs the healthcheck, which is the one started
+	container, err := container.withHealthcheckAttached()
+	if err != nil {
+		started.mu.Lock()
+		started.status = ServiceExited
+		started.startErr = err
+		started.mu.Unlock()
+
+		close(started.exited)
+		return
+	}
+
+	logs := tailOutput(container, outputs)
+
+	started.mu.Lock()
+	started.logs = logs
+	started.mu.Unlock()
+
+	running, err := container.Start(ctx, gw)
+	if err != nil {
+		logs.Stop()
+
+		started.mu.Lock()
+		started.status = ServiceExited
+		started.startErr = err
+		started.mu.Unlock()
+
+		close(started.exited)
+		return
+	}
+
+	started.mu.Lock()
+	started.status = ServiceRunning
+	started.running = running
+	started.mu.Unlock()
+
+	go func() {
+		<-running.Exited
+
+		logs.Stop()
+
+		started.mu.Lock()
+		if started.status == ServiceRunning {
+			started.status = ServiceExited
+		}
+		started.mu.Unlock()
+
+		close(started.exited)
+	}()
+}
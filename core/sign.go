@@ -0,0 +1,312 @@
+package core
+
+import (
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"path"
+
+	"github.com/dagger/dagger/auth"
+	"github.com/dagger/dagger/core/pipeline"
+	"github.com/google/go-containerregistry/pkg/name"
+	bkclient "github.com/moby/buildkit/client"
+	"github.com/moby/buildkit/client/llb"
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/opencontainers/go-digest"
+	specsgo "github.com/opencontainers/image-spec/specs-go"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+// ImageSigningKey is a private key used to sign a published image.
+//
+// The key is PEM encoded, either as a plain PKCS #8, EC or RSA private key,
+// or encrypted with a password like the keys generated by cosign.
+type ImageSigningKey struct {
+	Key      []byte
+	Password []byte
+}
+
+const (
+	cosignSignatureType       = "cosign container image signature"
+	cosignPayloadMediaType    = "application/vnd.dev.cosign.simplesigning.v1+json"
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+)
+
+// cosignPayload is the simple signing payload cosign signs and verifies.
+type cosignPayload struct {
+	Critical struct {
+		Identity struct {
+			DockerReference string `json:"docker-reference"`
+		} `json:"identity"`
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+		Type string `json:"type"`
+	} `json:"critical"`
+	Optional map[string]any `json:"optional"`
+}
+
+// signImage pushes a cosign signature of the image with the given digest to
+// the repository of each ref, returning the signature refs.
+//
+// The signatures are pushed from a container so that they reach the same
+// registries, services included, as the image itself.
+func signImage(
+	ctx context.Context,
+	refs []string,
+	dig digest.Digest,
+	key ImageSigningKey,
+	pipeline pipeline.Path,
+	registryAuth *auth.RegistryAuthProvider,
+	secrets SecretStore,
+	bkClient *bkclient.Client,
+	solveOpts bkclient.SolveOpt,
+	solveCh chan<- *bkclient.SolveStatus,
+) ([]string, error) {
+	signer, err := loadSigningKey(key.Key, key.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	ch, wg := mirrorCh(solveCh)
+	defer wg.Wait()
+
+	sigRefs := []string{}
+	_, err = bkClient.Build(ctx, solveOpts, "", func(ctx context.Context, gw bkgw.Client) (*bkgw.Result, error) {
+		seen := map[string]bool{}
+		for _, ref := range refs {
+			parsed, err := name.ParseReference(ref)
+			if err != nil {
+				return nil, err
+			}
+
+			repo := parsed.Context()
+			if seen[repo.Name()] {
+				continue
+			}
+			seen[repo.Name()] = true
+
+			payload := cosignPayload{}
+			payload.Critical.Identity.DockerReference = repo.Name()
+			payload.Critical.Image.DockerManifestDigest = dig.String()
+			payload.Critical.Type = cosignSignatureType
+
+			payloadJSON, err := json.Marshal(payload)
+			if err != nil {
+				return nil, err
+			}
+
+			sig, err := signPayload(signer, payloadJSON)
+			if err != nil {
+				return nil, err
+			}
+
+			layout, err := signatureLayout(payloadJSON, sig, pipeline)
+			if err != nil {
+				return nil, err
+			}
+
+			sigRef := repo.Tag(fmt.Sprintf("%s-%s.sig", dig.Algorithm(), dig.Encoded())).String()
+
+			mounts, err := registryAuthMounts(ctx, registryAuth, secrets, repo.RegistryStr())
+			if err != nil {
+				return nil, err
+			}
+
+			err = runInternalCommand(ctx, gw, []string{"push", internalMountPath, sigRef}, nil, &layout, nil, mounts...)
+			if err != nil {
+				return nil, fmt.Errorf("push signature %s: %w", sigRef, err)
+			}
+
+			sigRefs = append(sigRefs, sigRef)
+		}
+
+		return bkgw.NewResult(), nil
+	}, ch)
+	if err != nil {
+		return nil, err
+	}
+
+	return sigRefs, nil
+}
+
+// signatureLayout returns an OCI image layout holding a cosign signature
+// artifact for the payload.
+func signatureLayout(payload, sig []byte, pipeline pipeline.Path) (llb.State, error) {
+	payloadDigest := digest.FromBytes(payload)
+
+	config, err := json.Marshal(specs.Image{
+		RootFS: specs.RootFS{
+			Type:    "layers",
+			DiffIDs: []digest.Digest{payloadDigest},
+		},
+	})
+	if err != nil {
+		return llb.State{}, err
+	}
+	configDigest := digest.FromBytes(config)
+
+	manifest, err := json.Marshal(specs.Manifest{
+		Versioned: specsgo.Versioned{SchemaVersion: 2},
+		MediaType: specs.MediaTypeImageManifest,
+		Config: specs.Descriptor{
+			MediaType: specs.MediaTypeImageConfig,
+			Digest:    configDigest,
+			Size:      int64(len(config)),
+		},
+		Layers: []specs.Descriptor{
+			{
+				MediaType: cosignPayloadMediaType,
+				Digest:    payloadDigest,
+				Size:      int64(len(payload)),
+				Annotations: map[string]string{
+					cosignSignatureAnnotation: base64.StdEncoding.EncodeToString(sig),
+				},
+			},
+		},
+	})
+	if err != nil {
+		return llb.State{}, err
+	}
+	manifestDigest := digest.FromBytes(manifest)
+
+	index, err := json.Marshal(specs.Index{
+		Versioned: specsgo.Versioned{SchemaVersion: 2},
+		MediaType: specs.MediaTypeImageIndex,
+		Manifests: []specs.Descriptor{
+			{
+				MediaType: specs.MediaTypeImageManifest,
+				Digest:    manifestDigest,
+				Size:      int64(len(manifest)),
+			},
+		},
+	})
+	if err != nil {
+		return llb.State{}, err
+	}
+
+	blob := func(dig digest.Digest) string {
+		return path.Join("blobs", dig.Algorithm().String(), dig.Encoded())
+	}
+
+	return llb.Scratch().File(
+		llb.Mkdir(path.Join("blobs", digest.SHA256.String()), 0o755, llb.WithParents(true)).
+			Mkfile(specs.ImageLayoutFile, 0o644, []byte(`{"imageLayoutVersion":"`+specs.ImageLayoutVersion+`"}`)).
+			Mkfile("index.json", 0o644, index).
+			Mkfile(blob(manifestDigest), 0o644, manifest).
+			Mkfile(blob(configDigest), 0o644, config).
+			Mkfile(blob(payloadDigest), 0o644, payload),
+		pipeline.LLBOpt(),
+		llb.WithCustomName("creating image signature"),
+	), nil
+}
+
+// loadSigningKey parses a PEM encoded private key.
+func loadSigningKey(key, password []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(key)
+	if block == nil {
+		return nil, errors.New("signing key is not PEM encoded")
+	}
+
+	var (
+		parsed any
+		err    error
+	)
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		parsed, err = x509.ParseECPrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "ENCRYPTED COSIGN PRIVATE KEY", "ENCRYPTED SIGSTORE PRIVATE KEY":
+		var der []byte
+		der, err = decryptCosignKey(block.Bytes, password)
+		if err != nil {
+			return nil, err
+		}
+		parsed, err = x509.ParsePKCS8PrivateKey(der)
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %q", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parse signing key: %w", err)
+	}
+
+	signer, ok := parsed.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported signing key %T", parsed)
+	}
+
+	return signer, nil
+}
+
+// decryptCosignKey decrypts a key encrypted by cosign, which derives a
+// secretbox key from the password with scrypt.
+func decryptCosignKey(encrypted, password []byte) ([]byte, error) {
+	var box struct {
+		KDF struct {
+			Name   string `json:"name"`
+			Params struct {
+				N int `json:"N"`
+				R int `json:"r"`
+				P int `json:"p"`
+			} `json:"params"`
+			Salt []byte `json:"salt"`
+		} `json:"kdf"`
+		Cipher struct {
+			Name  string `json:"name"`
+			Nonce []byte `json:"nonce"`
+		} `json:"cipher"`
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	if err := json.Unmarshal(encrypted, &box); err != nil {
+		return nil, fmt.Errorf("parse encrypted signing key: %w", err)
+	}
+
+	if box.KDF.Name != "scrypt" || box.Cipher.Name != "nacl/secretbox" {
+		return nil, fmt.Errorf("unsupported signing key encryption %s/%s", box.KDF.Name, box.Cipher.Name)
+	}
+
+	var nonce [24]byte
+	if len(box.Cipher.Nonce) != len(nonce) {
+		return nil, errors.New("invalid signing key nonce")
+	}
+	copy(nonce[:], box.Cipher.Nonce)
+
+	derived, err := scrypt.Key(password, box.KDF.Salt, box.KDF.Params.N, box.KDF.Params.R, box.KDF.Params.P, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	var secret [32]byte
+	copy(secret[:], derived)
+
+	der, ok := secretbox.Open(nil, box.Ciphertext, &nonce, &secret)
+	if !ok {
+		return nil, errors.New("decrypt signing key: wrong password")
+	}
+
+	return der, nil
+}
+
+// signPayload signs the payload the way cosign verifies it: ed25519 keys sign
+// it directly, others sign its SHA-256 digest.
+func signPayload(signer crypto.Signer, payload []byte) ([]byte, error) {
+	if _, ok := signer.Public().(ed25519.PublicKey); ok {
+		return signer.Sign(rand.Reader, payload, crypto.Hash(0))
+	}
+
+	sum := sha256.Sum256(payload)
+	return signer.Sign(rand.Reader, sum[:], crypto.SHA256)
+}
@@ -5,12 +5,19 @@ import (
 	"fmt"
 	"io"
 	"net"
+	"strconv"
 
+	"github.com/dagger/dagger/network"
 	"github.com/moby/buildkit/session/sshforward"
 )
 
 type Socket struct {
 	HostPath string `json:"host_path,omitempty"`
+
+	// A port on the host's loopback interface to dial instead of a Unix
+	// socket.
+	HostProtocol NetworkProtocol `json:"host_protocol,omitempty"`
+	HostPort     int             `json:"host_port,omitempty"`
 }
 
 type SocketID string
@@ -33,15 +40,44 @@ func NewHostSocket(absPath string) *Socket {
 	}
 }
 
+// NewHostPortSocket returns a socket that dials a port on the host's
+// loopback interface. Datagrams are framed with network.WriteDatagram when the
+// protocol is UDP.
+func NewHostPortSocket(protocol NetworkProtocol, port int) *Socket {
+	return &Socket{
+		HostProtocol: protocol,
+		HostPort:     port,
+	}
+}
+
 func (socket *Socket) ID() (SocketID, error) {
 	return encodeID[SocketID](socket)
 }
 
 func (socket *Socket) IsHost() bool {
-	return socket.HostPath != ""
+	return socket.HostPath != "" || socket.HostPort != 0
 }
 
 func (socket *Socket) Server() (sshforward.SSHServer, error) {
+	if socket.HostPort != 0 {
+		addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(socket.HostPort))
+
+		return &socketProxy{
+			dial: func() (io.ReadWriteCloser, error) {
+				conn, err := net.Dial(socket.HostProtocol.Network(), addr)
+				if err != nil {
+					return nil, err
+				}
+
+				if socket.HostProtocol == NetworkProtocolUDP {
+					return network.DatagramStream(conn), nil
+				}
+
+				return conn, nil
+			},
+		}, nil
+	}
+
 	return &socketProxy{
 		dial: func() (io.ReadWriteCloser, error) {
 			return net.Dial("unix", socket.HostPath)
@@ -0,0 +1,263 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	gwpb "github.com/moby/buildkit/frontend/gateway/pb"
+	"github.com/opencontainers/go-digest"
+)
+
+// Terminal is an interactive session attached to a container, served over a
+// websocket.
+type Terminal struct {
+	Container *Container `json:"container"`
+	Args      []string   `json:"args"`
+}
+
+// defaultTerminalArgs is the command run when no args are given.
+var defaultTerminalArgs = []string{"sh"}
+
+func NewTerminal(container *Container, args []string) (*Terminal, error) {
+	if container.FS == nil {
+		return nil, fmt.Errorf("cannot open a terminal in an empty container")
+	}
+
+	if len(args) == 0 {
+		args = defaultTerminalArgs
+	}
+
+	return &Terminal{
+		Container: container,
+		Args:      args,
+	}, nil
+}
+
+// TerminalID is an opaque value representing a terminal session.
+type TerminalID string
+
+// ID marshals the terminal into an opaque ID.
+func (term *Terminal) ID() (TerminalID, error) {
+	return encodeID[TerminalID](term)
+}
+
+// WebsocketEndpoint returns the session-relative path the terminal is served
+// on.
+func (term *Terminal) WebsocketEndpoint() (string, error) {
+	id, err := term.ID()
+	if err != nil {
+		return "", err
+	}
+
+	return "/terminals/" + digest.FromString(string(id)).Encoded(), nil
+}
+
+// Terminal websocket protocol:
+//
+//   - binary messages from the client are written to the process's stdin
+//   - text messages from the client of the form "resize;<cols>;<rows>"
+//     resize the TTY
+//   - binary messages from the server carry the process's output
+//   - the server closes the connection once the process exits, with a close
+//     reason of "exit <code>"
+const terminalResizePrefix = "resize;"
+
+var terminalUpgrader = websocket.Upgrader{}
+
+// Handler returns an HTTP handler that starts the terminal process for each
+// websocket connection.
+func (term *Terminal) Handler(gw bkgw.Client) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := terminalUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			// Upgrade has already replied with an HTTP error
+			return
+		}
+		defer conn.Close()
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		stdinR, stdinW := io.Pipe()
+		resize := make(chan bkgw.WinSize, 1)
+
+		go func() {
+			defer cancel()
+			for {
+				msgType, msg, err := conn.ReadMessage()
+				if err != nil {
+					stdinW.CloseWithError(err)
+					return
+				}
+
+				switch msgType {
+				case websocket.BinaryMessage:
+					if _, err := stdinW.Write(msg); err != nil {
+						return
+					}
+				case websocket.TextMessage:
+					size, ok := parseTerminalResize(string(msg))
+					if !ok {
+						continue
+					}
+
+					select {
+					case resize <- size:
+					case <-ctx.Done():
+						return
+					}
+				}
+			}
+		}()
+
+		stdout := &websocketWriter{conn: conn}
+
+		exitCode := 0
+		err = term.Container.Attach(ctx, gw, term.Args, stdinR, stdout, resize)
+		if err != nil {
+			var exitErr *gwpb.ExitError
+			if !errors.As(err, &exitErr) {
+				stdout.close(websocket.CloseInternalServerErr, err.Error())
+				return
+			}
+
+			exitCode = int(exitErr.ExitCode)
+		}
+
+		stdout.close(websocket.CloseNormalClosure, fmt.Sprintf("exit %d", exitCode))
+	})
+}
+
+// Attach runs args in a new process with a TTY allocated, using the
+// container's rootfs, mounts and image config. It streams the process's input
+// and output until it exits.
+func (container *Container) Attach(
+	ctx context.Context,
+	gw bkgw.Client,
+	args []string,
+	stdin io.ReadCloser,
+	stdout io.WriteCloser,
+	resize <-chan bkgw.WinSize,
+) error {
+	_, err := WithServices(ctx, gw, container.Services, func() (any, error) {
+		ctr, err := container.gatewayContainer(ctx, gw)
+		if err != nil {
+			return nil, err
+		}
+
+		// NB: use a different ctx than the one that'll be interrupted for anything
+		// that needs to run as part of post-interruption cleanup
+		defer ctr.Release(context.Background())
+
+		env := []string{}
+		for _, e := range container.Config.Env {
+			name, _, _ := strings.Cut(e, "=")
+			if name == "_DAGGER_ENABLE_NESTING" || name == DebugFailedExecEnv {
+				continue
+			}
+
+			env = append(env, e)
+		}
+
+		proc, err := ctr.Start(ctx, bkgw.StartRequest{
+			Args:   args,
+			Env:    env,
+			User:   container.Config.User,
+			Cwd:    container.Config.WorkingDir,
+			Tty:    true,
+			Stdin:  stdin,
+			Stdout: stdout,
+			Stderr: stdout,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		go func() {
+			for {
+				select {
+				case size := <-resize:
+					// best-effort; the process may have already exited
+					_ = proc.Resize(ctx, size)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+
+		return nil, proc.Wait()
+	})
+	return err
+}
+
+func parseTerminalResize(msg string) (bkgw.WinSize, bool) {
+	if !strings.HasPrefix(msg, terminalResizePrefix) {
+		return bkgw.WinSize{}, false
+	}
+
+	colsStr, rowsStr, ok := strings.Cut(strings.TrimPrefix(msg, terminalResizePrefix), ";")
+	if !ok {
+		return bkgw.WinSize{}, false
+	}
+
+	cols, err := strconv.ParseUint(colsStr, 10, 32)
+	if err != nil {
+		return bkgw.WinSize{}, false
+	}
+
+	rows, err := strconv.ParseUint(rowsStr, 10, 32)
+	if err != nil {
+		return bkgw.WinSize{}, false
+	}
+
+	return bkgw.WinSize{
+		Cols: uint32(cols),
+		Rows: uint32(rows),
+	}, true
+}
+
+// websocketWriter sends everything written to it as binary messages.
+type websocketWriter struct {
+	conn *websocket.Conn
+	mu   sync.Mutex
+}
+
+func (w *websocketWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if err := w.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close is a no-op; the connection is closed once the process exits so that
+// the exit status can be reported.
+func (w *websocketWriter) Close() error {
+	return nil
+}
+
+// maxCloseReasonBytes is the largest close reason that fits in a websocket
+// control frame.
+const maxCloseReasonBytes = 123
+
+func (w *websocketWriter) close(code int, reason string) {
+	if len(reason) > maxCloseReasonBytes {
+		reason = reason[:maxCloseReasonBytes]
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_ = w.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason))
+}
@@ -0,0 +1,243 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strconv"
+
+	"github.com/dagger/dagger/core/pipeline"
+	"github.com/moby/buildkit/client/llb"
+	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	"github.com/moby/buildkit/solver/pb"
+	specs "github.com/opencontainers/image-spec/specs-go/v1"
+)
+
+// PortForward forwards traffic from a port on one end of a tunnel to a port
+// on the other.
+type PortForward struct {
+	Frontend int             `json:"frontend"`
+	Backend  int             `json:"backend"`
+	Protocol NetworkProtocol `json:"protocol,omitempty"`
+}
+
+// Tunnel forwards ports on the host's localhost to the service, starting the
+// service if it isn't running already.
+//
+// Each of the service's exposed ports is forwarded to the same port on the
+// host if no ports are given. A frontend port of 0 is assigned randomly.
+//
+// It returns the addresses listened on, which are served until sessionCtx is
+// canceled when the session ends.
+func (host *Host) Tunnel(ctx, sessionCtx context.Context, gw bkgw.Client, services *Services, svc *Service, ports []PortForward) ([]string, error) {
+	if host.DisableRW {
+		return nil, ErrHostRWDisabled
+	}
+
+	if len(ports) == 0 {
+		for _, port := range svc.Container.Ports {
+			ports = append(ports, PortForward{
+				Frontend: port.Port,
+				Backend:  port.Port,
+				Protocol: port.Protocol,
+			})
+		}
+	}
+
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no ports to forward: service %s has no exposed ports", svc.Hostname())
+	}
+
+	listeners := []net.Listener{}
+	closeAll := func() {
+		for _, ln := range listeners {
+			ln.Close()
+		}
+	}
+
+	for _, port := range ports {
+		if port.Protocol != "" && port.Protocol != NetworkProtocolTCP {
+			closeAll()
+			return nil, fmt.Errorf("cannot forward port %d/%s: only TCP ports can be tunneled", port.Backend, port.Protocol.Network())
+		}
+
+		ln, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(port.Frontend)))
+		if err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		listeners = append(listeners, ln)
+	}
+
+	if err := services.Start(ctx, gw, svc); err != nil {
+		closeAll()
+		return nil, err
+	}
+
+	addrs := make([]string, len(listeners))
+	for i, ln := range listeners {
+		addrs[i] = ln.Addr().String()
+
+		backend := net.JoinHostPort(svc.Hostname(), strconv.Itoa(ports[i].Backend))
+
+		// NB: serve for the rest of the session, not just this request
+		go func(ln net.Listener) {
+			if err := forward(sessionCtx, gw, ln, backend); err != nil {
+				fmt.Fprintf(os.Stderr, "tunnel to %s: %s\n", backend, err)
+			}
+		}(ln)
+	}
+
+	return addrs, nil
+}
+
+// forward accepts connections from the listener and forwards each of them to
+// the backend address, as reached from a container. It serves until ctx is
+// canceled or the listener is closed, and closes the listener when it returns.
+func forward(ctx context.Context, gw bkgw.Client, ln net.Listener, backend string) error {
+	defer ln.Close()
+
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+	}()
+
+	var ctr bkgw.Container
+	defer func() {
+		if ctr != nil {
+			ctr.Release(context.Background())
+		}
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		if ctr == nil {
+			// start the container lazily so that unused forwards are free
+			ctr, err = tunnelContainer(ctx, gw)
+			if err != nil {
+				conn.Close()
+				return fmt.Errorf("tunnel container: %w", err)
+			}
+		}
+
+		go func() {
+			defer conn.Close()
+
+			if err := tunnel(ctx, ctr, conn, backend); err != nil && debugHealthchecks {
+				fmt.Fprintf(os.Stderr, "tunnel to %s: %s\n", backend, err)
+			}
+		}()
+	}
+}
+
+// tunnelContainer creates an empty container to run tunnel processes in,
+// which can reach services by hostname.
+func tunnelContainer(ctx context.Context, gw bkgw.Client) (bkgw.Container, error) {
+	scratchRes, err := result(ctx, gw, llb.Scratch())
+	if err != nil {
+		return nil, err
+	}
+
+	return gw.NewContainer(ctx, bkgw.NewContainerRequest{
+		Mounts: []bkgw.Mount{
+			{
+				Dest:      "/",
+				MountType: pb.MountType_BIND,
+				Ref:       scratchRes.Ref,
+			},
+		},
+	})
+}
+
+// tunnel pipes the connection through a process in the container that dials
+// the backend address.
+func tunnel(ctx context.Context, ctr bkgw.Container, conn net.Conn, backend string) error {
+	var debugW io.WriteCloser
+	if debugHealthchecks {
+		debugW = os.Stderr
+	}
+
+	proc, err := ctr.Start(ctx, bkgw.StartRequest{
+		Args:  []string{"tunnel", backend},
+		Env:   []string{"_DAGGER_INTERNAL_COMMAND="},
+		Stdin: conn,
+		// leave closing the connection to whoever accepted it
+		Stdout: nopWriteCloser{conn},
+		Stderr: debugW,
+	})
+	if err != nil {
+		return err
+	}
+
+	return proc.Wait()
+}
+
+// hostSocketsDir is where a host service mounts the sockets it forwards its
+// ports to.
+const hostSocketsDir = "/run/dagger/host"
+
+// Service returns a service that forwards its ports to ports on the host's
+// localhost through the session, so that containers bound to it can reach
+// services running on the host.
+func (host *Host) Service(ctx context.Context, gw bkgw.Client, p pipeline.Path, platform specs.Platform, ports []PortForward) (*Container, error) {
+	if host.DisableRW {
+		return nil, ErrHostRWDisabled
+	}
+
+	if len(ports) == 0 {
+		return nil, fmt.Errorf("no ports to forward")
+	}
+
+	container, err := NewContainer("", p, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"proxy"}
+	for _, port := range ports {
+		if port.Frontend <= 0 {
+			return nil, fmt.Errorf("invalid frontend port %d for backend port %d", port.Frontend, port.Backend)
+		}
+
+		protocol := port.Protocol
+		if protocol == "" {
+			protocol = NetworkProtocolTCP
+		}
+
+		sockPath := path.Join(hostSocketsDir, fmt.Sprintf("%d-%s.sock", port.Frontend, protocol.Network()))
+
+		container, err = container.WithUnixSocket(ctx, gw, sockPath, NewHostPortSocket(protocol, port.Backend), "")
+		if err != nil {
+			return nil, err
+		}
+
+		desc := fmt.Sprintf("forwarded to port %d on the host", port.Backend)
+		container, err = container.WithExposedPort(ContainerPort{
+			Port:        port.Frontend,
+			Protocol:    protocol,
+			Description: &desc,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		args = append(args, fmt.Sprintf("%d/%s=%s", port.Frontend, protocol.Network(), sockPath))
+	}
+
+	return container.WithExec(ctx, gw, platform, ContainerExecOpts{
+		Args:            args,
+		internalCommand: true,
+	})
+}
@@ -1,19 +1,23 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/fs"
 	"path"
 	"strconv"
 	"strings"
 	"sync"
 
+	"github.com/dagger/dagger/auth"
 	"github.com/dagger/dagger/core/reffs"
 	"github.com/moby/buildkit/client/llb"
 	bkgw "github.com/moby/buildkit/frontend/gateway/client"
+	bkauth "github.com/moby/buildkit/session/auth"
 	"github.com/moby/buildkit/solver/pb"
 	specs "github.com/opencontainers/image-spec/specs-go/v1"
 	"github.com/opencontainers/runc/libcontainer/user"
@@ -211,3 +215,149 @@ func cloneMap[K comparable, T any](src map[K]T) map[K]T {
 	}
 	return dst
 }
+
+// runInternalCommand has the shim run one of its internal commands in a
+// scratch container, with mount, if any, mounted read-only at
+// internalMountPath, along with any extra mounts such as secrets.
+func runInternalCommand(ctx context.Context, gw bkgw.Client, args, env []string, mount *llb.State, stdout io.Writer, extra ...bkgw.Mount) error {
+	scratchRes, err := result(ctx, gw, llb.Scratch())
+	if err != nil {
+		return err
+	}
+
+	mounts := []bkgw.Mount{
+		{
+			Dest:      "/",
+			MountType: pb.MountType_BIND,
+			Ref:       scratchRes.Ref,
+		},
+	}
+
+	if mount != nil {
+		def, err := mount.Marshal(ctx)
+		if err != nil {
+			return err
+		}
+
+		mountRes, err := gw.Solve(ctx, bkgw.SolveRequest{
+			Definition: def.ToPB(),
+			Evaluate:   true,
+		})
+		if err != nil {
+			return err
+		}
+
+		mounts = append(mounts, bkgw.Mount{
+			Dest:      internalMountPath,
+			MountType: pb.MountType_BIND,
+			Ref:       mountRes.Ref,
+			Readonly:  true,
+		})
+	}
+
+	mounts = append(mounts, extra...)
+
+	container, err := gw.NewContainer(ctx, bkgw.NewContainerRequest{
+		Mounts: mounts,
+	})
+	if err != nil {
+		return err
+	}
+
+	defer container.Release(context.Background())
+
+	if stdout == nil {
+		stdout = io.Discard
+	}
+
+	stderr := new(bytes.Buffer)
+	proc, err := container.Start(ctx, bkgw.StartRequest{
+		Args:   args,
+		Env:    append([]string{"_DAGGER_INTERNAL_COMMAND="}, env...),
+		Stdout: &nopCloser{stdout},
+		Stderr: &nopCloser{stderr},
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := proc.Wait(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("%w: %s", err, msg)
+		}
+		return err
+	}
+
+	return nil
+}
+
+// secretMount mounts a secret read-only at dest in a container started
+// through the gateway.
+func secretMount(dest string, id SecretID) bkgw.Mount {
+	return bkgw.Mount{
+		Dest:      dest,
+		MountType: pb.MountType_SECRET,
+		SecretOpt: &pb.SecretOpt{
+			ID:   id.String(),
+			Mode: 0o400,
+		},
+	}
+}
+
+const (
+	// RegistryUsernamePath is where the username for a registry is mounted
+	// for the shim to read.
+	RegistryUsernamePath = "/run/dagger/registry-username"
+
+	// RegistryPasswordPath is where the password for a registry is mounted
+	// for the shim to read.
+	RegistryPasswordPath = "/run/dagger/registry-password"
+)
+
+// SecretStore holds the secrets of a session, which containers mount by ID.
+type SecretStore interface {
+	AddSecret(ctx context.Context, name, plaintext string) (SecretID, error)
+}
+
+// registryAuthMounts returns the credentials for host mounted as secrets, for
+// an internal command talking to its registry.
+func registryAuthMounts(ctx context.Context, registryAuth *auth.RegistryAuthProvider, secrets SecretStore, host string) ([]bkgw.Mount, error) {
+	// buildkit asks for Docker Hub credentials under its API host
+	if host == "docker.io" || host == "index.docker.io" {
+		host = "registry-1.docker.io"
+	}
+
+	creds, err := registryAuth.Credentials(ctx, &bkauth.CredentialsRequest{
+		Host: host,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if creds.GetUsername() == "" && creds.GetSecret() == "" {
+		return nil, nil
+	}
+
+	username, err := secrets.AddSecret(ctx, "dagger-registry-username:"+host, creds.GetUsername())
+	if err != nil {
+		return nil, err
+	}
+
+	password, err := secrets.AddSecret(ctx, "dagger-registry-password:"+host, creds.GetSecret())
+	if err != nil {
+		return nil, err
+	}
+
+	return []bkgw.Mount{
+		secretMount(RegistryUsernamePath, username),
+		secretMount(RegistryPasswordPath, password),
+	}, nil
+}
+
+// nopWriteCloser adds a no-op Close to a writer, for passing one that's
+// closed by its owner as a process's output.
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
@@ -56,6 +56,11 @@ type Config struct {
 	SessionToken  string
 	UserAgent     string
 
+	// Mirrors to pull images from for the session, as "registry=mirror"
+	// pairs. Whether a mirror is reached over plain HTTP follows the engine's
+	// registry config.
+	RegistryMirrors []string
+
 	// WARNING: this is currently exposed directly but will be removed or
 	// replaced with something incompatible in the future.
 	RawBuildkitStatus chan *bkclient.SolveStatus
@@ -111,6 +116,15 @@ func Start(ctx context.Context, startOpts *Config, fn StartCallback) error {
 	}
 
 	registryAuth := auth.NewRegistryAuthProvider(config.LoadDefaultConfigFile(os.Stderr))
+	for _, pair := range startOpts.RegistryMirrors {
+		registry, mirror, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("invalid registry mirror %q: expected registry=mirror", pair)
+		}
+		if err := registryAuth.AddMirror(registry, mirror); err != nil {
+			return err
+		}
+	}
 
 	var allowedEntitlements []entitlements.Entitlement
 	if c.PrivilegedExecEnabled {
@@ -163,8 +177,9 @@ func Start(ctx context.Context, startOpts *Config, fn StartCallback) error {
 
 	eg, groupCtx := errgroup.WithContext(ctx)
 	solveCh := make(chan *bkclient.SolveStatus)
+	execOutputs := core.NewExecOutputs()
 	eg.Go(func() error {
-		return handleSolveEvents(startOpts, solveCh)
+		return handleSolveEvents(startOpts, solveCh, execOutputs)
 	})
 
 	eg.Go(func() error {
@@ -176,14 +191,16 @@ func Start(ctx context.Context, startOpts *Config, fn StartCallback) error {
 			// Thankfully we can just yeet the gateway into the store.
 			secretStore.SetGateway(gw)
 
-			gwClient := core.NewGatewayClient(gw, cacheConfigType, cacheConfigAttrs)
+			gwClient := core.NewGatewayClient(gw, cacheConfigType, cacheConfigAttrs, execOutputs)
 			coreAPI, err := schema.New(schema.InitializeArgs{
+				SessionContext: ctx,
 				Router:         router,
 				Workdir:        startOpts.Workdir,
 				Gateway:        gwClient,
 				BKClient:       c.BuildkitClient,
 				SolveOpts:      solveOpts,
 				SolveCh:        solveCh,
+				ExecOutputs:    execOutputs,
 				Platform:       *platform,
 				DisableHostRW:  startOpts.DisableHostRW,
 				Auth:           registryAuth,
@@ -242,10 +259,18 @@ func Start(ctx context.Context, startOpts *Config, fn StartCallback) error {
 	return nil
 }
 
-func handleSolveEvents(startOpts *Config, upstreamCh chan *bkclient.SolveStatus) error {
+func handleSolveEvents(startOpts *Config, upstreamCh chan *bkclient.SolveStatus, execOutputs *core.ExecOutputs) error {
 	eg := &errgroup.Group{}
 	readers := []chan *bkclient.SolveStatus{}
 
+	// Dispatch exec output to live listeners
+	outputsCh := make(chan *bkclient.SolveStatus)
+	readers = append(readers, outputsCh)
+	eg.Go(func() error {
+		execOutputs.Consume(outputsCh)
+		return nil
+	})
+
 	// Dispatch events to raw listener
 	if startOpts.RawBuildkitStatus != nil {
 		readers = append(readers, startOpts.RawBuildkitStatus)
@@ -22,6 +22,10 @@ const (
 	CacheConfigEnvName = "_EXPERIMENTAL_DAGGER_CACHE_CONFIG"
 	ServicesDNSEnvName = "_EXPERIMENTAL_DAGGER_SERVICES_DNS"
 
+	// InsecureRegistriesEnvName is set by the engine to the comma-separated
+	// hosts of the registries it is configured to reach over plain HTTP.
+	InsecureRegistriesEnvName = "_DAGGER_INSECURE_REGISTRIES"
+
 	// trim image digests to 16 characters to makeoutput more readable
 	hashLen             = 16
 	containerNamePrefix = "dagger-engine-"
@@ -0,0 +1,83 @@
+package network
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+)
+
+// MaxDatagramSize is the largest datagram that can be carried over a stream.
+const MaxDatagramSize = 65535
+
+// WriteDatagram writes a datagram to a stream as a frame: its length as a
+// 2-byte big-endian integer, followed by the datagram itself.
+func WriteDatagram(w io.Writer, p []byte) error {
+	if len(p) > MaxDatagramSize {
+		return fmt.Errorf("datagram too large: %d bytes", len(p))
+	}
+
+	frame := make([]byte, 2+len(p))
+	binary.BigEndian.PutUint16(frame, uint16(len(p)))
+	copy(frame[2:], p)
+
+	_, err := w.Write(frame)
+	return err
+}
+
+// ReadDatagram reads a datagram framed by WriteDatagram from a stream into
+// buf, which must be able to hold MaxDatagramSize bytes.
+func ReadDatagram(r io.Reader, buf []byte) ([]byte, error) {
+	var size [2]byte
+	if _, err := io.ReadFull(r, size[:]); err != nil {
+		return nil, err
+	}
+
+	p := buf[:binary.BigEndian.Uint16(size[:])]
+	if _, err := io.ReadFull(r, p); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// DatagramStream adapts a connected packet-oriented conn, such as a UDP
+// conn, into a stream of framed datagrams that can be forwarded like any
+// other stream.
+func DatagramStream(conn net.Conn) io.ReadWriteCloser {
+	stream, frames := net.Pipe()
+
+	go func() {
+		defer conn.Close()
+
+		buf := make([]byte, MaxDatagramSize)
+		for {
+			p, err := ReadDatagram(frames, buf)
+			if err != nil {
+				return
+			}
+
+			if _, err := conn.Write(p); err != nil {
+				return
+			}
+		}
+	}()
+
+	go func() {
+		defer frames.Close()
+
+		buf := make([]byte, MaxDatagramSize)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				return
+			}
+
+			if err := WriteDatagram(frames, buf[:n]); err != nil {
+				return
+			}
+		}
+	}()
+
+	return stream
+}
@@ -0,0 +1,61 @@
+package network
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDatagramFraming(t *testing.T) {
+	stream := new(bytes.Buffer)
+
+	require.NoError(t, WriteDatagram(stream, []byte("hello")))
+	require.NoError(t, WriteDatagram(stream, []byte{}))
+	require.NoError(t, WriteDatagram(stream, []byte("world")))
+	require.Error(t, WriteDatagram(stream, make([]byte, MaxDatagramSize+1)))
+
+	buf := make([]byte, MaxDatagramSize)
+	for _, expected := range []string{"hello", "", "world"} {
+		p, err := ReadDatagram(stream, buf)
+		require.NoError(t, err)
+		require.Equal(t, expected, string(p))
+	}
+
+	_, err := ReadDatagram(stream, buf)
+	require.Error(t, err)
+}
+
+func TestDatagramStream(t *testing.T) {
+	echo, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer echo.Close()
+
+	go func() {
+		buf := make([]byte, MaxDatagramSize)
+		for {
+			n, addr, err := echo.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+
+			_, _ = echo.WriteTo(buf[:n], addr)
+		}
+	}()
+
+	conn, err := net.Dial("udp", echo.LocalAddr().String())
+	require.NoError(t, err)
+
+	stream := DatagramStream(conn)
+	defer stream.Close()
+
+	buf := make([]byte, MaxDatagramSize)
+	for _, msg := range []string{"ping", "pong"} {
+		require.NoError(t, WriteDatagram(stream, []byte(msg)))
+
+		p, err := ReadDatagram(stream, buf)
+		require.NoError(t, err)
+		require.Equal(t, msg, string(p))
+	}
+}
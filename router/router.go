@@ -25,12 +25,21 @@ type Router struct {
 	s *graphql.Schema
 	h *handler.Handler
 	l sync.RWMutex
+
+	endpoints  map[string]*endpoint
+	endpointMu sync.RWMutex
+}
+
+// endpoint is a handler registered with MuxEndpoint.
+type endpoint struct {
+	handler http.Handler
 }
 
 func New(sessionToken string) *Router {
 	r := &Router{
 		schemas:      make(map[string]ExecutableSchema),
 		sessionToken: sessionToken,
+		endpoints:    make(map[string]*endpoint),
 	}
 
 	return r
@@ -126,6 +135,31 @@ func (r *Router) Get(name string) ExecutableSchema {
 	return r.schemas[name]
 }
 
+// MuxEndpoint registers an additional HTTP handler served alongside the
+// GraphQL API, e.g. for streaming interactive sessions that don't fit into a
+// query/response model.
+//
+// The handler is subject to the same session authentication as queries.
+//
+// It returns a function that unregisters the handler, unless the path has
+// been registered again since.
+func (r *Router) MuxEndpoint(path string, handler http.Handler) func() {
+	r.endpointMu.Lock()
+	defer r.endpointMu.Unlock()
+
+	e := &endpoint{handler: handler}
+	r.endpoints[path] = e
+
+	return func() {
+		r.endpointMu.Lock()
+		defer r.endpointMu.Unlock()
+
+		if r.endpoints[path] == e {
+			delete(r.endpoints, path)
+		}
+	}
+}
+
 func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	r.l.RLock()
 	h := r.h
@@ -171,6 +205,11 @@ func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 
 	mux := http.NewServeMux()
 	mux.Handle("/query", h)
+	r.endpointMu.RLock()
+	for path, e := range r.endpoints {
+		mux.Handle(path, e.handler)
+	}
+	r.endpointMu.RUnlock()
 	mux.ServeHTTP(w, req)
 }
 
@@ -0,0 +1,98 @@
+package router
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuxEndpoint(t *testing.T) {
+	r := New("sekret")
+
+	err := r.Add(StaticSchema(StaticSchemaParams{
+		Name: "test",
+		Schema: `
+		type Query {
+			hello: String
+		}
+		`,
+		Resolvers: Resolvers{
+			"Query": ObjectResolver{
+				"hello": PassthroughResolver,
+			},
+		},
+	}))
+	require.NoError(t, err)
+
+	hello := http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("world"))
+	})
+
+	r.MuxEndpoint("/hello", hello)
+
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	t.Run("serves registered endpoints", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/hello", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("sekret", "")
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, res.StatusCode)
+		require.Equal(t, "world", string(body))
+	})
+
+	t.Run("requires the session token", func(t *testing.T) {
+		res, err := http.Get(srv.URL + "/hello")
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	})
+
+	t.Run("does not serve unregistered endpoints", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, srv.URL+"/goodbye", nil)
+		require.NoError(t, err)
+		req.SetBasicAuth("sekret", "")
+
+		res, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		defer res.Body.Close()
+
+		require.Equal(t, http.StatusNotFound, res.StatusCode)
+	})
+
+	t.Run("stops serving unregistered endpoints", func(t *testing.T) {
+		get := func() int {
+			req, err := http.NewRequest(http.MethodGet, srv.URL+"/bye", nil)
+			require.NoError(t, err)
+			req.SetBasicAuth("sekret", "")
+
+			res, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer res.Body.Close()
+
+			return res.StatusCode
+		}
+
+		unregisterA := r.MuxEndpoint("/bye", hello)
+		require.Equal(t, http.StatusOK, get())
+
+		unregisterB := r.MuxEndpoint("/bye", hello)
+
+		unregisterA()
+		require.Equal(t, http.StatusOK, get())
+
+		unregisterB()
+		require.Equal(t, http.StatusNotFound, get())
+	})
+}
@@ -29,6 +29,9 @@ type Platform string
 // A unique identifier for a secret.
 type SecretID string
 
+// A unique service identifier.
+type ServiceID string
+
 // A content-addressed socket identifier.
 type SocketID string
 
@@ -41,6 +44,34 @@ type BuildArg struct {
 	Value string `json:"value"`
 }
 
+// A header sent with an HTTP request.
+type HTTPHeader struct {
+	// The header name (e.g., "Accept").
+	Name string `json:"name"`
+
+	// The header value (e.g., "application/octet-stream").
+	Value string `json:"value"`
+}
+
+// An annotation set on a published image.
+type ImageAnnotation struct {
+	// The annotation name (e.g., "org.opencontainers.image.source").
+	Name string `json:"name"`
+
+	// The annotation value (e.g., "https://github.com/dagger/dagger").
+	Value string `json:"value"`
+}
+
+// A key used to sign a published image.
+type ImageSigningKey struct {
+	// The PEM encoded private key, either a plain PKCS #8, EC or RSA key, or a
+	// password-encrypted key generated by cosign.
+	Key *Secret `json:"key"`
+
+	// The password of an encrypted key.
+	Password *Secret `json:"password"`
+}
+
 // Key value object that represents a Pipeline label.
 type PipelineLabel struct {
 	// Label name.
@@ -50,6 +81,27 @@ type PipelineLabel struct {
 	Value string `json:"value"`
 }
 
+// A port forwarded from one end of a tunnel to the other.
+type PortForward struct {
+	// Port to forward traffic to.
+	Backend int `json:"backend"`
+
+	// Port to listen on.
+	Frontend int `json:"frontend"`
+
+	// Transport layer network protocol.
+	Protocol NetworkProtocol `json:"protocol"`
+}
+
+// A variable available to a rendered template.
+type TemplateVariable struct {
+	// The variable name (e.g., "VERSION").
+	Name string `json:"name"`
+
+	// The variable value (e.g., "1.2.3").
+	Value string `json:"value"`
+}
+
 // A directory whose contents persist across runs.
 type CacheVolume struct {
 	q *querybuilder.Selection
@@ -89,20 +141,22 @@ type Container struct {
 	q *querybuilder.Selection
 	c graphql.Client
 
-	endpoint    *string
-	envVariable *string
-	exitCode    *int
-	export      *bool
-	hostname    *string
-	id          *ContainerID
-	imageRef    *string
-	label       *string
-	platform    *Platform
-	publish     *string
-	stderr      *string
-	stdout      *string
-	user        *string
-	workdir     *string
+	endpoint     *string
+	envVariable  *string
+	exitCode     *int
+	export       *bool
+	exportToHost *bool
+	hostname     *string
+	id           *ContainerID
+	imageRef     *string
+	label        *string
+	outputStream *string
+	platform     *Platform
+	publish      *string
+	stderr       *string
+	stdout       *string
+	user         *string
+	workdir      *string
 }
 type WithContainerFunc func(r *Container) *Container
 
@@ -110,6 +164,22 @@ func (r *Container) With(f WithContainerFunc) *Container {
 	return f(r)
 }
 
+// Turns the container into a service that can be started and stopped
+// explicitly, e.g. to keep a database running across many steps of a session
+// rather than starting it for each container bound to it.
+//
+// The container's last command is run as the service.
+//
+// Currently experimental; set _EXPERIMENTAL_DAGGER_SERVICES_DNS=0 to disable.
+func (r *Container) AsService() *Service {
+	q := r.q.Select("asService")
+
+	return &Service{
+		q: q,
+		c: r.c,
+	}
+}
+
 // ContainerBuildOpts contains options for Container.Build
 type ContainerBuildOpts struct {
 	// Path to the Dockerfile to use.
@@ -348,6 +418,17 @@ func (r *Container) Exec(opts ...ContainerExecOpts) *Container {
 	}
 }
 
+// The exit code, output and timing of the last executed command.
+// Errors if no command has been executed.
+func (r *Container) ExecResult() *ExecResult {
+	q := r.q.Select("execResult")
+
+	return &ExecResult{
+		q: q,
+		c: r.c,
+	}
+}
+
 // Exit code of the last executed command. Zero means success.
 // Errors if no command has been executed.
 func (r *Container) ExitCode(ctx context.Context) (int, error) {
@@ -393,6 +474,47 @@ func (r *Container) Export(ctx context.Context, path string, opts ...ContainerEx
 	return response, q.Execute(ctx, r.c)
 }
 
+// ContainerExportToHostOpts contains options for Container.ExportToHost
+type ContainerExportToHostOpts struct {
+	// The container runtime to load the image into.
+	//
+	// Defaults to Docker.
+	Runtime ContainerRuntime
+	// Host socket the runtime listens on.
+	//
+	// Defaults to the runtime's usual socket (e.g., /var/run/docker.sock).
+	Socket *Socket
+}
+
+// Loads the container's image into a container runtime on the host, tagged
+// with the given name.
+func (r *Container) ExportToHost(ctx context.Context, tag string, opts ...ContainerExportToHostOpts) (bool, error) {
+	if r.exportToHost != nil {
+		return *r.exportToHost, nil
+	}
+	q := r.q.Select("exportToHost")
+	q = q.Arg("tag", tag)
+	// `runtime` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Runtime) {
+			q = q.Arg("runtime", opts[i].Runtime)
+			break
+		}
+	}
+	// `socket` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Socket) {
+			q = q.Arg("socket", opts[i].Socket)
+			break
+		}
+	}
+
+	var response bool
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
 // Retrieves the list of exposed ports.
 //
 // Currently experimental; set _EXPERIMENTAL_DAGGER_SERVICES_DNS=0 to disable.
@@ -603,6 +725,25 @@ func (r *Container) Mounts(ctx context.Context) ([]string, error) {
 	return response, q.Execute(ctx, r.c)
 }
 
+// Session-relative path of an HTTP endpoint streaming the output of the last
+// executed command in chunks as it's written, running it if needed.
+//
+// Both streams are written by default; pass "?stream=stdout" or
+// "?stream=stderr" to follow only one of them.
+//
+// Errors if no command has been executed.
+func (r *Container) OutputStream(ctx context.Context) (string, error) {
+	if r.outputStream != nil {
+		return *r.outputStream, nil
+	}
+	q := r.q.Select("outputStream")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
 // ContainerPipelineOpts contains options for Container.Pipeline
 type ContainerPipelineOpts struct {
 	// Pipeline description.
@@ -654,6 +795,25 @@ type ContainerPublishOpts struct {
 	// Identifiers for other platform specific containers.
 	// Used for multi-platform image.
 	PlatformVariants []*Container
+	// Additional addresses to publish the same image to in one push
+	// (e.g., ["docker.io/dagger/dagger:latest", "docker.io/dagger/dagger:v0.5.0"]).
+	Addresses []string
+	// Annotations to set on the image manifests, and on the index of a
+	// multi-platform image.
+	//
+	// Requires OCI media types.
+	Annotations []ImageAnnotation
+	// Media types used for the image manifests and layers.
+	//
+	// Default: OCI_MEDIA_TYPES.
+	MediaTypes ImageMediaTypes
+	// Compression to force on every layer, recompressing layers if needed.
+	//
+	// Layers keep their existing compression by default.
+	ForcedCompression ImageLayerCompression
+	// Key to sign the image with, pushing a cosign signature next to it
+	// (e.g., "docker.io/dagger/dagger:sha256-<digest>.sig").
+	Sign ImageSigningKey
 }
 
 // Publishes this container as a new image to the specified address.
@@ -673,6 +833,41 @@ func (r *Container) Publish(ctx context.Context, address string, opts ...Contain
 			break
 		}
 	}
+	// `addresses` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Addresses) {
+			q = q.Arg("addresses", opts[i].Addresses)
+			break
+		}
+	}
+	// `annotations` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Annotations) {
+			q = q.Arg("annotations", opts[i].Annotations)
+			break
+		}
+	}
+	// `mediaTypes` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].MediaTypes) {
+			q = q.Arg("mediaTypes", opts[i].MediaTypes)
+			break
+		}
+	}
+	// `forcedCompression` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].ForcedCompression) {
+			q = q.Arg("forcedCompression", opts[i].ForcedCompression)
+			break
+		}
+	}
+	// `sign` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Sign) {
+			q = q.Arg("sign", opts[i].Sign)
+			break
+		}
+	}
 
 	var response string
 
@@ -680,6 +875,86 @@ func (r *Container) Publish(ctx context.Context, address string, opts ...Contain
 	return response, q.Execute(ctx, r.c)
 }
 
+// ContainerPublishWithDigestsOpts contains options for Container.PublishWithDigests
+type ContainerPublishWithDigestsOpts struct {
+	// Identifiers for other platform specific containers.
+	// Used for multi-platform image.
+	PlatformVariants []*Container
+	// Additional addresses to publish the same image to in one push
+	// (e.g., ["docker.io/dagger/dagger:latest", "docker.io/dagger/dagger:v0.5.0"]).
+	Addresses []string
+	// Annotations to set on the image manifests, and on the index of a
+	// multi-platform image.
+	//
+	// Requires OCI media types.
+	Annotations []ImageAnnotation
+	// Media types used for the image manifests and layers.
+	//
+	// Default: OCI_MEDIA_TYPES.
+	MediaTypes ImageMediaTypes
+	// Compression to force on every layer, recompressing layers if needed.
+	//
+	// Layers keep their existing compression by default.
+	ForcedCompression ImageLayerCompression
+	// Key to sign the image with, pushing a cosign signature next to it
+	// (e.g., "docker.io/dagger/dagger:sha256-<digest>.sig").
+	Sign ImageSigningKey
+}
+
+// Publishes this container as a new image, like publish, and returns the
+// digests of what was pushed.
+func (r *Container) PublishWithDigests(address string, opts ...ContainerPublishWithDigestsOpts) *PublishResult {
+	q := r.q.Select("publishWithDigests")
+	q = q.Arg("address", address)
+	// `platformVariants` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].PlatformVariants) {
+			q = q.Arg("platformVariants", opts[i].PlatformVariants)
+			break
+		}
+	}
+	// `addresses` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Addresses) {
+			q = q.Arg("addresses", opts[i].Addresses)
+			break
+		}
+	}
+	// `annotations` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Annotations) {
+			q = q.Arg("annotations", opts[i].Annotations)
+			break
+		}
+	}
+	// `mediaTypes` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].MediaTypes) {
+			q = q.Arg("mediaTypes", opts[i].MediaTypes)
+			break
+		}
+	}
+	// `forcedCompression` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].ForcedCompression) {
+			q = q.Arg("forcedCompression", opts[i].ForcedCompression)
+			break
+		}
+	}
+	// `sign` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Sign) {
+			q = q.Arg("sign", opts[i].Sign)
+			break
+		}
+	}
+
+	return &PublishResult{
+		q: q,
+		c: r.c,
+	}
+}
+
 // Retrieves this container's root filesystem. Mounts are not included.
 func (r *Container) Rootfs() *Directory {
 	q := r.q.Select("rootfs")
@@ -690,6 +965,44 @@ func (r *Container) Rootfs() *Directory {
 	}
 }
 
+// ContainerSbomOpts contains options for Container.Sbom
+type ContainerSbomOpts struct {
+	// The SBOM format. Default: SPDX.
+	Format SbomFormat
+}
+
+// Generates a software bill of materials (SBOM) listing the packages
+// installed in this container's rootfs.
+//
+// Packages are read from the apk, dpkg and rpm databases and from the build
+// info of Go binaries, without network access.
+func (r *Container) Sbom(opts ...ContainerSbomOpts) *File {
+	q := r.q.Select("sbom")
+	// `format` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Format) {
+			q = q.Arg("format", opts[i].Format)
+			break
+		}
+	}
+
+	return &File{
+		q: q,
+		c: r.c,
+	}
+}
+
+// Merges the layers added since the last history entry, or all of this
+// container's layers if there is none, into a single layer.
+func (r *Container) Squash() *Container {
+	q := r.q.Select("squash")
+
+	return &Container{
+		q: q,
+		c: r.c,
+	}
+}
+
 // The error stream of the last executed command.
 // Errors if no command has been executed.
 func (r *Container) Stderr(ctx context.Context) (string, error) {
@@ -718,6 +1031,35 @@ func (r *Container) Stdout(ctx context.Context) (string, error) {
 	return response, q.Execute(ctx, r.c)
 }
 
+// ContainerTerminalOpts contains options for Container.Terminal
+type ContainerTerminalOpts struct {
+	// Command to run in the terminal (e.g., ["bash"]).
+	//
+	// Default: ["sh"].
+	Args []string
+}
+
+// Opens an interactive terminal in this container, e.g. to debug a failing
+// command by running a shell in the state leading up to it.
+//
+// The terminal is served over a websocket at the returned endpoint. A new
+// process is started for each connection.
+func (r *Container) Terminal(opts ...ContainerTerminalOpts) *Terminal {
+	q := r.q.Select("terminal")
+	// `args` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Args) {
+			q = q.Arg("args", opts[i].Args)
+			break
+		}
+	}
+
+	return &Terminal{
+		q: q,
+		c: r.c,
+	}
+}
+
 // Retrieves the user to be set for all commands.
 func (r *Container) User(ctx context.Context) (string, error) {
 	if r.user != nil {
@@ -731,6 +1073,22 @@ func (r *Container) User(ctx context.Context) (string, error) {
 	return response, q.Execute(ctx, r.c)
 }
 
+// Attaches an in-toto attestation about this container's image, pushed with it
+// when it is published (e.g., provenance or an SBOM).
+//
+// Attestations are attached to an image index, so publishing them requires
+// OCI media types.
+func (r *Container) WithAttestation(predicateType string, file *File) *Container {
+	q := r.q.Select("withAttestation")
+	q = q.Arg("predicateType", predicateType)
+	q = q.Arg("file", file)
+
+	return &Container{
+		q: q,
+		c: r.c,
+	}
+}
+
 // ContainerWithDefaultArgsOpts contains options for Container.WithDefaultArgs
 type ContainerWithDefaultArgsOpts struct {
 	// Arguments to prepend to future executions (e.g., ["-v", "--no-cache"]).
@@ -844,6 +1202,24 @@ type ContainerWithExecOpts struct {
 	// does not provide any security guarantees when using this option. It should only be used
 	// when absolutely necessary and only with trusted commands.
 	InsecureRootCapabilities bool
+	// The outcome the command is expected to have. The exec fails if the
+	// command's exit code doesn't meet it.
+	//
+	// Defaults to SUCCESS.
+	Expect ExecExpect
+	// The most memory the command may use, with an optional unit suffix
+	// (e.g., "512m", "2g").
+	MemoryLimit string
+	// The command's CPU weight relative to other commands (e.g., 512).
+	CPUShares int
+	// The CPU time the command may use per 100ms period, in microseconds
+	// (e.g., 50000 for half a CPU).
+	CPUQuota int
+	// The most processes the command may run at once.
+	PidsLimit int
+	// The number of seconds the command may run for. Once exceeded, the command
+	// and any processes it started are killed and it exits with code 124.
+	Timeout int
 }
 
 // Retrieves this container after executing the specified command inside it.
@@ -892,6 +1268,48 @@ func (r *Container) WithExec(args []string, opts ...ContainerWithExecOpts) *Cont
 			break
 		}
 	}
+	// `expect` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Expect) {
+			q = q.Arg("expect", opts[i].Expect)
+			break
+		}
+	}
+	// `memoryLimit` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].MemoryLimit) {
+			q = q.Arg("memoryLimit", opts[i].MemoryLimit)
+			break
+		}
+	}
+	// `cpuShares` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].CPUShares) {
+			q = q.Arg("cpuShares", opts[i].CPUShares)
+			break
+		}
+	}
+	// `cpuQuota` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].CPUQuota) {
+			q = q.Arg("cpuQuota", opts[i].CPUQuota)
+			break
+		}
+	}
+	// `pidsLimit` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].PidsLimit) {
+			q = q.Arg("pidsLimit", opts[i].PidsLimit)
+			break
+		}
+	}
+	// `timeout` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Timeout) {
+			q = q.Arg("timeout", opts[i].Timeout)
+			break
+		}
+	}
 
 	return &Container{
 		q: q,
@@ -991,56 +1409,170 @@ func (r *Container) WithFile(path string, source *File, opts ...ContainerWithFil
 	}
 }
 
-// Retrieves this container plus the given label.
-func (r *Container) WithLabel(name string, value string) *Container {
-	q := r.q.Select("withLabel")
-	q = q.Arg("name", name)
-	q = q.Arg("value", value)
-
-	return &Container{
-		q: q,
-		c: r.c,
-	}
-}
-
-// ContainerWithMountedCacheOpts contains options for Container.WithMountedCache
-type ContainerWithMountedCacheOpts struct {
-	// Identifier of the directory to use as the cache volume's root.
-	Source *Directory
-	// Sharing mode of the cache volume.
-	Sharing CacheSharingMode
-	// A user:group to set for the mounted cache directory.
-	//
-	// Note that this changes the ownership of the specified mount along with the
-	// initial filesystem provided by source (if any). It does not have any effect
-	// if/when the cache has already been created.
-	//
-	// The user and group can either be an ID (1000:1000) or a name (foo:bar).
-	//
-	// If the group is omitted, it defaults to the same as the user.
-	Owner string
+// ContainerWithHealthcheckOpts contains options for Container.WithHealthcheck
+type ContainerWithHealthcheckOpts struct {
+	// Command to run; the service is healthy once it exits zero (e.g., ["pg_isready"]).
+	Args []string
+	// Path to request over HTTP; the service is healthy once it responds with a 2xx or 3xx status (e.g., "/health").
+	HTTPPath string
+	// Port to request the HTTP path from. Defaults to the first exposed port.
+	Port int
+	// Seconds between checks. Defaults to 1.
+	Interval int
+	// Consecutive failures, after the start period, before the service is unhealthy. Defaults to 3.
+	Retries int
+	// Seconds to give the service to start, during which failures don't count.
+	StartPeriod int
 }
 
-// Retrieves this container plus a cache volume mounted at the given path.
-func (r *Container) WithMountedCache(path string, cache *CacheVolume, opts ...ContainerWithMountedCacheOpts) *Container {
-	q := r.q.Select("withMountedCache")
-	q = q.Arg("path", path)
-	q = q.Arg("cache", cache)
-	// `source` optional argument
+// Checks whether services run from the container are ready, beyond listening
+// on their exposed ports.
+//
+// The check runs in the container alongside the service, like a Docker
+// healthcheck, and containers bound to the service wait for it to pass. It
+// either runs a command or requests an HTTP path. It isn't run when the
+// container's command is run other than as a service.
+//
+// Command checks are also recorded in the image config when the container is
+// published or exported.
+//
+// Currently experimental; set _EXPERIMENTAL_DAGGER_SERVICES_DNS=0 to disable.
+func (r *Container) WithHealthcheck(opts ...ContainerWithHealthcheckOpts) *Container {
+	q := r.q.Select("withHealthcheck")
+	// `args` optional argument
 	for i := len(opts) - 1; i >= 0; i-- {
-		if !querybuilder.IsZeroValue(opts[i].Source) {
-			q = q.Arg("source", opts[i].Source)
+		if !querybuilder.IsZeroValue(opts[i].Args) {
+			q = q.Arg("args", opts[i].Args)
 			break
 		}
 	}
-	// `sharing` optional argument
+	// `httpPath` optional argument
 	for i := len(opts) - 1; i >= 0; i-- {
-		if !querybuilder.IsZeroValue(opts[i].Sharing) {
-			q = q.Arg("sharing", opts[i].Sharing)
+		if !querybuilder.IsZeroValue(opts[i].HTTPPath) {
+			q = q.Arg("httpPath", opts[i].HTTPPath)
 			break
 		}
 	}
-	// `owner` optional argument
+	// `port` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Port) {
+			q = q.Arg("port", opts[i].Port)
+			break
+		}
+	}
+	// `interval` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Interval) {
+			q = q.Arg("interval", opts[i].Interval)
+			break
+		}
+	}
+	// `retries` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Retries) {
+			q = q.Arg("retries", opts[i].Retries)
+			break
+		}
+	}
+	// `startPeriod` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].StartPeriod) {
+			q = q.Arg("startPeriod", opts[i].StartPeriod)
+			break
+		}
+	}
+
+	return &Container{
+		q: q,
+		c: r.c,
+	}
+}
+
+// ContainerWithHistoryOpts contains options for Container.WithHistory
+type ContainerWithHistoryOpts struct {
+	// A comment on the layer.
+	Comment string
+	// Whether the entry describes a change to the image config instead of a
+	// layer, leaving the layers as they are.
+	EmptyLayer bool
+}
+
+// Describes the layers added since the last history entry with a new entry,
+// merging them into a single layer.
+func (r *Container) WithHistory(createdBy string, opts ...ContainerWithHistoryOpts) *Container {
+	q := r.q.Select("withHistory")
+	q = q.Arg("createdBy", createdBy)
+	// `comment` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Comment) {
+			q = q.Arg("comment", opts[i].Comment)
+			break
+		}
+	}
+	// `emptyLayer` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].EmptyLayer) {
+			q = q.Arg("emptyLayer", opts[i].EmptyLayer)
+			break
+		}
+	}
+
+	return &Container{
+		q: q,
+		c: r.c,
+	}
+}
+
+// Retrieves this container plus the given label.
+func (r *Container) WithLabel(name string, value string) *Container {
+	q := r.q.Select("withLabel")
+	q = q.Arg("name", name)
+	q = q.Arg("value", value)
+
+	return &Container{
+		q: q,
+		c: r.c,
+	}
+}
+
+// ContainerWithMountedCacheOpts contains options for Container.WithMountedCache
+type ContainerWithMountedCacheOpts struct {
+	// Identifier of the directory to use as the cache volume's root.
+	Source *Directory
+	// Sharing mode of the cache volume.
+	Sharing CacheSharingMode
+	// A user:group to set for the mounted cache directory.
+	//
+	// Note that this changes the ownership of the specified mount along with the
+	// initial filesystem provided by source (if any). It does not have any effect
+	// if/when the cache has already been created.
+	//
+	// The user and group can either be an ID (1000:1000) or a name (foo:bar).
+	//
+	// If the group is omitted, it defaults to the same as the user.
+	Owner string
+}
+
+// Retrieves this container plus a cache volume mounted at the given path.
+func (r *Container) WithMountedCache(path string, cache *CacheVolume, opts ...ContainerWithMountedCacheOpts) *Container {
+	q := r.q.Select("withMountedCache")
+	q = q.Arg("path", path)
+	q = q.Arg("cache", cache)
+	// `source` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Source) {
+			q = q.Arg("source", opts[i].Source)
+			break
+		}
+	}
+	// `sharing` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Sharing) {
+			q = q.Arg("sharing", opts[i].Sharing)
+			break
+		}
+	}
+	// `owner` optional argument
 	for i := len(opts) - 1; i >= 0; i-- {
 		if !querybuilder.IsZeroValue(opts[i].Owner) {
 			q = q.Arg("owner", opts[i].Owner)
@@ -1213,6 +1745,21 @@ func (r *Container) WithRegistryAuth(address string, username string, secret *Se
 	}
 }
 
+// Retrieves this container with a file or directory moved to a new path.
+//
+// If the new path is an existing directory, it is moved into it. Both paths
+// must be within the same mount.
+func (r *Container) WithRename(from string, to string) *Container {
+	q := r.q.Select("withRename")
+	q = q.Arg("from", from)
+	q = q.Arg("to", to)
+
+	return &Container{
+		q: q,
+		c: r.c,
+	}
+}
+
 // Initializes this container from this DirectoryID.
 func (r *Container) WithRootfs(id *Directory) *Container {
 	q := r.q.Select("withRootfs")
@@ -1224,6 +1771,30 @@ func (r *Container) WithRootfs(id *Directory) *Container {
 	}
 }
 
+// ContainerWithSbomOpts contains options for Container.WithSbom
+type ContainerWithSbomOpts struct {
+	// The SBOM format. Default: SPDX.
+	Format SbomFormat
+}
+
+// Attaches an SBOM of this container's rootfs as an attestation, generated
+// when the image is published or exported.
+func (r *Container) WithSbom(opts ...ContainerWithSbomOpts) *Container {
+	q := r.q.Select("withSbom")
+	// `format` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Format) {
+			q = q.Arg("format", opts[i].Format)
+			break
+		}
+	}
+
+	return &Container{
+		q: q,
+		c: r.c,
+	}
+}
+
 // Retrieves this container plus an env variable containing the given secret.
 func (r *Container) WithSecretVariable(name string, secret *Secret) *Container {
 	q := r.q.Select("withSecretVariable")
@@ -1254,6 +1825,18 @@ func (r *Container) WithServiceBinding(alias string, service *Container) *Contai
 	}
 }
 
+// Retrieves this container plus a symbolic link at the given path.
+func (r *Container) WithSymlink(target string, linkName string) *Container {
+	q := r.q.Select("withSymlink")
+	q = q.Arg("target", target)
+	q = q.Arg("linkName", linkName)
+
+	return &Container{
+		q: q,
+		c: r.c,
+	}
+}
+
 // ContainerWithUnixSocketOpts contains options for Container.WithUnixSocket
 type ContainerWithUnixSocketOpts struct {
 	// A user:group to set for the mounted socket.
@@ -1404,6 +1987,7 @@ type Directory struct {
 	q *querybuilder.Selection
 	c graphql.Client
 
+	digest *string
 	export *bool
 	id     *DirectoryID
 }
@@ -1413,6 +1997,32 @@ func (r *Directory) With(f WithDirectoryFunc) *Directory {
 	return f(r)
 }
 
+// DirectoryAsTarballOpts contains options for Directory.AsTarball
+type DirectoryAsTarballOpts struct {
+	// Compression to apply to the archive.
+	//
+	// Defaults to an uncompressed tarball.
+	Compression ArchiveCompression
+}
+
+// Packs this directory into an archive, preserving permissions, ownership,
+// timestamps and symbolic links.
+func (r *Directory) AsTarball(opts ...DirectoryAsTarballOpts) *File {
+	q := r.q.Select("asTarball")
+	// `compression` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Compression) {
+			q = q.Arg("compression", opts[i].Compression)
+			break
+		}
+	}
+
+	return &File{
+		q: q,
+		c: r.c,
+	}
+}
+
 // Gets the difference between this directory and an another directory.
 func (r *Directory) Diff(other *Directory) *Directory {
 	q := r.q.Select("diff")
@@ -1424,6 +2034,22 @@ func (r *Directory) Diff(other *Directory) *Directory {
 	}
 }
 
+// Computes a SHA256 digest of the directory's tree (e.g., "sha256:...").
+//
+// The digest covers the path, type, permissions and contents of every entry,
+// but not timestamps or ownership.
+func (r *Directory) Digest(ctx context.Context) (string, error) {
+	if r.digest != nil {
+		return *r.digest, nil
+	}
+	q := r.q.Select("digest")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
 // Retrieves a directory at the given path.
 func (r *Directory) Directory(path string) *Directory {
 	q := r.q.Select("directory")
@@ -1546,6 +2172,18 @@ func (r *Directory) File(path string) *File {
 	}
 }
 
+// Returns the paths of the files and directories matching the given pattern,
+// searching recursively.
+func (r *Directory) Glob(ctx context.Context, pattern string) ([]string, error) {
+	q := r.q.Select("glob")
+	q = q.Arg("pattern", pattern)
+
+	var response []string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
 // The content-addressed identifier of the directory.
 func (r *Directory) ID(ctx context.Context) (DirectoryID, error) {
 	if r.id != nil {
@@ -1617,6 +2255,17 @@ func (r *Directory) Pipeline(name string, opts ...DirectoryPipelineOpts) *Direct
 	}
 }
 
+// Retrieves metadata about the file or directory at the given path.
+func (r *Directory) Stat(path string) *Stat {
+	q := r.q.Select("stat")
+	q = q.Arg("path", path)
+
+	return &Stat{
+		q: q,
+		c: r.c,
+	}
+}
+
 // DirectoryWithDirectoryOpts contains options for Directory.WithDirectory
 type DirectoryWithDirectoryOpts struct {
 	// Exclude artifacts that match the given pattern (e.g., ["node_modules/", ".git*"]).
@@ -1731,6 +2380,70 @@ func (r *Directory) WithNewFile(path string, contents string, opts ...DirectoryW
 	}
 }
 
+// Retrieves this directory with a file or directory moved to a new path.
+//
+// If the new path is an existing directory, it is moved into it.
+func (r *Directory) WithRename(from string, to string) *Directory {
+	q := r.q.Select("withRename")
+	q = q.Arg("from", from)
+	q = q.Arg("to", to)
+
+	return &Directory{
+		q: q,
+		c: r.c,
+	}
+}
+
+// Retrieves this directory plus a symbolic link at the given path.
+func (r *Directory) WithSymlink(target string, linkName string) *Directory {
+	q := r.q.Select("withSymlink")
+	q = q.Arg("target", target)
+	q = q.Arg("linkName", linkName)
+
+	return &Directory{
+		q: q,
+		c: r.c,
+	}
+}
+
+// DirectoryWithTemplateOpts contains options for Directory.WithTemplate
+type DirectoryWithTemplateOpts struct {
+	// Location to write the rendered file to (e.g., "config.yaml").
+	//
+	// Defaults to rendering the template in place.
+	Dest string
+	// Variables available to the template.
+	Variables []TemplateVariable
+}
+
+// Retrieves this directory with a Go text/template file rendered.
+//
+// Variables are referenced by name (e.g., "{{ .VERSION }}"). Referencing a
+// variable that is not set is an error.
+func (r *Directory) WithTemplate(path string, opts ...DirectoryWithTemplateOpts) *Directory {
+	q := r.q.Select("withTemplate")
+	q = q.Arg("path", path)
+	// `dest` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Dest) {
+			q = q.Arg("dest", opts[i].Dest)
+			break
+		}
+	}
+	// `variables` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Variables) {
+			q = q.Arg("variables", opts[i].Variables)
+			break
+		}
+	}
+
+	return &Directory{
+		q: q,
+		c: r.c,
+	}
+}
+
 // Retrieves this directory with all file/dir timestamps set to the given time.
 func (r *Directory) WithTimestamps(timestamp int) *Directory {
 	q := r.q.Select("withTimestamps")
@@ -1799,87 +2512,207 @@ func (r *EnvVariable) Value(ctx context.Context) (string, error) {
 	return response, q.Execute(ctx, r.c)
 }
 
-// A file.
-type File struct {
+// The outcome of a container's last executed command.
+type ExecResult struct {
 	q *querybuilder.Selection
 	c graphql.Client
 
-	contents *string
-	export   *bool
-	id       *FileID
-	size     *int
+	duration   *int
+	exitCode   *int
+	finishedAt *string
+	startedAt  *string
+	stderr     *string
+	stdout     *string
 }
 
-// Retrieves the contents of the file.
-func (r *File) Contents(ctx context.Context) (string, error) {
-	if r.contents != nil {
-		return *r.contents, nil
+// How long the command ran for, in milliseconds.
+func (r *ExecResult) Duration(ctx context.Context) (int, error) {
+	if r.duration != nil {
+		return *r.duration, nil
 	}
-	q := r.q.Select("contents")
+	q := r.q.Select("duration")
 
-	var response string
+	var response int
 
 	q = q.Bind(&response)
 	return response, q.Execute(ctx, r.c)
 }
 
-// Writes the file to a file path on the host.
-func (r *File) Export(ctx context.Context, path string) (bool, error) {
-	if r.export != nil {
-		return *r.export, nil
+// The exit code of the command.
+func (r *ExecResult) ExitCode(ctx context.Context) (int, error) {
+	if r.exitCode != nil {
+		return *r.exitCode, nil
 	}
-	q := r.q.Select("export")
-	q = q.Arg("path", path)
+	q := r.q.Select("exitCode")
 
-	var response bool
+	var response int
 
 	q = q.Bind(&response)
 	return response, q.Execute(ctx, r.c)
 }
 
-// Retrieves the content-addressed identifier of the file.
-func (r *File) ID(ctx context.Context) (FileID, error) {
-	if r.id != nil {
-		return *r.id, nil
+// When the command finished, in RFC 3339 format.
+func (r *ExecResult) FinishedAt(ctx context.Context) (string, error) {
+	if r.finishedAt != nil {
+		return *r.finishedAt, nil
 	}
-	q := r.q.Select("id")
+	q := r.q.Select("finishedAt")
 
-	var response FileID
+	var response string
 
 	q = q.Bind(&response)
 	return response, q.Execute(ctx, r.c)
 }
 
-// XXX_GraphQLType is an internal function. It returns the native GraphQL type name
-func (r *File) XXX_GraphQLType() string {
-	return "File"
-}
-
-// XXX_GraphQLID is an internal function. It returns the underlying type ID
-func (r *File) XXX_GraphQLID(ctx context.Context) (string, error) {
-	id, err := r.ID(ctx)
-	if err != nil {
-		return "", err
+// When the command started, in RFC 3339 format.
+func (r *ExecResult) StartedAt(ctx context.Context) (string, error) {
+	if r.startedAt != nil {
+		return *r.startedAt, nil
 	}
-	return string(id), nil
-}
+	q := r.q.Select("startedAt")
 
-// Retrieves a secret referencing the contents of this file.
-//
-// Deprecated: insecure, leaves secret in cache. Superseded by SetSecret
-func (r *File) Secret() *Secret {
-	q := r.q.Select("secret")
+	var response string
 
-	return &Secret{
-		q: q,
-		c: r.c,
-	}
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
 }
 
-// Gets the size of the file, in bytes.
-func (r *File) Size(ctx context.Context) (int, error) {
-	if r.size != nil {
-		return *r.size, nil
+// The error stream of the command. Empty if it was redirected.
+func (r *ExecResult) Stderr(ctx context.Context) (string, error) {
+	if r.stderr != nil {
+		return *r.stderr, nil
+	}
+	q := r.q.Select("stderr")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The output stream of the command. Empty if it was redirected.
+func (r *ExecResult) Stdout(ctx context.Context) (string, error) {
+	if r.stdout != nil {
+		return *r.stdout, nil
+	}
+	q := r.q.Select("stdout")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// A file.
+type File struct {
+	q *querybuilder.Selection
+	c graphql.Client
+
+	contents *string
+	digest   *string
+	export   *bool
+	id       *FileID
+	size     *int
+}
+
+// Retrieves the contents of the file.
+func (r *File) Contents(ctx context.Context) (string, error) {
+	if r.contents != nil {
+		return *r.contents, nil
+	}
+	q := r.q.Select("contents")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// FileDigestOpts contains options for File.Digest
+type FileDigestOpts struct {
+	// Hash algorithm to use.
+	//
+	// Defaults to SHA256.
+	Algorithm DigestAlgorithm
+}
+
+// Computes the digest of the file's contents (e.g., "sha256:...").
+func (r *File) Digest(ctx context.Context, opts ...FileDigestOpts) (string, error) {
+	if r.digest != nil {
+		return *r.digest, nil
+	}
+	q := r.q.Select("digest")
+	// `algorithm` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Algorithm) {
+			q = q.Arg("algorithm", opts[i].Algorithm)
+			break
+		}
+	}
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// Writes the file to a file path on the host.
+func (r *File) Export(ctx context.Context, path string) (bool, error) {
+	if r.export != nil {
+		return *r.export, nil
+	}
+	q := r.q.Select("export")
+	q = q.Arg("path", path)
+
+	var response bool
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// Retrieves the content-addressed identifier of the file.
+func (r *File) ID(ctx context.Context) (FileID, error) {
+	if r.id != nil {
+		return *r.id, nil
+	}
+	q := r.q.Select("id")
+
+	var response FileID
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// XXX_GraphQLType is an internal function. It returns the native GraphQL type name
+func (r *File) XXX_GraphQLType() string {
+	return "File"
+}
+
+// XXX_GraphQLID is an internal function. It returns the underlying type ID
+func (r *File) XXX_GraphQLID(ctx context.Context) (string, error) {
+	id, err := r.ID(ctx)
+	if err != nil {
+		return "", err
+	}
+	return string(id), nil
+}
+
+// Retrieves a secret referencing the contents of this file.
+//
+// Deprecated: insecure, leaves secret in cache. Superseded by SetSecret
+func (r *File) Secret() *Secret {
+	q := r.q.Select("secret")
+
+	return &Secret{
+		q: q,
+		c: r.c,
+	}
+}
+
+// Gets the size of the file, in bytes.
+func (r *File) Size(ctx context.Context) (int, error) {
+	if r.size != nil {
+		return *r.size, nil
 	}
 	q := r.q.Select("size")
 
@@ -1889,6 +2722,67 @@ func (r *File) Size(ctx context.Context) (int, error) {
 	return response, q.Execute(ctx, r.c)
 }
 
+// Retrieves metadata about the file.
+func (r *File) Stat() *Stat {
+	q := r.q.Select("stat")
+
+	return &Stat{
+		q: q,
+		c: r.c,
+	}
+}
+
+// Extracts this file into a directory.
+//
+// The file must be a tarball, optionally compressed with gzip, bzip2 or zstd,
+// or a zip archive.
+func (r *File) Unpack() *Directory {
+	q := r.q.Select("unpack")
+
+	return &Directory{
+		q: q,
+		c: r.c,
+	}
+}
+
+// Retrieves this file with the given contents appended to it.
+func (r *File) WithAppended(contents string) *File {
+	q := r.q.Select("withAppended")
+	q = q.Arg("contents", contents)
+
+	return &File{
+		q: q,
+		c: r.c,
+	}
+}
+
+// FileWithReplacedOpts contains options for File.WithReplaced
+type FileWithReplacedOpts struct {
+	// Replace every occurrence rather than only the first.
+	All bool
+}
+
+// Retrieves this file with the given string replaced in its contents.
+//
+// Errors if the string is not found.
+func (r *File) WithReplaced(search string, replacement string, opts ...FileWithReplacedOpts) *File {
+	q := r.q.Select("withReplaced")
+	q = q.Arg("search", search)
+	q = q.Arg("replacement", replacement)
+	// `all` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].All) {
+			q = q.Arg("all", opts[i].All)
+			break
+		}
+	}
+
+	return &File{
+		q: q,
+		c: r.c,
+	}
+}
+
 // Retrieves this file with its created/modified timestamps set to the given time.
 func (r *File) WithTimestamps(timestamp int) *File {
 	q := r.q.Select("withTimestamps")
@@ -1900,6 +2794,93 @@ func (r *File) WithTimestamps(timestamp int) *File {
 	}
 }
 
+// A commit in a git repository.
+type GitCommit struct {
+	q *querybuilder.Selection
+	c graphql.Client
+
+	author    *string
+	committer *string
+	hash      *string
+	message   *string
+	timestamp *string
+}
+
+// The author of the commit (e.g., "Jane Doe <jane@example.com>").
+func (r *GitCommit) Author(ctx context.Context) (string, error) {
+	if r.author != nil {
+		return *r.author, nil
+	}
+	q := r.q.Select("author")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The committer of the commit (e.g., "Jane Doe <jane@example.com>").
+func (r *GitCommit) Committer(ctx context.Context) (string, error) {
+	if r.committer != nil {
+		return *r.committer, nil
+	}
+	q := r.q.Select("committer")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The full hash of the commit.
+func (r *GitCommit) Hash(ctx context.Context) (string, error) {
+	if r.hash != nil {
+		return *r.hash, nil
+	}
+	q := r.q.Select("hash")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The commit message.
+func (r *GitCommit) Message(ctx context.Context) (string, error) {
+	if r.message != nil {
+		return *r.message, nil
+	}
+	q := r.q.Select("message")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The full hashes of the parent commits.
+func (r *GitCommit) Parents(ctx context.Context) ([]string, error) {
+	q := r.q.Select("parents")
+
+	var response []string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The time the commit was made, formatted as RFC3339 (e.g., "2023-03-01T17:18:11Z").
+func (r *GitCommit) Timestamp(ctx context.Context) (string, error) {
+	if r.timestamp != nil {
+		return *r.timestamp, nil
+	}
+	q := r.q.Select("timestamp")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
 // A git ref (tag, branch or commit).
 type GitRef struct {
 	q *querybuilder.Selection
@@ -1908,6 +2889,28 @@ type GitRef struct {
 	digest *string
 }
 
+// Lists the files changed at this ref since it diverged from another ref
+// (e.g., "git diff --name-only main...HEAD").
+func (r *GitRef) ChangedFiles(ctx context.Context, base string) ([]string, error) {
+	q := r.q.Select("changedFiles")
+	q = q.Arg("base", base)
+
+	var response []string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// Metadata about the commit this ref points to.
+func (r *GitRef) CommitInfo() *GitCommit {
+	q := r.q.Select("commitInfo")
+
+	return &GitCommit{
+		q: q,
+		c: r.c,
+	}
+}
+
 // The digest of the current value of this ref.
 func (r *GitRef) Digest(ctx context.Context) (string, error) {
 	if r.digest != nil {
@@ -1956,6 +2959,9 @@ func (r *GitRef) Tree(opts ...GitRefTreeOpts) *Directory {
 type GitRepository struct {
 	q *querybuilder.Selection
 	c graphql.Client
+
+	headBranch *string
+	isDirty    *bool
 }
 
 // Returns details on one branch.
@@ -1990,10 +2996,10 @@ func (r *GitRepository) Commit(id string) *GitRef {
 	}
 }
 
-// Returns details on one tag.
-func (r *GitRepository) Tag(name string) *GitRef {
-	q := r.q.Select("tag")
-	q = q.Arg("name", name)
+// Returns details on the ref checked out in a repository on the host, or the
+// default branch of a remote repository.
+func (r *GitRepository) Head() *GitRef {
+	q := r.q.Select("head")
 
 	return &GitRef{
 		q: q,
@@ -2001,34 +3007,127 @@ func (r *GitRepository) Tag(name string) *GitRef {
 	}
 }
 
-// Lists of tags on the repository.
-func (r *GitRepository) Tags(ctx context.Context) ([]string, error) {
-	q := r.q.Select("tags")
+// The name of the branch head points to (e.g., "main").
+//
+// Empty if head is detached.
+func (r *GitRepository) HeadBranch(ctx context.Context) (string, error) {
+	if r.headBranch != nil {
+		return *r.headBranch, nil
+	}
+	q := r.q.Select("headBranch")
 
-	var response []string
+	var response string
 
 	q = q.Bind(&response)
 	return response, q.Execute(ctx, r.c)
 }
 
-// Information about the host execution environment.
-type Host struct {
-	q *querybuilder.Selection
-	c graphql.Client
+// Whether a repository on the host has uncommitted changes to tracked files.
+//
+// Always false for remote repositories.
+func (r *GitRepository) IsDirty(ctx context.Context) (bool, error) {
+	if r.isDirty != nil {
+		return *r.isDirty, nil
+	}
+	q := r.q.Select("isDirty")
+
+	var response bool
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
 }
 
-// HostDirectoryOpts contains options for Host.Directory
-type HostDirectoryOpts struct {
-	// Exclude artifacts that match the given pattern (e.g., ["node_modules/", ".git*"]).
-	Exclude []string
-	// Include only artifacts that match the given pattern (e.g., ["app/", "package.*"]).
-	Include []string
+// GitRepositoryLogOpts contains options for GitRepository.Log
+type GitRepositoryLogOpts struct {
+	// Branch, tag or commit to exclude the history of (e.g., "v0.3.8").
+	//
+	// Defaults to listing the whole history.
+	From string
 }
 
-// Accesses a directory on the host.
-func (r *Host) Directory(path string, opts ...HostDirectoryOpts) *Directory {
-	q := r.q.Select("directory")
-	q = q.Arg("path", path)
+// Lists the commits reachable from one ref but not from another, newest first
+// (e.g., "git log v0.3.8..v0.3.9").
+func (r *GitRepository) Log(ctx context.Context, to string, opts ...GitRepositoryLogOpts) ([]GitCommit, error) {
+	q := r.q.Select("log")
+	// `from` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].From) {
+			q = q.Arg("from", opts[i].From)
+			break
+		}
+	}
+	q = q.Arg("to", to)
+
+	q = q.Select("author committer hash message timestamp")
+
+	type log struct {
+		Author    string
+		Committer string
+		Hash      string
+		Message   string
+		Timestamp string
+	}
+
+	convert := func(fields []log) []GitCommit {
+		out := []GitCommit{}
+
+		for _, field := range fields {
+			out = append(out, GitCommit{author: &field.Author, committer: &field.Committer, hash: &field.Hash, message: &field.Message, timestamp: &field.Timestamp})
+		}
+
+		return out
+	}
+	var response []log
+
+	q = q.Bind(&response)
+
+	err := q.Execute(ctx, r.c)
+	if err != nil {
+		return nil, err
+	}
+
+	return convert(response), nil
+}
+
+// Returns details on one tag.
+func (r *GitRepository) Tag(name string) *GitRef {
+	q := r.q.Select("tag")
+	q = q.Arg("name", name)
+
+	return &GitRef{
+		q: q,
+		c: r.c,
+	}
+}
+
+// Lists of tags on the repository.
+func (r *GitRepository) Tags(ctx context.Context) ([]string, error) {
+	q := r.q.Select("tags")
+
+	var response []string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// Information about the host execution environment.
+type Host struct {
+	q *querybuilder.Selection
+	c graphql.Client
+}
+
+// HostDirectoryOpts contains options for Host.Directory
+type HostDirectoryOpts struct {
+	// Exclude artifacts that match the given pattern (e.g., ["node_modules/", ".git*"]).
+	Exclude []string
+	// Include only artifacts that match the given pattern (e.g., ["app/", "package.*"]).
+	Include []string
+}
+
+// Accesses a directory on the host.
+func (r *Host) Directory(path string, opts ...HostDirectoryOpts) *Directory {
+	q := r.q.Select("directory")
+	q = q.Arg("path", path)
 	// `exclude` optional argument
 	for i := len(opts) - 1; i >= 0; i-- {
 		if !querybuilder.IsZeroValue(opts[i].Exclude) {
@@ -2061,6 +3160,85 @@ func (r *Host) EnvVariable(name string) *HostVariable {
 	}
 }
 
+// HostGitRepositoryOpts contains options for Host.GitRepository
+type HostGitRepositoryOpts struct {
+	// Set to true for the tree of the repository's head to be the working tree,
+	// including uncommitted changes and untracked files that are not ignored.
+	IncludeUncommitted bool
+}
+
+// Accesses the git repository containing a directory on the host.
+//
+// Its history is read directly from the host, and branches, tags and commits
+// are checked out from the repository without submodules.
+func (r *Host) GitRepository(path string, opts ...HostGitRepositoryOpts) *GitRepository {
+	q := r.q.Select("gitRepository")
+	q = q.Arg("path", path)
+	// `includeUncommitted` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].IncludeUncommitted) {
+			q = q.Arg("includeUncommitted", opts[i].IncludeUncommitted)
+			break
+		}
+	}
+
+	return &GitRepository{
+		q: q,
+		c: r.c,
+	}
+}
+
+// Creates a service that forwards traffic to ports on the host's localhost,
+// so that containers bound to it can reach services running on the host.
+//
+// The service is a container that can be bound with
+// `Container.withServiceBinding`.
+//
+// Currently experimental; set _EXPERIMENTAL_DAGGER_SERVICES_DNS=0 to disable.
+func (r *Host) Service(ports []PortForward) *Container {
+	q := r.q.Select("service")
+	q = q.Arg("ports", ports)
+
+	return &Container{
+		q: q,
+		c: r.c,
+	}
+}
+
+// HostTunnelOpts contains options for Host.Tunnel
+type HostTunnelOpts struct {
+	// Ports to forward, from a frontend port on the host to a backend port of
+	// the service. A frontend port of 0 is assigned randomly.
+	//
+	// Defaults to each of the service's exposed ports, forwarded from the same
+	// port on the host.
+	Ports []PortForward
+}
+
+// Forwards ports on the host's localhost to a service, starting the service if
+// it isn't running already.
+//
+// The ports are forwarded until the session ends. Only TCP ports can be
+// forwarded.
+//
+// Currently experimental; set _EXPERIMENTAL_DAGGER_SERVICES_DNS=0 to disable.
+func (r *Host) Tunnel(ctx context.Context, service *Service, opts ...HostTunnelOpts) ([]string, error) {
+	q := r.q.Select("tunnel")
+	q = q.Arg("service", service)
+	// `ports` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Ports) {
+			q = q.Arg("ports", opts[i].Ports)
+			break
+		}
+	}
+
+	var response []string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
 // Accesses a Unix socket on the host.
 func (r *Host) UnixSocket(path string) *Socket {
 	q := r.q.Select("unixSocket")
@@ -2139,6 +3317,55 @@ func (r *HostVariable) Value(ctx context.Context) (string, error) {
 	return response, q.Execute(ctx, r.c)
 }
 
+// A layer of an image in a registry.
+type ImageLayer struct {
+	q *querybuilder.Selection
+	c graphql.Client
+
+	digest    *string
+	mediaType *string
+	size      *int
+}
+
+// The digest of the layer's blob.
+func (r *ImageLayer) Digest(ctx context.Context) (string, error) {
+	if r.digest != nil {
+		return *r.digest, nil
+	}
+	q := r.q.Select("digest")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The media type of the layer's blob.
+func (r *ImageLayer) MediaType(ctx context.Context) (string, error) {
+	if r.mediaType != nil {
+		return *r.mediaType, nil
+	}
+	q := r.q.Select("mediaType")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The size of the layer's blob, in bytes.
+func (r *ImageLayer) Size(ctx context.Context) (int, error) {
+	if r.size != nil {
+		return *r.size, nil
+	}
+	q := r.q.Select("size")
+
+	var response int
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
 // A simple key value object that represents a label.
 type Label struct {
 	q *querybuilder.Selection
@@ -2174,6 +3401,41 @@ func (r *Label) Value(ctx context.Context) (string, error) {
 	return response, q.Execute(ctx, r.c)
 }
 
+// The digest of a published image's manifest for one platform.
+type PlatformDigest struct {
+	q *querybuilder.Selection
+	c graphql.Client
+
+	digest   *string
+	platform *Platform
+}
+
+// The manifest digest.
+func (r *PlatformDigest) Digest(ctx context.Context) (string, error) {
+	if r.digest != nil {
+		return *r.digest, nil
+	}
+	q := r.q.Select("digest")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The platform of the manifest.
+func (r *PlatformDigest) Platform(ctx context.Context) (Platform, error) {
+	if r.platform != nil {
+		return *r.platform, nil
+	}
+	q := r.q.Select("platform")
+
+	var response Platform
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
 // A port exposed by a container.
 type Port struct {
 	q *querybuilder.Selection
@@ -2330,6 +3592,109 @@ func (r *Project) SDK(ctx context.Context) (string, error) {
 	return response, q.Execute(ctx, r.c)
 }
 
+// An image published to a registry.
+type PublishResult struct {
+	q *querybuilder.Selection
+	c graphql.Client
+
+	digest         *string
+	indexDigest    *string
+	manifestDigest *string
+}
+
+// The digest of what was pushed: the index of a multi-platform image, or the
+// manifest otherwise.
+func (r *PublishResult) Digest(ctx context.Context) (string, error) {
+	if r.digest != nil {
+		return *r.digest, nil
+	}
+	q := r.q.Select("digest")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The digest of the image index, if a multi-platform image was pushed.
+func (r *PublishResult) IndexDigest(ctx context.Context) (string, error) {
+	if r.indexDigest != nil {
+		return *r.indexDigest, nil
+	}
+	q := r.q.Select("indexDigest")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The digest of the manifest for this container's platform.
+func (r *PublishResult) ManifestDigest(ctx context.Context) (string, error) {
+	if r.manifestDigest != nil {
+		return *r.manifestDigest, nil
+	}
+	q := r.q.Select("manifestDigest")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The digest of the manifest for each published platform.
+func (r *PublishResult) Platforms(ctx context.Context) ([]PlatformDigest, error) {
+	q := r.q.Select("platforms")
+
+	q = q.Select("digest platform")
+
+	type platforms struct {
+		Digest   string
+		Platform Platform
+	}
+
+	convert := func(fields []platforms) []PlatformDigest {
+		out := []PlatformDigest{}
+
+		for _, field := range fields {
+			out = append(out, PlatformDigest{digest: &field.Digest, platform: &field.Platform})
+		}
+
+		return out
+	}
+	var response []platforms
+
+	q = q.Bind(&response)
+
+	err := q.Execute(ctx, r.c)
+	if err != nil {
+		return nil, err
+	}
+
+	return convert(response), nil
+}
+
+// The addresses the image was published to, pinned to its digest if the
+// exporter reported one.
+func (r *PublishResult) Refs(ctx context.Context) ([]string, error) {
+	q := r.q.Select("refs")
+
+	var response []string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The refs of the signatures pushed for the image, if it was signed.
+func (r *PublishResult) Signatures(ctx context.Context) ([]string, error) {
+	q := r.q.Select("signatures")
+
+	var response []string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
 // Constructs a cache volume for a given cache key.
 func (r *Client) CacheVolume(key string) *CacheVolume {
 	q := r.q.Select("cacheVolume")
@@ -2423,6 +3788,26 @@ func (r *Client) File(id FileID) *File {
 type GitOpts struct {
 	// Set to true to keep .git directory.
 	KeepGitDir bool
+	// Secret used as the password for HTTP basic authentication, such as a
+	// personal access token.
+	HTTPAuthToken *Secret
+	// Secret used as the value of the HTTP Authorization header (e.g., "Basic <credentials>").
+	//
+	// Takes precedence over httpAuthToken.
+	HTTPAuthHeader *Secret
+	// Set to false to skip checking out submodules.
+	//
+	// Defaults to true.
+	Submodules bool
+	// Number of commits of history to fetch (e.g., 50).
+	//
+	// Applies to the .git directory kept with keepGitDir and to the history
+	// inspected by log, commitInfo and changedFiles. The files checked out in the
+	// tree are the same at any depth.
+	//
+	// Defaults to the whole history, or to a single commit for the .git directory
+	// kept with keepGitDir.
+	Depth int
 	// A service which must be started before the repo is fetched.
 	ExperimentalServiceHost *Container
 }
@@ -2438,6 +3823,34 @@ func (r *Client) Git(url string, opts ...GitOpts) *GitRepository {
 			break
 		}
 	}
+	// `httpAuthToken` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].HTTPAuthToken) {
+			q = q.Arg("httpAuthToken", opts[i].HTTPAuthToken)
+			break
+		}
+	}
+	// `httpAuthHeader` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].HTTPAuthHeader) {
+			q = q.Arg("httpAuthHeader", opts[i].HTTPAuthHeader)
+			break
+		}
+	}
+	// `submodules` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Submodules) {
+			q = q.Arg("submodules", opts[i].Submodules)
+			break
+		}
+	}
+	// `depth` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Depth) {
+			q = q.Arg("depth", opts[i].Depth)
+			break
+		}
+	}
 	// `experimentalServiceHost` optional argument
 	for i := len(opts) - 1; i >= 0; i-- {
 		if !querybuilder.IsZeroValue(opts[i].ExperimentalServiceHost) {
@@ -2462,8 +3875,71 @@ func (r *Client) Host() *Host {
 	}
 }
 
+// HostImageOpts contains options for Query.HostImage
+type HostImageOpts struct {
+	// The container runtime to take the image from.
+	//
+	// Defaults to Docker.
+	Runtime ContainerRuntime
+	// Host socket the runtime listens on.
+	//
+	// Defaults to the runtime's usual socket (e.g., /var/run/docker.sock).
+	Socket *Socket
+	// Platform of the image to load. Defaults to the default platform of the builder.
+	Platform Platform
+}
+
+// Loads a container from an image already present in a container runtime on
+// the host.
+func (r *Client) HostImage(ref string, opts ...HostImageOpts) *Container {
+	q := r.q.Select("hostImage")
+	q = q.Arg("ref", ref)
+	// `runtime` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Runtime) {
+			q = q.Arg("runtime", opts[i].Runtime)
+			break
+		}
+	}
+	// `socket` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Socket) {
+			q = q.Arg("socket", opts[i].Socket)
+			break
+		}
+	}
+	// `platform` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Platform) {
+			q = q.Arg("platform", opts[i].Platform)
+			break
+		}
+	}
+
+	return &Container{
+		q: q,
+		c: r.c,
+	}
+}
+
 // HTTPOpts contains options for Query.HTTP
 type HTTPOpts struct {
+	// Digest the downloaded content must match (e.g., "sha256:...").
+	//
+	// The download fails if it does not match.
+	Checksum string
+	// Headers to send with the request.
+	Headers []HTTPHeader
+	// Secret used as the value of the Authorization header (e.g., "Bearer <token>").
+	AuthHeader *Secret
+	// Name of the downloaded file.
+	//
+	// Defaults to a hash of the URL.
+	Name string
+	// Permission given to the downloaded file (e.g., 0755).
+	//
+	// Default: 0600.
+	Permissions int
 	// A service which must be started before the URL is fetched.
 	ExperimentalServiceHost *Container
 }
@@ -2472,6 +3948,41 @@ type HTTPOpts struct {
 func (r *Client) HTTP(url string, opts ...HTTPOpts) *File {
 	q := r.q.Select("http")
 	q = q.Arg("url", url)
+	// `checksum` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Checksum) {
+			q = q.Arg("checksum", opts[i].Checksum)
+			break
+		}
+	}
+	// `headers` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Headers) {
+			q = q.Arg("headers", opts[i].Headers)
+			break
+		}
+	}
+	// `authHeader` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].AuthHeader) {
+			q = q.Arg("authHeader", opts[i].AuthHeader)
+			break
+		}
+	}
+	// `name` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Name) {
+			q = q.Arg("name", opts[i].Name)
+			break
+		}
+	}
+	// `permissions` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Permissions) {
+			q = q.Arg("permissions", opts[i].Permissions)
+			break
+		}
+	}
 	// `experimentalServiceHost` optional argument
 	for i := len(opts) - 1; i >= 0; i-- {
 		if !querybuilder.IsZeroValue(opts[i].ExperimentalServiceHost) {
@@ -2486,6 +3997,32 @@ func (r *Client) HTTP(url string, opts ...HTTPOpts) *File {
 	}
 }
 
+// ImageOpts contains options for Query.Image
+type ImageOpts struct {
+	// Platform to describe the config and layers of.
+	//
+	// Defaults to the default platform of the builder.
+	Platform Platform
+}
+
+// Looks up an image in its registry without pulling it.
+func (r *Client) Image(address string, opts ...ImageOpts) *RegistryImage {
+	q := r.q.Select("image")
+	q = q.Arg("address", address)
+	// `platform` optional argument
+	for i := len(opts) - 1; i >= 0; i-- {
+		if !querybuilder.IsZeroValue(opts[i].Platform) {
+			q = q.Arg("platform", opts[i].Platform)
+			break
+		}
+	}
+
+	return &RegistryImage{
+		q: q,
+		c: r.c,
+	}
+}
+
 // PipelineOpts contains options for Query.Pipeline
 type PipelineOpts struct {
 	// Pipeline description.
@@ -2541,6 +4078,17 @@ func (r *Client) Secret(id SecretID) *Secret {
 	}
 }
 
+// Loads a service from ID.
+func (r *Client) Service(id ServiceID) *Service {
+	q := r.q.Select("service")
+	q = q.Arg("id", id)
+
+	return &Service{
+		q: q,
+		c: r.c,
+	}
+}
+
 // Sets a secret given a user defined name to its plaintext and returns the secret.
 func (r *Client) SetSecret(name string, plaintext string) *Secret {
 	q := r.q.Select("setSecret")
@@ -2575,6 +4123,215 @@ func (r *Client) Socket(opts ...SocketOpts) *Socket {
 	}
 }
 
+// Pulls the images of a registry from a mirror within the returned query.
+//
+// Only containers and images loaded from the returned query, or queries
+// chained from it, use the mirror; the rest of the session is unaffected.
+func (r *Client) WithRegistryMirror(registry string, mirror string) *Client {
+	q := r.q.Select("withRegistryMirror")
+	q = q.Arg("registry", registry)
+	q = q.Arg("mirror", mirror)
+
+	return &Client{
+		q: q,
+		c: r.c,
+	}
+}
+
+// An image in a registry.
+type RegistryImage struct {
+	q *querybuilder.Selection
+	c graphql.Client
+
+	address        *string
+	created        *string
+	digest         *string
+	exists         *bool
+	manifestDigest *string
+	platform       *Platform
+}
+
+// The address the image was looked up by.
+func (r *RegistryImage) Address(ctx context.Context) (string, error) {
+	if r.address != nil {
+		return *r.address, nil
+	}
+	q := r.q.Select("address")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// When the image was created, in RFC 3339 format.
+func (r *RegistryImage) Created(ctx context.Context) (string, error) {
+	if r.created != nil {
+		return *r.created, nil
+	}
+	q := r.q.Select("created")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The digest the address resolves to: an index for a multi-platform image, or
+// a manifest otherwise.
+func (r *RegistryImage) Digest(ctx context.Context) (string, error) {
+	if r.digest != nil {
+		return *r.digest, nil
+	}
+	q := r.q.Select("digest")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// Whether the address resolves to an image.
+func (r *RegistryImage) Exists(ctx context.Context) (bool, error) {
+	if r.exists != nil {
+		return *r.exists, nil
+	}
+	q := r.q.Select("exists")
+
+	var response bool
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The labels set in the image's config.
+func (r *RegistryImage) Labels(ctx context.Context) ([]Label, error) {
+	q := r.q.Select("labels")
+
+	q = q.Select("name value")
+
+	type labels struct {
+		Name  string
+		Value string
+	}
+
+	convert := func(fields []labels) []Label {
+		out := []Label{}
+
+		for _, field := range fields {
+			out = append(out, Label{name: &field.Name, value: &field.Value})
+		}
+
+		return out
+	}
+	var response []labels
+
+	q = q.Bind(&response)
+
+	err := q.Execute(ctx, r.c)
+	if err != nil {
+		return nil, err
+	}
+
+	return convert(response), nil
+}
+
+// The layers of the image, from the bottom up.
+func (r *RegistryImage) Layers(ctx context.Context) ([]ImageLayer, error) {
+	q := r.q.Select("layers")
+
+	q = q.Select("digest mediaType size")
+
+	type layers struct {
+		Digest    string
+		MediaType string
+		Size      int
+	}
+
+	convert := func(fields []layers) []ImageLayer {
+		out := []ImageLayer{}
+
+		for _, field := range fields {
+			out = append(out, ImageLayer{digest: &field.Digest, mediaType: &field.MediaType, size: &field.Size})
+		}
+
+		return out
+	}
+	var response []layers
+
+	q = q.Bind(&response)
+
+	err := q.Execute(ctx, r.c)
+	if err != nil {
+		return nil, err
+	}
+
+	return convert(response), nil
+}
+
+// The digest of the manifest for the platform.
+//
+// Null if the image isn't available for the requested platform.
+func (r *RegistryImage) ManifestDigest(ctx context.Context) (string, error) {
+	if r.manifestDigest != nil {
+		return *r.manifestDigest, nil
+	}
+	q := r.q.Select("manifestDigest")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The platform described by the config and layers.
+//
+// Null if the image isn't available for the requested platform, in which case
+// the config and layers aren't described either.
+func (r *RegistryImage) Platform(ctx context.Context) (Platform, error) {
+	if r.platform != nil {
+		return *r.platform, nil
+	}
+	q := r.q.Select("platform")
+
+	var response Platform
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The digest of the manifest for each platform the image is available for.
+func (r *RegistryImage) Platforms(ctx context.Context) ([]PlatformDigest, error) {
+	q := r.q.Select("platforms")
+
+	q = q.Select("digest platform")
+
+	type platforms struct {
+		Digest   string
+		Platform Platform
+	}
+
+	convert := func(fields []platforms) []PlatformDigest {
+		out := []PlatformDigest{}
+
+		for _, field := range fields {
+			out = append(out, PlatformDigest{digest: &field.Digest, platform: &field.Platform})
+		}
+
+		return out
+	}
+	var response []platforms
+
+	q = q.Bind(&response)
+
+	err := q.Execute(ctx, r.c)
+	if err != nil {
+		return nil, err
+	}
+
+	return convert(response), nil
+}
+
 // A reference to a secret value, which can be handled more safely than the value itself.
 type Secret struct {
 	q *querybuilder.Selection
@@ -2624,6 +4381,134 @@ func (r *Secret) Plaintext(ctx context.Context) (string, error) {
 	return response, q.Execute(ctx, r.c)
 }
 
+// A container run as a service whose lifecycle is controlled explicitly.
+//
+// Containers bound to the service while it's running share it rather than
+// starting their own.
+//
+// Currently experimental; set _EXPERIMENTAL_DAGGER_SERVICES_DNS=0 to disable.
+type Service struct {
+	q *querybuilder.Selection
+	c graphql.Client
+
+	hostname *string
+	id       *ServiceID
+	logs     *string
+	start    *ServiceID
+	status   *ServiceStatus
+	stop     *ServiceID
+}
+
+// The container run as the service.
+func (r *Service) Container() *Container {
+	q := r.q.Select("container")
+
+	return &Container{
+		q: q,
+		c: r.c,
+	}
+}
+
+// The hostname the service can be reached at by the containers bound to it.
+func (r *Service) Hostname(ctx context.Context) (string, error) {
+	if r.hostname != nil {
+		return *r.hostname, nil
+	}
+	q := r.q.Select("hostname")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// A unique identifier for this service.
+func (r *Service) ID(ctx context.Context) (ServiceID, error) {
+	if r.id != nil {
+		return *r.id, nil
+	}
+	q := r.q.Select("id")
+
+	var response ServiceID
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// XXX_GraphQLType is an internal function. It returns the native GraphQL type name
+func (r *Service) XXX_GraphQLType() string {
+	return "Service"
+}
+
+// XXX_GraphQLID is an internal function. It returns the underlying type ID
+func (r *Service) XXX_GraphQLID(ctx context.Context) (string, error) {
+	id, err := r.ID(ctx)
+	if err != nil {
+		return "", err
+	}
+	return string(id), nil
+}
+
+// The last 16 KiB of output the service has logged since it was last started,
+// with stdout and stderr interleaved.
+//
+// Empty once the service is stopped.
+func (r *Service) Logs(ctx context.Context) (string, error) {
+	if r.logs != nil {
+		return *r.logs, nil
+	}
+	q := r.q.Select("logs")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// Starts the service and waits for its health check to pass.
+//
+// The service keeps running until it's stopped or the session ends. Starting
+// a service that's already running does nothing.
+func (r *Service) Start(ctx context.Context) (ServiceID, error) {
+	if r.start != nil {
+		return *r.start, nil
+	}
+	q := r.q.Select("start")
+
+	var response ServiceID
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The state of the service.
+func (r *Service) Status(ctx context.Context) (ServiceStatus, error) {
+	if r.status != nil {
+		return *r.status, nil
+	}
+	q := r.q.Select("status")
+
+	var response ServiceStatus
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// Stops the service and waits for it to exit.
+//
+// Stopping a service that isn't running does nothing.
+func (r *Service) Stop(ctx context.Context) (ServiceID, error) {
+	if r.stop != nil {
+		return *r.stop, nil
+	}
+	q := r.q.Select("stop")
+
+	var response ServiceID
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
 type Socket struct {
 	q *querybuilder.Selection
 	c graphql.Client
@@ -2658,6 +4543,173 @@ func (r *Socket) XXX_GraphQLID(ctx context.Context) (string, error) {
 	return string(id), nil
 }
 
+// Metadata about a file or directory.
+type Stat struct {
+	q *querybuilder.Selection
+	c graphql.Client
+
+	gid         *int
+	isDirectory *bool
+	isSymlink   *bool
+	linkTarget  *string
+	mode        *int
+	modifiedAt  *string
+	name        *string
+	size        *int
+	uid         *int
+}
+
+// The owning group ID.
+func (r *Stat) Gid(ctx context.Context) (int, error) {
+	if r.gid != nil {
+		return *r.gid, nil
+	}
+	q := r.q.Select("gid")
+
+	var response int
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// Whether this is a directory.
+func (r *Stat) IsDirectory(ctx context.Context) (bool, error) {
+	if r.isDirectory != nil {
+		return *r.isDirectory, nil
+	}
+	q := r.q.Select("isDirectory")
+
+	var response bool
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// Whether this is a symbolic link.
+func (r *Stat) IsSymlink(ctx context.Context) (bool, error) {
+	if r.isSymlink != nil {
+		return *r.isSymlink, nil
+	}
+	q := r.q.Select("isSymlink")
+
+	var response bool
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The path a symbolic link points to. Empty if this is not a symbolic link.
+func (r *Stat) LinkTarget(ctx context.Context) (string, error) {
+	if r.linkTarget != nil {
+		return *r.linkTarget, nil
+	}
+	q := r.q.Select("linkTarget")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The Unix permission bits, including setuid, setgid and sticky (e.g., 0755).
+func (r *Stat) Mode(ctx context.Context) (int, error) {
+	if r.mode != nil {
+		return *r.mode, nil
+	}
+	q := r.q.Select("mode")
+
+	var response int
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// When the contents were last modified, in RFC 3339 format.
+func (r *Stat) ModifiedAt(ctx context.Context) (string, error) {
+	if r.modifiedAt != nil {
+		return *r.modifiedAt, nil
+	}
+	q := r.q.Select("modifiedAt")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The base name of the file or directory.
+func (r *Stat) Name(ctx context.Context) (string, error) {
+	if r.name != nil {
+		return *r.name, nil
+	}
+	q := r.q.Select("name")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The size, in bytes.
+func (r *Stat) Size(ctx context.Context) (int, error) {
+	if r.size != nil {
+		return *r.size, nil
+	}
+	q := r.q.Select("size")
+
+	var response int
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// The owning user ID.
+func (r *Stat) UID(ctx context.Context) (int, error) {
+	if r.uid != nil {
+		return *r.uid, nil
+	}
+	q := r.q.Select("uid")
+
+	var response int
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+// An interactive terminal session attached to a container.
+type Terminal struct {
+	q *querybuilder.Selection
+	c graphql.Client
+
+	websocketEndpoint *string
+}
+
+// The websocket endpoint serving the terminal, relative to the session's address.
+//
+// Binary messages carry the terminal's input and output. Text messages of the
+// form "resize;<cols>;<rows>" resize the terminal. The connection is closed
+// with a reason of "exit <code>" once the process exits.
+func (r *Terminal) WebsocketEndpoint(ctx context.Context) (string, error) {
+	if r.websocketEndpoint != nil {
+		return *r.websocketEndpoint, nil
+	}
+	q := r.q.Select("websocketEndpoint")
+
+	var response string
+
+	q = q.Bind(&response)
+	return response, q.Execute(ctx, r.c)
+}
+
+type ArchiveCompression string
+
+const (
+	Gzip         ArchiveCompression = "GZIP"
+	Uncompressed ArchiveCompression = "UNCOMPRESSED"
+	Zip          ArchiveCompression = "ZIP"
+	Zstd         ArchiveCompression = "ZSTD"
+)
+
 type CacheSharingMode string
 
 const (
@@ -2666,9 +4718,63 @@ const (
 	Shared  CacheSharingMode = "SHARED"
 )
 
+type ContainerRuntime string
+
+const (
+	Containerd ContainerRuntime = "CONTAINERD"
+	Docker     ContainerRuntime = "DOCKER"
+	Podman     ContainerRuntime = "PODMAN"
+)
+
+type DigestAlgorithm string
+
+const (
+	Sha256 DigestAlgorithm = "SHA256"
+	Sha512 DigestAlgorithm = "SHA512"
+)
+
+type ExecExpect string
+
+const (
+	Any     ExecExpect = "ANY"
+	Failure ExecExpect = "FAILURE"
+	Success ExecExpect = "SUCCESS"
+)
+
+type ImageLayerCompression string
+
+const (
+	LayerEstargz ImageLayerCompression = "LAYER_ESTARGZ"
+	LayerGzip    ImageLayerCompression = "LAYER_GZIP"
+	LayerZstd    ImageLayerCompression = "LAYER_ZSTD"
+)
+
+type ImageMediaTypes string
+
+const (
+	DockerMediaTypes ImageMediaTypes = "DOCKER_MEDIA_TYPES"
+	OciMediaTypes    ImageMediaTypes = "OCI_MEDIA_TYPES"
+)
+
 type NetworkProtocol string
 
 const (
 	Tcp NetworkProtocol = "TCP"
 	Udp NetworkProtocol = "UDP"
 )
+
+type SbomFormat string
+
+const (
+	Cyclonedx SbomFormat = "CYCLONEDX"
+	Spdx      SbomFormat = "SPDX"
+)
+
+type ServiceStatus string
+
+const (
+	ServiceExited   ServiceStatus = "SERVICE_EXITED"
+	ServiceRunning  ServiceStatus = "SERVICE_RUNNING"
+	ServiceStarting ServiceStatus = "SERVICE_STARTING"
+	ServiceStopped  ServiceStatus = "SERVICE_STOPPED"
+)